@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// cacheFlusher is implemented by news sources that keep a cache that can be flushed
+type cacheFlusher interface {
+	Flush()
+}
+
+// cacheCloser is implemented by news sources that keep caches, to stop their background eviction
+// on shutdown
+type cacheCloser interface {
+	Close()
+}
+
+// adminAPI exposes operational endpoints, authenticated with a bearer token
+type adminAPI struct {
+	token         string
+	cfg           Config
+	newsSource    NewsSource
+	subscriptions *subscriptionStore
+	logger        *slog.Logger
+}
+
+func newAdminAPI(cfg Config, newsSource NewsSource, subscriptions *subscriptionStore, logger *slog.Logger) *adminAPI {
+	return &adminAPI{
+		token:         cfg.adminAPIToken,
+		cfg:           cfg,
+		newsSource:    newsSource,
+		subscriptions: subscriptions,
+		logger:        logger,
+	}
+}
+
+// Handler returns the admin routes, meant to be registered under /admin/
+func (a *adminAPI) Handler() http.Handler {
+	r := http.NewServeMux()
+	r.HandleFunc("/admin/flush-cache", a.handleFlushCache)
+	r.HandleFunc("/admin/config", a.handleConfig)
+	r.HandleFunc("/admin/subscriptions", a.handleSubscriptions)
+	return a.authenticate(r)
+}
+
+// authenticate rejects requests that don't carry the admin bearer token
+func (a *adminAPI) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if a.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *adminAPI) handleFlushCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	flusher, ok := a.newsSource.(cacheFlusher)
+	if !ok {
+		writeJSON(w, http.StatusNotImplemented, map[string]string{"error": "news source has no cache"})
+		return
+	}
+	flusher.Flush()
+	a.logger.Info("cache flushed through the admin API")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "flushed"})
+}
+
+func (a *adminAPI) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, redactedConfig(a.cfg))
+}
+
+func (a *adminAPI) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, a.subscriptions.List())
+}
+
+// redactedConfig returns the config values by field name, hiding anything that looks like a credential
+func redactedConfig(cfg Config) map[string]string {
+	result := map[string]string{}
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		value := fmt.Sprint(v.Field(i))
+		lower := strings.ToLower(name)
+		if value != "" && (strings.Contains(lower, "token") || strings.Contains(lower, "key") || strings.Contains(lower, "secret")) {
+			value = "[redacted]"
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// writeJSON writes v as the JSON response body with the given status code. The bodies are plain
+// maps and structs, so writing only fails when the client went away, and there's no one left to
+// tell.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
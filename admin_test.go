@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// flushableNews is a news source with a cache, counting its flushes
+type flushableNews struct {
+	*fakeNews
+	flushes int
+}
+
+func (f *flushableNews) Flush() {
+	f.flushes++
+}
+
+func newTestAdminAPI() (*adminAPI, *flushableNews) {
+	news := &flushableNews{fakeNews: &fakeNews{}}
+	cfg := Config{adminAPIToken: "admin-token", nytAPIKey: "nyt-key", slackSigningSecret: "signing-secret", port: 8080}
+	subscriptions := newSubscriptionStore([]subscription{{ChannelID: "C0TESTCHANNEL", Section: "world"}})
+	return newAdminAPI(cfg, news, subscriptions, discardLogger()), news
+}
+
+func adminRequest(h http.Handler, method string, path string, authorization string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAdminAPIUnauthorized(t *testing.T) {
+	api, news := newTestAdminAPI()
+	h := api.Handler()
+	for _, authorization := range []string{"", "Bearer wrong-token", "admin-token extra", "Basic YWRtaW46YWRtaW4="} {
+		for _, route := range []struct{ method, path string }{
+			{http.MethodPost, "/admin/flush-cache"},
+			{http.MethodGet, "/admin/config"},
+			{http.MethodGet, "/admin/subscriptions"},
+		} {
+			w := adminRequest(h, route.method, route.path, authorization)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with %q: got status %d, want %d", route.method, route.path, authorization, w.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+	if news.flushes != 0 {
+		t.Errorf("the cache was flushed %d times without the token", news.flushes)
+	}
+}
+
+func TestAdminAPIWithoutTokenRejectsEverything(t *testing.T) {
+	api := newAdminAPI(Config{}, &fakeNews{}, newSubscriptionStore(nil), discardLogger())
+	if w := adminRequest(api.Handler(), http.MethodGet, "/admin/config", "Bearer "); w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminAPIAuthorized(t *testing.T) {
+	api, news := newTestAdminAPI()
+	h := api.Handler()
+
+	w := adminRequest(h, http.MethodPost, "/admin/flush-cache", "Bearer admin-token")
+	if w.Code != http.StatusOK || news.flushes != 1 {
+		t.Errorf("flush-cache: got status %d and %d flushes, want %d and 1", w.Code, news.flushes, http.StatusOK)
+	}
+	if w := adminRequest(h, http.MethodGet, "/admin/flush-cache", "Bearer admin-token"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET flush-cache: got status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+
+	w = adminRequest(h, http.MethodGet, "/admin/config", "Bearer admin-token")
+	var config map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&config); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("config: got status %d and error %v", w.Code, err)
+	}
+	for _, field := range []string{"adminAPIToken", "nytAPIKey", "slackSigningSecret"} {
+		if config[field] != "[redacted]" {
+			t.Errorf("config field %s is %q, want it redacted", field, config[field])
+		}
+	}
+	if config["port"] != "8080" {
+		t.Errorf("config field port is %q, want 8080", config["port"])
+	}
+	if config["guardianAPIKey"] != "" {
+		t.Errorf("empty config field guardianAPIKey is %q, want it empty", config["guardianAPIKey"])
+	}
+
+	w = adminRequest(h, http.MethodGet, "/admin/subscriptions", "Bearer admin-token")
+	var subscriptions []subscription
+	if err := json.NewDecoder(w.Body).Decode(&subscriptions); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("subscriptions: got status %d and error %v", w.Code, err)
+	}
+	if len(subscriptions) != 1 || subscriptions[0].ChannelID != "C0TESTCHANNEL" || subscriptions[0].Section != "world" {
+		t.Errorf("got subscriptions %+v", subscriptions)
+	}
+}
+
+func TestAdminAPIFlushWithoutCache(t *testing.T) {
+	api := newAdminAPI(Config{adminAPIToken: "admin-token"}, &fakeNews{}, newSubscriptionStore(nil), discardLogger())
+	if w := adminRequest(api.Handler(), http.MethodPost, "/admin/flush-cache", "Bearer admin-token"); w.Code != http.StatusNotImplemented {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusNotImplemented)
+	}
+}
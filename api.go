@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// articleFields lists the JSON fields of an Article, in the order they are declared
+var articleFields = jsonFields(reflect.TypeOf(Article{}))
+
+// jsonFields returns the JSON names of the fields of a struct type
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// parseFields parses a comma separated list of article fields, returning every field when the
+// list is empty
+func parseFields(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return articleFields, nil
+	}
+	known := map[string]bool{}
+	for _, field := range articleFields {
+		known[field] = true
+	}
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if !known[field] {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(articleFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// projectArticles keeps only the given JSON fields of the articles
+func projectArticles(articles []Article, fields []string) ([]map[string]json.RawMessage, error) {
+	projected := []map[string]json.RawMessage{}
+	for _, a := range articles {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		story := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			story[field] = all[field]
+		}
+		projected = append(projected, story)
+	}
+	return projected, nil
+}
+
+// apiStoryCount returns the number of stories of an API request, using the default when none was
+// requested. Unlike storyCount it isn't bound by what fits in a slack message, only by
+// apiMaxStories and what the news source can return, in which case limited is true.
+func (b *Bot) apiStoryCount(requested int) (count int, limited bool) {
+	max := min(b.apiMaxStories, b.newsSource.MaxStories())
+	if requested <= 0 {
+		return min(defaultStoryCount, max), false
+	}
+	if requested > max {
+		return max, true
+	}
+	return requested, false
+}
+
+// HandleStoriesAPI serves the top stories of a section as JSON, for the clients other than slack.
+// The query params are all optional: section (defaults to home), n the number of stories and
+// fields the comma separated article fields to return (defaults to all of them). Larger counts
+// than apiMaxStories are capped, which the X-Result-Limited header tells.
+func (b *Bot) HandleStoriesAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	section := normalizeSection(query.Get("section"))
+	if section == "" {
+		section = defaultSection
+	}
+	// forgive the typos like the commands do, the unknown sections are rejected by the source
+	if s, ok := resolveSection(section, b.newsSource.SupportedSections()); ok {
+		section = s
+	}
+	requested := 0
+	if n := query.Get("n"); n != "" {
+		var err error
+		if requested, err = strconv.Atoi(n); err != nil || requested < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "n must be a positive number"})
+			return
+		}
+	}
+	topN, limited := b.apiStoryCount(requested)
+	fields, err := parseFields(query.Get("fields"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	articles, err := b.newsSource.TopStories(r.Context(), section, topN)
+	b.metrics.recordRequest(section, err)
+	switch {
+	case errors.Is(err, ErrInvalidSection):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown section"})
+		return
+	case err != nil:
+		b.logger.Error("error requesting top stories for the API", "section", section, "error", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "news source unavailable"})
+		return
+	}
+
+	stories, err := projectArticles(articles, fields)
+	if err != nil {
+		b.logger.Error("error encoding stories for the API", "section", section, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	if limited {
+		w.Header().Set("X-Result-Limited", "true")
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"section": section, "stories": stories})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// getStories calls the stories API with the given query and decodes its JSON response
+func getStories(t *testing.T, b *Bot, query url.Values) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	b.HandleStoriesAPI(rec, httptest.NewRequest(http.MethodGet, "/api/stories?"+query.Encode(), nil))
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding the response %q: %v", rec.Body.String(), err)
+	}
+	return rec, body
+}
+
+func TestStoriesAPIFields(t *testing.T) {
+	all := append([]string(nil), articleFields...)
+	sort.Strings(all)
+	tests := []struct {
+		fields string
+		want   []string
+	}{
+		{"", all},
+		{"   ", all},
+		{"title,url", []string{"title", "url"}},
+		{" url , title ", []string{"title", "url"}},
+		{"title,title", []string{"title"}},
+		{"published_time", []string{"published_time"}},
+	}
+	news := &fakeNews{stories: map[string][]Article{"home": testArticles(2)}}
+	b, _ := newTestBot(t, news, func(cfg *Config) { cfg.apiMaxStories = 10 })
+	for _, tt := range tests {
+		t.Run(tt.fields, func(t *testing.T) {
+			rec, body := getStories(t, b, url.Values{"fields": {tt.fields}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+			}
+			var stories []map[string]json.RawMessage
+			if err := json.Unmarshal(body["stories"], &stories); err != nil {
+				t.Fatal(err)
+			}
+			if len(stories) != 2 {
+				t.Fatalf("got %d stories, want 2", len(stories))
+			}
+			for _, story := range stories {
+				var got []string
+				for field := range story {
+					got = append(got, field)
+				}
+				sort.Strings(got)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got fields %v, want %v", got, tt.want)
+				}
+			}
+			var title string
+			if raw, ok := stories[0]["title"]; ok {
+				if err := json.Unmarshal(raw, &title); err != nil || title != "Story 1" {
+					t.Errorf("got title %s, want Story 1", raw)
+				}
+			}
+		})
+	}
+}
+
+func TestStoriesAPIInvalidFields(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"home": testArticles(2)}}
+	b, _ := newTestBot(t, news, func(cfg *Config) { cfg.apiMaxStories = 10 })
+	for _, fields := range []string{"bogus", "title,bogus", "title,", "Title", "published_at,-"} {
+		t.Run(fields, func(t *testing.T) {
+			rec, body := getStories(t, b, url.Values{"fields": {fields}})
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want 400", rec.Code)
+			}
+			var message string
+			if err := json.Unmarshal(body["error"], &message); err != nil || !strings.Contains(message, "unknown field") {
+				t.Errorf("got error %s, want the unknown field reported", body["error"])
+			}
+		})
+	}
+	if requests := news.requested(); len(requests) > 0 {
+		t.Errorf("requested %v for invalid fields", requests)
+	}
+}
+
+func TestStoriesAPIMaxStories(t *testing.T) {
+	tests := []struct {
+		name       string
+		n          string
+		maxStories int
+		want       int
+		limited    bool
+	}{
+		{"default", "", 0, defaultStoryCount, false},
+		{"over the slack limit", "12", 0, 12, false},
+		{"at the cap", "15", 0, 15, false},
+		{"over the cap", "16", 0, 15, true},
+		{"far over the cap", "500", 0, 15, true},
+		{"over the source limit", "8", 5, 5, true},
+		{"default over the source limit", "", 2, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			news := &fakeNews{stories: map[string][]Article{"home": testArticles(30)}, maxStories: tt.maxStories}
+			b, _ := newTestBot(t, news, func(cfg *Config) { cfg.apiMaxStories = 15 })
+			query := url.Values{}
+			if tt.n != "" {
+				query.Set("n", tt.n)
+			}
+			rec, body := getStories(t, b, query)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+			}
+			var stories []json.RawMessage
+			if err := json.Unmarshal(body["stories"], &stories); err != nil {
+				t.Fatal(err)
+			}
+			if len(stories) != tt.want {
+				t.Errorf("got %d stories, want %d", len(stories), tt.want)
+			}
+			if got := rec.Header().Get("X-Result-Limited") == "true"; got != tt.limited {
+				t.Errorf("got X-Result-Limited %q, want limited %t", rec.Header().Get("X-Result-Limited"), tt.limited)
+			}
+		})
+	}
+}
+
+func TestStoriesAPIResolvesSections(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"technology": testArticles(2), "politics": testArticles(1)}}
+	b, _ := newTestBot(t, news, func(cfg *Config) { cfg.apiMaxStories = 10 })
+	for _, section := range []string{"tech", "technolgy", "Technology"} {
+		rec, body := getStories(t, b, url.Values{"section": {section}})
+		if rec.Code != http.StatusOK || string(body["section"]) != `"technology"` {
+			t.Errorf("section %q: got status %d and section %s, want the technology stories", section, rec.Code, body["section"])
+		}
+	}
+}
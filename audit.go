@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// command statuses recorded in the audit log
+const (
+	statusOK       = "ok"
+	statusEmpty    = "empty"
+	statusRejected = "rejected"
+	statusError    = "error"
+)
+
+// commandResult collects the outcome of a command while it is handled
+type commandResult struct {
+	status string
+}
+
+// auditEntry describes a command once it completed
+type auditEntry struct {
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlation_id"`
+	TeamID        string    `json:"team_id"`
+	ChannelID     string    `json:"channel_id"`
+	UserID        string    `json:"user_id"`
+	Command       string    `json:"command"`
+	Status        string    `json:"status"`
+}
+
+// auditLogger records the commands handled by the bot. Implementations must not block, so
+// auditing never delays or fails the response to the user.
+type auditLogger interface {
+	record(entry auditEntry)
+}
+
+// newAuditLogger picks the audit logger from the config: posting to a channel, writing to the log,
+// or nothing at all
+func newAuditLogger(cfg Config, logger *slog.Logger, post func(channelID string, text string) error) auditLogger {
+	switch {
+	case cfg.auditChannel != "":
+		return newChannelAuditLogger(cfg.auditChannel, logger, post)
+	case cfg.auditLog:
+		return logAuditLogger{logger: logger}
+	default:
+		return noopAuditLogger{}
+	}
+}
+
+// noopAuditLogger drops the audit entries
+type noopAuditLogger struct{}
+
+func (noopAuditLogger) record(auditEntry) {}
+
+// logAuditLogger writes the audit entries to the log, with a field per entry field. The time of
+// the entry is the time of the log record.
+type logAuditLogger struct {
+	logger *slog.Logger
+}
+
+func (l logAuditLogger) record(entry auditEntry) {
+	l.logger.Info("audit",
+		"correlation_id", entry.CorrelationID,
+		"team_id", entry.TeamID,
+		"channel_id", entry.ChannelID,
+		"user_id", entry.UserID,
+		"command", entry.Command,
+		"status", entry.Status,
+	)
+}
+
+// channelAuditLogger posts the audit entries to a slack channel. The entries are queued and
+// posted in the background, and dropped when the queue is full.
+type channelAuditLogger struct {
+	channelID string
+	logger    *slog.Logger
+	post      func(channelID string, text string) error
+	entries   chan auditEntry
+}
+
+// auditQueueSize is the number of audit entries waiting to be posted before new ones are dropped
+const auditQueueSize = 100
+
+func newChannelAuditLogger(channelID string, logger *slog.Logger, post func(channelID string, text string) error) *channelAuditLogger {
+	l := &channelAuditLogger{channelID: channelID, logger: logger, post: post, entries: make(chan auditEntry, auditQueueSize)}
+	go l.run()
+	return l
+}
+
+func (l *channelAuditLogger) record(entry auditEntry) {
+	select {
+	case l.entries <- entry:
+	default:
+		l.logger.Warn("audit queue is full, dropping the entry", "correlation_id", entry.CorrelationID)
+	}
+}
+
+func (l *channelAuditLogger) run() {
+	for entry := range l.entries {
+		text := fmt.Sprintf("`%s` by <@%s> in <#%s>: *%s* (%s)", entry.Command, entry.UserID, entry.ChannelID, entry.Status, entry.CorrelationID)
+		if err := l.post(l.channelID, text); err != nil {
+			l.logger.Error("error posting audit entry", "correlation_id", entry.CorrelationID, "error", err)
+		}
+	}
+}
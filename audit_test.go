@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingAuditLogger keeps the audit entries in memory
+type recordingAuditLogger struct {
+	mu      sync.Mutex
+	entries []auditEntry
+}
+
+func (l *recordingAuditLogger) record(entry auditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func TestAuditEntries(t *testing.T) {
+	stories := map[string][]Article{"world": testArticles(3), "science": nil}
+	tests := []struct {
+		name   string
+		text   string
+		news   *fakeNews
+		status string
+	}{
+		{"stories", "stories world", &fakeNews{stories: stories}, statusOK},
+		{"help", "help", &fakeNews{stories: stories}, statusOK},
+		{"no stories", "stories science", &fakeNews{stories: stories}, statusEmpty},
+		{"unknown section", "stories bogus", &fakeNews{stories: stories}, statusRejected},
+		{"news source error", "stories world", &fakeNews{stories: stories, err: errors.New("boom")}, statusError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, tt.news, nil)
+			auditor := &recordingAuditLogger{}
+			b.auditor = auditor
+			runCommand(t, b, fake, testCommandRequest(fake), tt.text)
+
+			if len(auditor.entries) != 1 {
+				t.Fatalf("got %d audit entries, want 1", len(auditor.entries))
+			}
+			entry := auditor.entries[0]
+			want := auditEntry{
+				Time:          testNow,
+				CorrelationID: entry.CorrelationID,
+				TeamID:        "T0TEST",
+				ChannelID:     "C0TESTCHANNEL",
+				UserID:        "U0TEST",
+				Command:       "/news " + tt.text,
+				Status:        tt.status,
+			}
+			if entry != want {
+				t.Errorf("got entry %+v, want %+v", entry, want)
+			}
+			if entry.CorrelationID == "" {
+				t.Error("the entry has no correlation ID")
+			}
+		})
+	}
+}
+
+func TestNewAuditLogger(t *testing.T) {
+	post := func(string, string) error { return nil }
+	if _, ok := newAuditLogger(Config{}, discardLogger(), post).(noopAuditLogger); !ok {
+		t.Error("auditing isn't disabled by default")
+	}
+	if _, ok := newAuditLogger(Config{auditLog: true}, discardLogger(), post).(logAuditLogger); !ok {
+		t.Error("the audit log isn't written to the log")
+	}
+	if l, ok := newAuditLogger(Config{auditLog: true, auditChannel: "C0AUDIT"}, discardLogger(), post).(*channelAuditLogger); !ok || l.channelID != "C0AUDIT" {
+		t.Error("the audit log isn't posted to the audit channel")
+	}
+}
+
+func TestLogAuditLogger(t *testing.T) {
+	var logs bytes.Buffer
+	l := logAuditLogger{logger: slog.New(slog.NewJSONHandler(&logs, nil))}
+	l.record(auditEntry{CorrelationID: "abc123", TeamID: "T0TEST", ChannelID: "C0TESTCHANNEL", UserID: "U0TEST", Command: "/news stories world", Status: statusOK})
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("decoding the log %q: %v", logs.String(), err)
+	}
+	want := map[string]string{"msg": "audit", "correlation_id": "abc123", "team_id": "T0TEST", "channel_id": "C0TESTCHANNEL",
+		"user_id": "U0TEST", "command": "/news stories world", "status": statusOK}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("got %s %v, want %q", key, record[key], value)
+		}
+	}
+}
+
+func TestChannelAuditLogger(t *testing.T) {
+	posted := make(chan string)
+	l := newChannelAuditLogger("C0AUDIT", discardLogger(), func(channelID string, text string) error {
+		if channelID != "C0AUDIT" {
+			t.Errorf("posted to %q, want the audit channel", channelID)
+		}
+		posted <- text
+		return errors.New("posting failed")
+	})
+	l.record(auditEntry{CorrelationID: "abc123", ChannelID: "C0TESTCHANNEL", UserID: "U0TEST", Command: "/news stories world", Status: statusOK})
+	select {
+	case text := <-posted:
+		want := "`/news stories world` by <@U0TEST> in <#C0TESTCHANNEL>: *ok* (abc123)"
+		if text != want {
+			t.Errorf("posted %q, want %q", text, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the entry wasn't posted")
+	}
+
+	// the next post blocks, so the queue fills up: recording must drop the entries rather than block
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < auditQueueSize+10; i++ {
+			l.record(auditEntry{CorrelationID: strings.Repeat("x", i)})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("recording blocked with a full queue")
+	}
+}
+
+func TestAuditPostFailureKeepsResponse(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.auditChannel = "C0AUDIT" })
+	fake.failNext("chat.postMessage", "channel_not_found")
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world")
+	if len(responses) != 1 || !strings.Contains(responses[0].text(), "Story 1") {
+		t.Errorf("got responses %+v, want the stories posted", responses)
+	}
+	// the entry is posted in the background
+	for deadline := time.Now().Add(time.Second); len(fake.received("chat.postMessage")) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("the audit entry wasn't posted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRetryAfter is used when a rate limited response doesn't tell us how long to wait
+const defaultRetryAfter = time.Minute
+
+// backoffGate is shared by every call to an upstream API. Once the upstream rate limits us
+// the gate closes and calls fail fast until the backoff expires, instead of each request
+// retrying on its own.
+type backoffGate struct {
+	mu    sync.Mutex
+	until time.Time
+	now   func() time.Time
+}
+
+func newBackoffGate() *backoffGate {
+	return &backoffGate{now: time.Now}
+}
+
+// open reports whether calls are currently allowed through the gate
+func (g *backoffGate) open() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !g.now().Before(g.until)
+}
+
+// backoff closes the gate for d. It never shortens a backoff already in place.
+func (g *backoffGate) backoff(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := g.now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of seconds
+// or an HTTP date, falling back to defaultRetryAfter when it is missing or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return defaultRetryAfter
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNYTimesGatedAfterRateLimit(t *testing.T) {
+	calls := 0
+	rateLimited := true
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if rateLimited {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Back")))
+	})
+	now := testNow
+	nyt.gate.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := nyt.TopStories(ctx, "world", 3); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited on a 429", err)
+	}
+	rateLimited = false
+
+	// every endpoint shares the gate, none of them reaches NYT during the backoff
+	now = now.Add(119 * time.Second)
+	if _, err := nyt.TopStories(ctx, "science", 3); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v during the backoff, want ErrRateLimited", err)
+	}
+	if _, err := nyt.SearchArticles(ctx, "eclipse", 3); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v searching during the backoff, want ErrRateLimited", err)
+	}
+	if calls != 1 {
+		t.Fatalf("NYT got %d calls, want only the rate limited one", calls)
+	}
+
+	now = now.Add(2 * time.Second)
+	articles, err := nyt.TopStories(ctx, "world", 3)
+	if err != nil {
+		t.Fatalf("got %v after the backoff, want the calls to resume", err)
+	}
+	if len(articles) != 1 || calls != 2 {
+		t.Errorf("got %d stories after %d calls, want 1 story after 2 calls", len(articles), calls)
+	}
+}
+
+func TestBackoffGateNeverShortens(t *testing.T) {
+	g := newBackoffGate()
+	now := testNow
+	g.now = func() time.Time { return now }
+
+	if !g.open() {
+		t.Fatal("a new gate is closed")
+	}
+	g.backoff(time.Minute)
+	g.backoff(time.Second)
+	now = now.Add(30 * time.Second)
+	if g.open() {
+		t.Error("a shorter backoff reopened the gate")
+	}
+	now = now.Add(30 * time.Second)
+	if !g.open() {
+		t.Error("the gate is still closed once the backoff expired")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"30", 30 * time.Second},
+		{testNow.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"", defaultRetryAfter},
+		{"0", defaultRetryAfter},
+		{"-5", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+		// a date in the past doesn't tell us how long to wait
+		{testNow.Add(-time.Minute).Format(http.TimeFormat), defaultRetryAfter},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, testNow); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
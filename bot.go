@@ -1,50 +1,364 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/slack-go/slack"
 )
 
+const (
+	invalidSectionMessage      = "⚠️ That's not a valid news section! Try requesting `/news help` to learn how to use this app!"
+	invalidCountMessage        = "⚠️ The number of stories must be a number, e.g. `/news stories politics 5`"
+	sectionsUnavailableMessage = "⚠️ News sections are temporarily unavailable. Try again later!"
+	genericErrorMessage        = "⚠️ Oops, something went wrong on our side. Try again later!"
+	productNotEnabledMessage   = "⚠️ This feature isn't enabled for our API key."
+	rateLimitedMessage         = "⏳ We're getting a lot of requests right now — please try again in a minute."
+	timedOutMessage            = "⏳ The request timed out, please try again."
+	invalidPopularMessage      = "⚠️ Popular stories are ranked by `viewed`, `emailed` or `shared` over 1, 7 or 30 days, e.g. `/news popular viewed 7`"
+	unsupportedMessage         = "⚠️ Our news source doesn't offer this, sorry!"
+)
+
+// newsErrorStatus picks the audit status of a news source error
+func newsErrorStatus(err error) string {
+	if errors.Is(err, ErrInvalidSection) || errors.Is(err, ErrInvalidMetric) || errors.Is(err, ErrInvalidPeriod) {
+		return statusRejected
+	}
+	return statusError
+}
+
+// newsErrorMessage picks the message shown to the user when the news source fails
+func newsErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidSection):
+		return invalidSectionMessage
+	case errors.Is(err, ErrInvalidMetric), errors.Is(err, ErrInvalidPeriod):
+		return invalidPopularMessage
+	case errors.Is(err, ErrUnsupported):
+		return unsupportedMessage
+	case errors.Is(err, ErrProductNotEnabled):
+		return productNotEnabledMessage
+	case errors.Is(err, ErrRateLimited):
+		return rateLimitedMessage
+	case errors.Is(err, context.DeadlineExceeded):
+		return timedOutMessage
+	default:
+		return genericErrorMessage
+	}
+}
+
+const (
+	// defaultStoryCount is the number of stories shown when the user doesn't ask for a number
+	defaultStoryCount = 3
+	// maxStoryCount is the most stories we show at once, to keep the message within slack limits
+	maxStoryCount = 10
+)
+
+// defaultSection is the section shown when none is requested
+const defaultSection = "home"
+
+// popularityCandidates is the number of section stories considered when ranking by popularity
+const popularityCandidates = 50
+
+// commandRequest holds the information about where a command came from and how to respond to it
+type commandRequest struct {
+	// id correlates the logs of the request
+	id           string
+	teamID       string
+	channelID    string
+	userID       string
+	responseURL  string
+	responseType string
+	// features are the global feature flags with the request overrides applied
+	features featureFlags
+	// messageTS is the message the request was triggered from, if any. The response replaces it.
+	messageTS      string
+	messageVisible bool
+	// result is shared by the copies of the request, so handlers can report the outcome to the audit
+	result *commandResult
+}
+
+// setStatus records the outcome of the command, for the audit log
+func (req commandRequest) setStatus(status string) {
+	if req.result != nil {
+		req.result.status = status
+	}
+}
+
+// visibleToChannel reports whether the response is seen by the whole channel, which is also the
+// case when it replaces a visible message
+func (req commandRequest) visibleToChannel() bool {
+	return req.responseType == slack.ResponseTypeInChannel || req.messageVisible
+}
+
+// ephemeral returns a copy of the request responding only to the user who sent it
+func (req commandRequest) ephemeral() commandRequest {
+	req.responseType = slack.ResponseTypeEphemeral
+	return req
+}
+
+// defaultResponseType picks the response visibility for a channel. Responses in a direct message
+// with the bot are only visible to the user anyway, so they're posted in channel. Responses
+// everywhere else default to ephemeral so we don't flood the channel.
+func defaultResponseType(channelID string) string {
+	if strings.HasPrefix(channelID, "D") {
+		return slack.ResponseTypeInChannel
+	}
+	return slack.ResponseTypeEphemeral
+}
+
 type Bot struct {
 	newsSource             NewsSource
 	slackVerificationToken string
-	slackClient            *slack.Client
+	// slackSigningSecret verifies the requests from slack, the verification token is only checked without it
+	slackSigningSecret string
+	slackClients       *slackClients
+	metrics            *metrics
+	renderDefaults     RenderOptions
+	// responseURLHosts lists the hosts we accept to post slack responses to
+	responseURLHosts []string
+	subscriptions    *subscriptionStore
+	briefingSections []string
+	breakingSections []string
+	// rotationPoster posts the section of the day, it is nil when no rotation is configured
+	rotationPoster *rotationPoster
+
+	// digestPins keeps the latest digest pinned in the subscribed channels, it is nil when disabled
+	digestPins *digestPins
+	// digestHistory skips the digests with no new stories, it is nil when disabled
+	digestHistory *digestHistory
+
+	// briefingGroups are the groups of sections of the default briefing, if any
+	briefingGroups []briefingGroup
+
+	// relatedSections are offered as quick replies after the stories of each section
+	relatedSections map[string][]string
+
+	// now is the clock of the bot, used to render relative times and find today's date
+	now func() time.Time
+
+	// auditor records the completed commands
+	auditor auditLogger
+
+	// tasks tracks the commands handled in the background, so shutdown can wait for them. Their
+	// context is cancelled by stopTasks.
+	tasks     sync.WaitGroup
+	tasksCtx  context.Context
+	stopTasks context.CancelFunc
+
+	// postSlots limits the concurrent posts to slack, to stay within its rate limits
+	postSlots chan struct{}
+
+	// maintenance short-circuits the commands while enabled
+	maintenance *maintenanceMode
+
+	// prom exports the metrics of the commands to Prometheus
+	prom *promMetrics
+
+	// logger logs with structured fields, the payload of the outgoing messages is logged at debug level
+	logger *slog.Logger
+
+	// compactDigestHeaders shows the section and time of the digests on a single line, the
+	// commands keep the full header
+	compactDigestHeaders bool
+
+	// commandTimeout bounds the time spent handling each command
+	commandTimeout time.Duration
+
+	// apiMaxStories caps the stories requested to the stories API, to protect the quota of the
+	// news source
+	apiMaxStories int
+
+	// cooldown throttles the commands posting in each channel
+	cooldown *cooldown
+	// quota limits the requests of each user per day
+	quota *dailyQuota
+
+	// imageValidator checks the images before rendering them, it is nil when validation is disabled
+	imageValidator *imageValidator
+
+	// tips are shown at random in the help view, rng is guarded by rngMu since it isn't safe for concurrent use
+	tips  []string
+	rngMu sync.Mutex
+	rng   *rand.Rand
+
+	features featureFlags
+	// adminUserIDs are the users allowed to override the feature flags per request
+	adminUserIDs []string
 }
 
 // NewBot instantiates a new Bot
-func NewBot(newsSource NewsSource, slackOAuthToken string, slackVerificationToken string) *Bot {
-	return &Bot{
+func NewBot(newsSource NewsSource, cfg Config, logger *slog.Logger, prom *promMetrics) *Bot {
+	tasksCtx, stopTasks := context.WithCancel(context.Background())
+	b := &Bot{
 		newsSource:             newsSource,
-		slackVerificationToken: slackVerificationToken,
-		slackClient:            slack.New(slackOAuthToken),
+		slackVerificationToken: cfg.slackVerificationToken,
+		slackSigningSecret:     cfg.slackSigningSecret,
+		slackClients:           newSlackClients(cfg.slackTokens),
+		metrics:                newMetrics(),
+		renderDefaults:         renderDefaults(newsSource, cfg, logger),
+		responseURLHosts:       cfg.slackResponseURLHosts,
+		subscriptions:          newSubscriptionStore(cfg.subscriptions),
+		digestPins:             newDigestPins(cfg),
+		digestHistory:          newDigestHistory(cfg),
+		briefingSections:       cfg.briefingSections,
+		breakingSections:       cfg.breakingSections,
+		briefingGroups:         cfg.briefingGroups,
+		relatedSections:        cfg.relatedSections,
+		tips:                   cfg.helpTips,
+		features:               cfg.features,
+		adminUserIDs:           cfg.adminUserIDs,
+		imageValidator:         newImageValidatorFromConfig(cfg),
+		cooldown:               newCooldown(cfg.commandCooldown),
+		commandTimeout:         cfg.commandTimeout,
+		apiMaxStories:          cfg.apiMaxStories,
+		compactDigestHeaders:   cfg.compactDigestHeaders,
+		quota:                  newDailyQuota(cfg.dailyQuota, cfg.quotaLocation),
+		logger:                 logger,
+		prom:                   prom,
+		now:                    time.Now,
+		rotationPoster:         newRotationPoster(cfg),
+		maintenance:            newMaintenanceMode(cfg),
+		postSlots:              make(chan struct{}, cfg.maxConcurrentPosts),
+		tasksCtx:               tasksCtx,
+		stopTasks:              stopTasks,
+		rng:                    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	b.auditor = newAuditLogger(cfg, logger, func(channelID string, text string) error {
+		_, _, err := b.postMessage(b.tasksCtx, newCorrelationID(), "", channelID, slack.MsgOptionText(text, false))
+		return err
+	})
+	return b
+}
+
+// runTask runs a task in the background, tracked so Wait can wait for it
+func (b *Bot) runTask(task func(ctx context.Context)) {
+	b.tasks.Add(1)
+	go func() {
+		defer b.tasks.Done()
+		task(b.tasksCtx)
+	}()
+}
+
+// Wait waits for the background tasks to finish. If ctx is done first, the tasks are cancelled and
+// the error of ctx returned.
+func (b *Bot) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		b.stopTasks()
+		return ctx.Err()
+	}
+}
+
+// Close stops the background eviction of the caches of the bot, once its tasks are done
+func (b *Bot) Close() {
+	if b.cooldown.last != nil {
+		b.cooldown.last.Close()
+	}
+	if b.quota.counts != nil {
+		b.quota.counts.Close()
+	}
+	if b.imageValidator != nil {
+		b.imageValidator.cache.Close()
+	}
+	if b.digestHistory != nil {
+		b.digestHistory.posted.Close()
+	}
+}
+
+func newImageValidatorFromConfig(cfg Config) *imageValidator {
+	if !cfg.validateImages {
+		return nil
 	}
+	return newImageValidator(2*time.Second, 10*time.Minute)
+}
+
+// render renders the stories as a message, see prepareRender
+func (b *Bot) render(ctx context.Context, articles []Article, opts RenderOptions) slack.MsgOption {
+	articles, opts = b.prepareRender(ctx, articles, opts)
+	return renderMessage(articles, opts)
+}
+
+// prepareRender applies the bot settings to the stories and options before rendering them. It drops
+// the images that can't be displayed when image validation is enabled, so an unreachable image
+// doesn't make slack reject the message.
+func (b *Bot) prepareRender(ctx context.Context, articles []Article, opts RenderOptions) ([]Article, RenderOptions) {
+	if opts.showImages() && b.imageValidator != nil {
+		articles = b.imageValidator.dropInvalidImages(ctx, articles)
+	}
+	opts.Now = b.now
+	return articles, opts
+}
+
+// renderDefaults builds the default rendering options from the config
+func renderDefaults(newsSource NewsSource, cfg Config, logger *slog.Logger) RenderOptions {
+	opts := RenderOptions{
+		HeadlinesOnly:       cfg.headlinesOnly,
+		HighlightLead:       cfg.highlightLead,
+		Attachments:         cfg.renderAttachments,
+		Color:               cfg.attachmentColor,
+		Images:              cfg.renderImages,
+		ImageStyle:          cfg.imageStyle,
+		AbstractPlaceholder: cfg.abstractPlaceholder,
+		Badges:              cfg.badges,
+		ArticleTemplate:     cfg.articleTemplate,
+		Freshness:           cfg.freshness,
+		Logger:              logger,
+	}
+	if opts.Color == "" {
+		opts.Color = newsSource.BrandColor()
+	}
+	return opts
 }
 
 // HandleSlashCommand handles a slash command request
 func (b *Bot) HandleSlashCommand(w http.ResponseWriter, r *http.Request) {
+	verified, err := b.verifySignature(r)
+	if err != nil {
+		b.logger.Warn("invalid request signature", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
 	s, err := slack.SlashCommandParse(r)
 	if err != nil {
-		log.Println("error parsing slash command:", err)
+		b.logger.Error("error parsing slash command", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	// TODO: validate request with signing secret instead
-	if !s.ValidateToken(b.slackVerificationToken) {
-		log.Println("invalid token")
+	if !verified && !s.ValidateToken(b.slackVerificationToken) {
+		b.logger.Warn("invalid token", "team_id", s.TeamID, "channel_id", s.ChannelID)
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	// Would that ever happen?
 	if s.Command != "/news" {
-		log.Println("unexpected slash command:", s.Command)
+		b.logger.Warn("unexpected slash command", "command", s.Command)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -52,87 +366,302 @@ func (b *Bot) HandleSlashCommand(w http.ResponseWriter, r *http.Request) {
 	// return 200 immediately to tell slack the payload was received.
 	// command will be processed async
 	w.WriteHeader(http.StatusOK)
-	go b.processCommand(s.ChannelID, s.ResponseURL, strings.ToLower(s.Text))
+	req := commandRequest{
+		id:           newCorrelationID(),
+		teamID:       s.TeamID,
+		channelID:    s.ChannelID,
+		userID:       s.UserID,
+		responseURL:  s.ResponseURL,
+		responseType: defaultResponseType(s.ChannelID),
+	}
+	b.runTask(func(ctx context.Context) { b.processCommand(ctx, req, s.Text) })
 }
 
-func (b *Bot) processCommand(channelID string, responseURL string, params string) {
-	// create new context not attached to the request, since this method is called async
-	ctx := context.Background()
+// processCommand handles a command in the background. The context isn't attached to the request,
+// which is answered before the command is handled, and the command times out after commandTimeout.
+func (b *Bot) processCommand(ctx context.Context, req commandRequest, text string) {
+	ctx, cancel := context.WithTimeout(ctx, b.commandTimeout)
+	defer cancel()
+
+	req.result = &commandResult{status: statusOK}
+	defer b.audit(req, "/news "+text)
+
+	if message, ok := b.maintenance.active(); ok {
+		b.postNotice(ctx, req, statusRejected, message)
+		return
+	}
+
+	// visibility flags apply to every subcommand
+	public, text := extractFlag(text, "--public")
+	private, text := extractFlag(text, "--private")
+	switch {
+	case public:
+		req.responseType = slack.ResponseTypeInChannel
+	case private:
+		req.responseType = slack.ResponseTypeEphemeral
+	}
+
+	// admins can override the feature flags for a single request to try a gated feature
+	overrides, unknownFeatures, text := extractFeatureOverrides(text)
+	req.features = b.features
+	switch {
+	case len(overrides) == 0 && len(unknownFeatures) == 0:
+	case !b.isAdmin(req.userID):
+		b.postResponse(ctx, req.ephemeral(), slack.MsgOptionText("ℹ️ Feature overrides are only available to admins, so they were ignored.", false))
+	default:
+		req.features = b.features.with(overrides)
+		if len(unknownFeatures) > 0 {
+			b.postResponse(ctx, req.ephemeral(), slack.MsgOptionText(fmt.Sprintf("ℹ️ Ignored the unknown features `%s`, the features are `%s`.",
+				strings.Join(unknownFeatures, "`, `"), strings.Join(knownFeatures, "`, `")), false))
+		}
+	}
+
+	params := strings.ToLower(text)
+	command := commandName(params)
+	defer func(start time.Time) {
+		b.prom.observeCommand(command, time.Since(start))
+	}(time.Now())
+
+	if feature, ok := commandFeatures[command]; ok && !req.features.enabled(feature) {
+		b.postNotice(ctx, req, statusRejected, featureDisabledMessage)
+		return
+	}
+
+	// throttle the commands posting to the whole channel, the help, the sections and the private
+	// responses don't spam anyone. The briefing is always posted to the channel.
+	if (command == "briefing" || command != "help" && command != "sections" && req.visibleToChannel()) && !b.tryCooldown(ctx, req) {
+		return
+	}
+
+	// the quota is only charged for the commands about to hit the news source, the help and the
+	// list of sections are free
+	if command != "help" && command != "sections" && !b.tryQuota(ctx, req) {
+		return
+	}
+
 	switch {
 	case strings.HasPrefix(params, "stories"):
-		b.handleTopRequest(ctx, channelID, responseURL, params[7:])
+		b.handleTopRequest(ctx, req, params[7:])
+		return
+	case strings.HasPrefix(params, "briefing"):
+		b.handleBriefingRequest(ctx, req, params[8:])
+		return
+	case strings.HasPrefix(params, "breaking"):
+		b.handleBreakingRequest(ctx, req)
+		return
+	case strings.HasPrefix(params, "sections"):
+		b.handleSectionsRequest(ctx, req.ephemeral())
+		return
+	case strings.HasPrefix(params, "search"):
+		// keep the original case of the query
+		b.handleSearchRequest(ctx, req, text[6:])
+		return
+	case strings.HasPrefix(params, "popular"):
+		b.handlePopularRequest(ctx, req, params[7:])
+		return
+	case strings.HasPrefix(params, "onthisday"):
+		b.handleOnThisDayRequest(ctx, req, b.now())
+		return
+	case strings.HasPrefix(params, "author"):
+		// keep the original case of the author's name
+		b.handleAuthorRequest(ctx, req, text[6:])
 		return
 	default:
-		b.handleHelpRequest(ctx, channelID, responseURL)
+		b.handleHelpRequest(ctx, req.ephemeral())
 		return
 	}
 }
 
-func (b *Bot) handleTopRequest(ctx context.Context, channelID string, responseURL string, params string) {
-	params = strings.Trim(params, " ")
-	if len(params) == 0 {
-		// if no category is passed we default to top stories on the homepage
-		params = "home"
+// commands lists the subcommands of /news, any other text shows the help
+var commands = []string{"stories", "briefing", "breaking", "popular", "onthisday", "author", "search", "sections"}
+
+// commandFeatures are the gated features of the subcommands
+var commandFeatures = map[string]string{
+	"briefing": featureBriefing,
+	"popular":  featurePopular,
+	"author":   featureAuthor,
+}
+
+// commandName returns the subcommand of the command params, or 'help' when there is none
+func commandName(params string) string {
+	for _, command := range commands {
+		if strings.HasPrefix(params, command) {
+			return command
+		}
 	}
+	return "help"
+}
 
-	// Fetch top 3 stories
-	articles, err := b.newsSource.TopStories(ctx, params, 3)
-	if err != nil {
-		log.Println("error requesting top stories:", err)
+func (b *Bot) handleTopRequest(ctx context.Context, req commandRequest, params string) {
+	opts, params := parseRenderOptions(params, b.renderDefaults)
+	popular, params := extractFlag(params, "--popular")
+	lang, params := extractFlagValue(params, "--lang")
+	count, found, params := extractCount(params)
+	if found && count <= 0 {
+		b.logger.Debug("ignoring the count, showing the default number of stories", "correlation_id", req.id, "count", count)
+	}
+	topN, clamped := b.storyCount(count)
+	if clamped {
+		opts.Notes = append(opts.Notes, fmt.Sprintf("ℹ️ Showing %d stories, the most we can show at once.", topN))
+	}
 
-		errMessage := "⚠️ Oops, something went wrong on our side. Try again later!"
-		if err == ErrInvalidSection {
-			errMessage = "⚠️ That's not a valid news section! Try requesting `/news help` to learn how to use this app!"
+	if len(b.newsSource.SupportedSections()) == 0 {
+		b.postNotice(ctx, req, statusError, sectionsUnavailableMessage)
+		return
+	}
+
+	sections, duplicates := b.resolveSections(parseSections(params))
+	if len(sections) == 0 {
+		// if no category is passed we default to top stories on the homepage
+		sections = []string{defaultSection}
+	}
+	if len(sections) > 1 {
+		if popular || lang != "" {
+			b.postNotice(ctx, req, statusRejected, multiSectionFlagsMessage)
+			return
 		}
+		b.handleMultiSectionRequest(ctx, req, sections, duplicates, topN, opts)
+		return
+	}
+	params = sections[0]
 
-		if _, _, err := b.slackClient.PostMessage(
-			channelID,
-			slack.MsgOptionResponseURL(responseURL, slack.ResponseTypeEphemeral),
-			slack.MsgOptionText(errMessage, true),
-		); err != nil {
-			log.Println("error sending message:", err)
+	if !b.isSupportedSection(params) {
+		b.prom.observeStories(params, outcomeInvalidSection)
+		b.postNotice(ctx, req, statusRejected, b.invalidSectionMessage(params))
+		return
+	}
+	if (popular && !req.features.enabled(featurePopular)) || (lang != "" && !req.features.enabled(featureLang)) {
+		b.postNotice(ctx, req, statusRejected, featureDisabledMessage)
+		return
+	}
+
+	var articles []Article
+	var err error
+	switch {
+	case lang != "" && lang != "en":
+		articles, err = b.newsSource.LocalizedStories(ctx, params, lang, topN)
+		if errors.Is(err, ErrLanguageUnavailable) {
+			// fall back to the english stories, letting the user know
+			opts.Notes = append(opts.Notes, fmt.Sprintf("ℹ️ %s content isn't available for the %s section, showing it in English.",
+				languageName(lang), b.newsSource.UserFriendlySection(params)))
+			articles, err = b.newsSource.TopStories(ctx, params, topN)
 		}
+	case popular:
+		articles, err = b.popularTopStories(ctx, params, topN)
+	default:
+		articles, err = b.newsSource.TopStories(ctx, params, topN)
+	}
+	b.metrics.recordRequest(params, err)
+	if err != nil {
+		b.prom.observeStories(params, outcomeError)
+		b.logger.Error("error requesting top stories", "correlation_id", req.id, "channel_id", req.channelID, "section", params, "error", err)
+		b.postNotice(ctx, req, newsErrorStatus(err), newsErrorMessage(err))
+		return
+	}
+	b.prom.observeStories(params, outcomeSuccess)
 
+	// a valid section may legitimately have no stories at a given time
+	if len(articles) == 0 {
+		message := fmt.Sprintf("The %s section has no top stories right now — check back later.", b.newsSource.UserFriendlySection(params))
+		b.postNotice(ctx, req, statusEmpty, message)
 		return
 	}
 
 	// build Block message and replace response
-	var message slack.Blocks
-	message.BlockSet = append(
-		message.BlockSet,
-		slack.NewHeaderBlock(&slack.TextBlockObject{
-			Type: "plain_text",
-			Text: "📢 Here are the top stories 📢",
-		}),
-	)
+	opts.QuickReplies = b.quickReplies(params)
+	b.postResponse(ctx, req, b.render(ctx, articles, opts))
+}
 
-	for _, a := range articles {
-		message.BlockSet = append(
-			message.BlockSet,
-			slack.NewSectionBlock(&slack.TextBlockObject{
-				Type: "mrkdwn",
-				Text: fmt.Sprintf("*<%s|%s>*\n%s", a.URL, a.Title, a.Abstract),
-			}, nil, nil),
-			slack.NewContextBlock("", slack.TextBlockObject{
-				Type: "plain_text",
-				Text: a.PublishedAt,
-			}),
-			slack.NewDividerBlock(),
-		)
+// storyCount returns the number of stories to show for the requested count, using the default
+// when none or a non-positive count was requested. The count is clamped to what we can show and
+// what the news source can return, in which case clamped is true.
+func (b *Bot) storyCount(requested int) (count int, clamped bool) {
+	max := min(maxStoryCount, b.newsSource.MaxStories())
+	if requested <= 0 {
+		return min(defaultStoryCount, max), false
+	}
+	if requested > max {
+		return max, true
+	}
+	return requested, false
+}
+
+// handleAuthorRequest searches for the most recent articles written by an author
+func (b *Bot) handleAuthorRequest(ctx context.Context, req commandRequest, params string) {
+	author, err := sanitizeSearchQuery(unquote(params))
+	if err != nil {
+		b.postNotice(ctx, req, statusRejected, "⚠️ Tell us who to look for, e.g. `/news author \"Paul Krugman\"`")
+		return
+	}
+
+	articles, err := b.newsSource.SearchByAuthor(ctx, author, 3)
+	if err != nil {
+		b.logger.Error("error searching articles by author", "correlation_id", req.id, "channel_id", req.channelID, "author", author, "error", err)
+		b.postNotice(ctx, req, newsErrorStatus(err), newsErrorMessage(err))
+		return
 	}
 
-	if _, _, err := b.slackClient.PostMessage(channelID,
-		slack.MsgOptionBlocks(message.BlockSet...),
-		slack.MsgOptionResponseURL(responseURL, slack.ResponseTypeEphemeral),
-	); err != nil {
-		log.Println("error sending message:", err)
+	if len(articles) == 0 {
+		b.postNotice(ctx, req, statusEmpty, fmt.Sprintf("We couldn't find any recent articles by %s.", author))
+		return
 	}
 
+	opts := b.renderDefaults
+	opts.Header = fmt.Sprintf("✍️ Latest stories by %s", author)
+	b.postResponse(ctx, req, b.render(ctx, articles, opts))
+}
+
+// handleSearchRequest searches for the most recent articles about a topic
+func (b *Bot) handleSearchRequest(ctx context.Context, req commandRequest, params string) {
+	query, err := sanitizeSearchQuery(unquote(params))
+	if err != nil {
+		b.postNotice(ctx, req, statusRejected, "⚠️ Tell us what to look for, e.g. `/news search climate policy`")
+		return
+	}
+
+	articles, err := b.newsSource.SearchArticles(ctx, query, defaultStoryCount)
+	b.metrics.recordRequest("search", err)
+	if err != nil {
+		b.logger.Error("error searching articles", "correlation_id", req.id, "channel_id", req.channelID, "query", query, "error", err)
+		b.postNotice(ctx, req, newsErrorStatus(err), newsErrorMessage(err))
+		return
+	}
+
+	if len(articles) == 0 {
+		b.postNotice(ctx, req, statusEmpty, fmt.Sprintf("No articles found for %s.", query))
+		return
+	}
+
+	opts := b.renderDefaults
+	opts.Header = fmt.Sprintf("🔎 Latest stories about %s", query)
+	b.postResponse(ctx, req, b.render(ctx, articles, opts))
+}
+
+// popularTopStories returns the top N stories of a section, ranked by how much they are being read.
+// If the popularity data is unavailable we fall back to the section's original order.
+func (b *Bot) popularTopStories(ctx context.Context, section string, topN int) ([]Article, error) {
+	// fetch every story of the section so the most read ones can surface to the top
+	articles, err := b.newsSource.TopStories(ctx, section, popularityCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	popular, err := b.newsSource.PopularStories(ctx, "viewed", 1)
+	if err != nil {
+		b.logger.Warn("error requesting popular stories, keeping original order", "section", section, "error", err)
+	} else {
+		articles = rankByPopularity(articles, popular)
+	}
+
+	if topN < len(articles) {
+		articles = articles[:topN]
+	}
+	return articles, nil
 }
 
 // handleHelpRequest returns a Slack Block Kit structure that renders an interactive 'help' view
 // every time an incorrect slash command is sent
-func (b *Bot) handleHelpRequest(ctx context.Context, channelID string, responseURL string) {
+func (b *Bot) handleHelpRequest(ctx context.Context, req commandRequest) {
 	var message slack.Blocks
 	message.BlockSet = append(message.BlockSet,
 		slack.NewHeaderBlock(&slack.TextBlockObject{
@@ -140,31 +669,161 @@ func (b *Bot) handleHelpRequest(ctx context.Context, channelID string, responseU
 			Text: "See what's happening in the world 🗣",
 		}),
 		slack.NewDividerBlock(),
-		slack.NewSectionBlock(
-			&slack.TextBlockObject{
-				Type: "mrkdwn",
-				Text: "💡 Choose the news section you're interested in:",
-			},
-			nil,
-			&slack.Accessory{
-				SelectElement: &slack.SelectBlockElement{
-					Type:    "static_select",
-					Options: b.addNewsSectionsOptions(),
-				},
-			},
-		),
+		b.sectionPicker(),
+	)
+	if tip := b.randomTip(); tip != "" {
+		message.BlockSet = append(message.BlockSet, slack.NewContextBlock("", slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: "💡 Tip: " + tip,
+		}))
+	}
+
+	b.postResponse(ctx, req, slack.MsgOptionBlocks(message.BlockSet...))
+}
+
+// handleSectionsRequest lists the supported sections with the name to request them by
+func (b *Bot) handleSectionsRequest(ctx context.Context, req commandRequest) {
+	sections := b.newsSource.SupportedSections()
+	if len(sections) == 0 {
+		b.postNotice(ctx, req, statusError, sectionsUnavailableMessage)
+		return
+	}
+
+	lines := []string{"*Here are the sections you can ask for:*"}
+	for _, section := range sections {
+		lines = append(lines, fmt.Sprintf("• %s: `/news stories %s`", b.newsSource.UserFriendlySection(section), section))
+	}
+	b.postResponse(ctx, req, slack.MsgOptionBlocks(slack.NewSectionBlock(&slack.TextBlockObject{
+		Type: "mrkdwn",
+		Text: truncateText(strings.Join(lines, "\n"), maxSectionTextLength),
+	}, nil, nil)))
+}
+
+// isAdmin checks whether a user is one of the configured admins
+func (b *Bot) isAdmin(userID string) bool {
+	for _, id := range b.adminUserIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// randomTip picks one of the configured tips, or returns an empty string if there are none
+func (b *Bot) randomTip() string {
+	if len(b.tips) == 0 {
+		return ""
+	}
+	return b.tips[b.randomInt(len(b.tips))]
+}
+
+// randomInt returns a random number in [0, n)
+func (b *Bot) randomInt(n int) int {
+	b.rngMu.Lock()
+	defer b.rngMu.Unlock()
+	return b.rng.Intn(n)
+}
+
+// sectionPickerBlockID and sectionSelectActionID identify the section picker of the help view in
+// the state of its interactions
+const (
+	sectionPickerBlockID  = "section_picker"
+	sectionSelectActionID = "section_select"
+)
+
+// sectionPicker builds the section block with the dropdown menu used to pick a news section.
+// Slack rejects a dropdown with no options, so if the news source has no sections available
+// we explain it instead.
+func (b *Bot) sectionPicker() slack.Block {
+	if len(b.newsSource.SupportedSections()) == 0 {
+		return slack.NewSectionBlock(&slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: sectionsUnavailableMessage,
+		}, nil, nil)
+	}
+
+	return slack.NewSectionBlock(
+		&slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: "💡 Choose the news section you're interested in:",
+		},
+		nil,
+		&slack.Accessory{SelectElement: b.sectionSelect()},
+		slack.SectionBlockOptionBlockID(sectionPickerBlockID),
 	)
+}
 
-	if _, _, err := b.slackClient.PostMessage(channelID,
-		slack.MsgOptionBlocks(message.BlockSet...),
-		slack.MsgOptionResponseURL(responseURL, slack.ResponseTypeEphemeral),
-	); err != nil {
-		log.Println("error sending message:", err)
+// resolveSections resolves the aliases and typos of the requested sections, see resolveSection.
+// The unknown sections are kept as typed, so the user is told about them. It reports whether
+// some sections were requested twice.
+func (b *Bot) resolveSections(sections []string, duplicates bool) ([]string, bool) {
+	supported := b.newsSource.SupportedSections()
+	var resolved []string
+	seen := map[string]bool{}
+	for _, section := range sections {
+		if s, ok := resolveSection(section, supported); ok {
+			section = s
+		}
+		if seen[section] {
+			duplicates = true
+			continue
+		}
+		seen[section] = true
+		resolved = append(resolved, section)
 	}
+	return resolved, duplicates
 }
 
+// invalidSectionMessage explains why a section isn't supported. A supported section followed by
+// a word, e.g. 'politics abc', is taken as a count that isn't a number.
+func (b *Bot) invalidSectionMessage(section string) string {
+	fields := strings.Fields(section)
+	if len(fields) > 1 && b.isSupportedSection(strings.Join(fields[:len(fields)-1], " ")) {
+		return invalidCountMessage
+	}
+	return invalidSectionMessage
+}
+
+// isSupportedSection checks the section is one of the news source's supported sections.
+// When no sections are available we can't tell, so the section is not considered supported.
+func (b *Bot) isSupportedSection(section string) bool {
+	for _, s := range b.newsSource.SupportedSections() {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionSelect builds the dropdown menu used to pick a news section. It starts on the default
+// section, or on the first one when the default isn't offered, since slack rejects an initial
+// option that isn't one of the options.
+func (b *Bot) sectionSelect() *slack.SelectBlockElement {
+	options := b.addNewsSectionsOptions()
+	var initial *slack.OptionBlockObject
+	for _, option := range options {
+		if option.Value == defaultSection {
+			initial = option
+			break
+		}
+	}
+	if initial == nil && len(options) > 0 {
+		initial = options[0]
+	}
+	return &slack.SelectBlockElement{
+		Type:          "static_select",
+		ActionID:      sectionSelectActionID,
+		Options:       options,
+		InitialOption: initial,
+	}
+}
+
+// maxSelectOptions is the most options slack accepts in a static select
+const maxSelectOptions = 100
+
 // addNewsSectionsOptions loops through the available news sections a user can request
-// and builds the appropriate options block object
+// and builds the appropriate options block object. The options are sorted by name for a stable
+// UI, after the default section.
 func (b *Bot) addNewsSectionsOptions() []*slack.OptionBlockObject {
 	var response []*slack.OptionBlockObject
 	for _, section := range b.newsSource.SupportedSections() {
@@ -176,42 +835,160 @@ func (b *Bot) addNewsSectionsOptions() []*slack.OptionBlockObject {
 			Value: section,
 		})
 	}
+	sort.SliceStable(response, func(i, j int) bool {
+		if response[i].Value == defaultSection || response[j].Value == defaultSection {
+			return response[i].Value == defaultSection && response[j].Value != defaultSection
+		}
+		if response[i].Text.Text != response[j].Text.Text {
+			return response[i].Text.Text < response[j].Text.Text
+		}
+		return response[i].Value < response[j].Value
+	})
+	if len(response) > maxSelectOptions {
+		response = response[:maxSelectOptions]
+	}
 	return response
 }
 
 // HandleHelpInteraction handles a request coming from a 'help' view interaction
-// It expects a slack interaction payload of type 'block_actions' containing the user's
-// input (https://api.slack.com/reference/interaction-payloads/block-actions)
+// Payloads of type 'block_actions' contain the user's input
+// (https://api.slack.com/reference/interaction-payloads/block-actions), while modal
+// 'view_submission' and 'view_closed' payloads are only acknowledged.
 func (b *Bot) HandleHelpInteraction(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		log.Println("error parsing interactive request:", err)
+	verified, err := b.verifySignature(r)
+	if err != nil {
+		b.logger.Warn("invalid request signature", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	interaction, err := parseInteraction(r)
+	if err != nil {
+		b.logger.Error("error parsing interactive request", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	if !verified && interaction.Token != b.slackVerificationToken {
+		b.logger.Warn("invalid token", "team_id", interaction.Team.ID, "channel_id", interaction.Channel.ID)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch interaction.Type {
+	case slack.InteractionTypeBlockActions:
+		b.handleBlockActions(w, interaction)
+	case slack.InteractionTypeViewSubmission:
+		// an empty 200 response tells slack to close the modal
+		w.WriteHeader(http.StatusOK)
+	case slack.InteractionTypeViewClosed:
+		// nothing to do when the user dismisses a modal, just acknowledge it
+		w.WriteHeader(http.StatusOK)
+	default:
+		// acknowledge anyway so slack doesn't retry the payload
+		b.logger.Warn("unexpected interaction type", "type", interaction.Type)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// parseInteraction reads the interaction callback from the request body, which is either a form
+// with a 'payload' field holding the JSON document or the raw JSON document itself
+func parseInteraction(r *http.Request) (slack.InteractionCallback, error) {
+	var interaction slack.InteractionCallback
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		err := json.NewDecoder(r.Body).Decode(&interaction)
+		return interaction, err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return interaction, err
+	}
+
 	// NOTE: looks like this slack library has different implementation styles for interactive requests than
 	// it does for slash commands (see handler above). I would probably revisit using this library again and
 	// look for another more consistent one.
 	//
 	// We need to retrieve the 'payload' field and unmarshal in an InteractiveCallback object.
 	payload := r.PostForm.Get("payload")
-	var interaction slack.InteractionCallback
-	if err := json.Unmarshal([]byte(payload), &interaction); err != nil {
-		log.Println("error parsing interactive request:", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	if payload == "" {
+		return interaction, errors.New("missing payload field")
 	}
+	err := json.Unmarshal([]byte(payload), &interaction)
+	return interaction, err
+}
 
-	// TODO: validate request with signing secret instead
-	if interaction.Token != b.slackVerificationToken {
-		log.Println("invalid token")
-		w.WriteHeader(http.StatusUnauthorized)
+// container is where an interaction happened
+type container struct {
+	channelID string
+	// messageTS is the message holding the interactive elements, and messageVisible tells
+	// whether everyone in the channel sees it. Interactions in views have no message.
+	messageTS      string
+	messageVisible bool
+}
+
+// interactionContainer extracts the container of an interaction. Message containers carry their
+// channel, while views (e.g. a modal or the app home) only have the channel the view was opened
+// from, if any. Without a channel the response goes through the response URL or to the user.
+func interactionContainer(interaction slack.InteractionCallback) container {
+	c := container{channelID: interaction.Container.ChannelID}
+	if c.channelID == "" {
+		c.channelID = interaction.Channel.ID
+	}
+	switch interaction.Container.Type {
+	case "message", "message_attachment":
+		c.messageTS = interaction.Container.MessageTs
+		c.messageVisible = !interaction.Container.IsEphemeral
+	}
+	return c
+}
+
+// helpInput extracts the requested section from a 'block_actions' interaction. A pressed quick
+// reply wins, otherwise the section is read from the state of the section picker, which holds the
+// current value of every input of the view. The selected options of the actions are a fallback
+// for help messages posted before the picker had its IDs. Unknown actions are ignored.
+func helpInput(interaction slack.InteractionCallback) (section string, quickReply bool, ok bool) {
+	for _, action := range interaction.ActionCallback.BlockActions {
+		if action.BlockID == quickRepliesBlockID && action.Value != "" {
+			return action.Value, true, true
+		}
+	}
+	if state := interaction.BlockActionState; state != nil {
+		if action, found := state.Values[sectionPickerBlockID][sectionSelectActionID]; found && action.SelectedOption.Value != "" {
+			return action.SelectedOption.Value, false, true
+		}
+	}
+	for _, action := range interaction.ActionCallback.BlockActions {
+		if action.Type == "static_select" && action.SelectedOption.Value != "" {
+			return action.SelectedOption.Value, false, true
+		}
+	}
+	return "", false, false
+}
+
+// isReadMore tells whether the interaction only comes from 'Read more' buttons, which need nothing
+// but an acknowledgement
+func isReadMore(interaction slack.InteractionCallback) bool {
+	actions := interaction.ActionCallback.BlockActions
+	for _, action := range actions {
+		if action.ActionID != readMoreActionID {
+			return false
+		}
+	}
+	return len(actions) > 0
+}
+
+// handleBlockActions handles a 'block_actions' interaction coming from the 'help' view
+func (b *Bot) handleBlockActions(w http.ResponseWriter, interaction slack.InteractionCallback) {
+	if isReadMore(interaction) {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Check we have exactly one action coming in
-	if len(interaction.ActionCallback.BlockActions) != 1 {
-		log.Println("unexpected amount of actions received")
+	section, quickReply, ok := helpInput(interaction)
+	if !ok {
+		b.logger.Warn("no known input in the actions received", "actions", len(interaction.ActionCallback.BlockActions))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -220,6 +997,335 @@ func (b *Bot) HandleHelpInteraction(w http.ResponseWriter, r *http.Request) {
 	// command will be processed async
 	w.WriteHeader(http.StatusOK)
 
-	action := interaction.ActionCallback.BlockActions[0]
-	go b.handleTopRequest(context.Background(), interaction.Container.ChannelID, interaction.ResponseURL, action.SelectedOption.Value)
+	container := interactionContainer(interaction)
+	req := commandRequest{
+		id:           newCorrelationID(),
+		teamID:       interaction.Team.ID,
+		channelID:    container.channelID,
+		userID:       interaction.User.ID,
+		responseURL:  interaction.ResponseURL,
+		responseType: defaultResponseType(container.channelID),
+		features:     b.features,
+		// replace the help message, so the stale selects don't pile up in the channel
+		messageTS:      container.messageTS,
+		messageVisible: container.messageVisible,
+		result:         &commandResult{status: statusOK},
+	}
+	b.logger.Debug("received block actions", "correlation_id", req.id, "container_type", interaction.Container.Type, "channel_id", req.channelID)
+	command := "help view: " + section
+	if quickReply {
+		// a quick reply posts the new section below the stories, which stay in the channel
+		command = "quick reply: " + section
+		req.messageTS = ""
+		req.messageVisible = false
+	}
+	b.runTask(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, b.commandTimeout)
+		defer cancel()
+		defer b.audit(req, command)
+		if message, ok := b.maintenance.active(); ok {
+			b.postNotice(ctx, req, statusRejected, message)
+			return
+		}
+		if req.visibleToChannel() && !b.tryCooldown(ctx, req) {
+			return
+		}
+		if !b.tryQuota(ctx, req) {
+			return
+		}
+		b.handleTopRequest(ctx, req, section)
+	})
+}
+
+// postResponse posts a message through the slack response URL of a request.
+// Since the response URL comes from the request payload, we refuse to post to any host
+// outside of the allowlist to avoid sending data to a spoofed URL.
+func (b *Bot) postResponse(ctx context.Context, req commandRequest, options ...slack.MsgOption) {
+	// interactions from the app home have no response URL, post to the user directly
+	if req.responseURL == "" {
+		if _, _, err := b.postToChannel(ctx, req, options...); err != nil {
+			b.logger.Error("error sending message", "correlation_id", req.id, "channel_id", req.channelID, "error", err)
+			req.setStatus(statusError)
+		}
+		return
+	}
+
+	if !b.isAllowedResponseURL(req.responseURL) {
+		b.logger.Warn("refusing to post to untrusted response url", "correlation_id", req.id, "channel_id", req.channelID, "response_url", req.responseURL)
+		req.setStatus(statusRejected)
+		return
+	}
+
+	// the response URL identifies the conversation, so the channel ID isn't checked
+	err := b.postToResponseURL(ctx, req, options...)
+	if err == nil {
+		return
+	}
+	if req.messageTS == "" && !isNetworkError(err) {
+		b.logger.Error("error sending message", "correlation_id", req.id, "channel_id", req.channelID, "error", err)
+		req.setStatus(statusError)
+		return
+	}
+
+	// the response URL may be unreachable, or have expired for an old message: answer in the
+	// channel instead
+	b.logger.Warn("error posting to the response url, posting to the channel instead", "correlation_id", req.id, "channel_id", req.channelID, "error", err)
+	if err := b.postToContainer(ctx, req, options...); err != nil {
+		b.logger.Error("error sending message", "correlation_id", req.id, "channel_id", req.channelID, "error", err)
+		req.setStatus(statusError)
+	}
+}
+
+// isNetworkError reports whether err is a failure to reach slack, e.g. a TLS or connection error,
+// rather than an error returned by the slack API
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// tryCooldown records a command posting to the channel of the request. When the channel is cooling
+// down, it tells the user and returns false.
+func (b *Bot) tryCooldown(ctx context.Context, req commandRequest) bool {
+	ok, wait := b.cooldown.try(req.channelID)
+	if !ok {
+		seconds := int(math.Ceil(wait.Seconds()))
+		b.postNotice(ctx, req, statusRejected, fmt.Sprintf("⏳ This channel was updated recently, try again in %ds.", seconds))
+	}
+	return ok
+}
+
+// tryQuota counts a request in the daily quota of its user. When the user reached the limit, it
+// tells them and returns false.
+func (b *Bot) tryQuota(ctx context.Context, req commandRequest) bool {
+	if b.quota.try(req.userID) {
+		return true
+	}
+	b.postNotice(ctx, req, statusRejected, fmt.Sprintf("⏳ You've reached your daily limit of %d requests.", b.quota.limit))
+	return false
+}
+
+// postNotice posts a text response only visible to the user, e.g. to report an error, and records
+// the status of the command
+func (b *Bot) postNotice(ctx context.Context, req commandRequest, status string, message string) {
+	req.setStatus(status)
+	b.postResponse(ctx, req.ephemeral(), slack.MsgOptionText(message, false))
+}
+
+// audit records the completed command with its status
+func (b *Bot) audit(req commandRequest, command string) {
+	status := statusOK
+	if req.result != nil {
+		status = req.result.status
+	}
+	b.auditor.record(auditEntry{
+		Time:          b.now(),
+		CorrelationID: req.id,
+		TeamID:        req.teamID,
+		ChannelID:     req.channelID,
+		UserID:        req.userID,
+		Command:       command,
+		Status:        status,
+	})
+}
+
+// postToContainer responds to a request in its channel, without the response URL. The visible
+// message of an interaction is replaced, while ephemeral ones can't be and get a new ephemeral reply.
+func (b *Bot) postToContainer(ctx context.Context, req commandRequest, options ...slack.MsgOption) error {
+	if req.messageVisible {
+		_, _, err := b.postMessage(ctx, req.id, req.teamID, req.channelID, append(options, slack.MsgOptionUpdate(req.messageTS))...)
+		if err == nil {
+			return nil
+		}
+		b.logger.Warn("error replacing the original message, posting a new one", "correlation_id", req.id, "channel_id", req.channelID, "error", err)
+	}
+	if req.responseType == slack.ResponseTypeEphemeral && req.userID != "" {
+		_, _, err := b.postMessage(ctx, req.id, req.teamID, req.channelID, append(options, slack.MsgOptionPostEphemeral(req.userID))...)
+		return err
+	}
+	_, _, err := b.postToChannel(ctx, req, options...)
+	return err
+}
+
+// postToChannel posts a message directly to the channel of a request. In some DMs we only get
+// the ID of the user, so when slack can't find the channel, or the request has no valid one, we
+// open a DM with the user instead.
+// It returns the channel the message was posted to and its timestamp.
+func (b *Bot) postToChannel(ctx context.Context, req commandRequest, options ...slack.MsgOption) (string, string, error) {
+	if channelIDPattern.MatchString(req.channelID) || req.userID == "" {
+		channel, ts, err := b.postMessage(ctx, req.id, req.teamID, req.channelID, options...)
+		if !isSlackError(err, "channel_not_found") || req.userID == "" {
+			return channel, ts, err
+		}
+	}
+
+	client, err := b.slackClients.get(req.teamID)
+	if err != nil {
+		return "", "", err
+	}
+	dm, _, _, err := client.OpenConversation(&slack.OpenConversationParameters{Users: []string{req.userID}})
+	if err != nil {
+		return "", "", fmt.Errorf("error opening DM with user %s: %w", req.userID, err)
+	}
+	return b.postMessage(ctx, req.id, req.teamID, dm.ID, options...)
+}
+
+// errInvalidChannelID is returned when posting to a channel ID slack would reject
+var errInvalidChannelID = errors.New("invalid channel id")
+
+// channelIDPattern matches the IDs of public channels (C), private channels (G) and DMs (D)
+var channelIDPattern = regexp.MustCompile(`^[CGD][A-Z0-9]{6,}$`)
+
+// postMessage posts a message to a channel, refusing malformed channel IDs instead of sending
+// them to slack, since its error wouldn't tell what went wrong
+func (b *Bot) postMessage(ctx context.Context, correlationID string, teamID string, channelID string, options ...slack.MsgOption) (string, string, error) {
+	if !channelIDPattern.MatchString(channelID) {
+		b.logger.Warn("not posting to malformed channel id", "correlation_id", correlationID, "channel_id", channelID)
+		return "", "", fmt.Errorf("%w %q", errInvalidChannelID, channelID)
+	}
+	return b.sendMessage(ctx, correlationID, teamID, channelID, options...)
+}
+
+// sendMessage posts a message with the client of the given workspace. If the workspace token
+// was revoked or rotated, the token is read again from the store and the post retried once.
+// The correlation ID ties the debug logs of the message to the request that triggered it.
+// At most maxConcurrentPosts messages are posted at once, the others wait for their turn until
+// ctx is done.
+func (b *Bot) sendMessage(ctx context.Context, correlationID string, teamID string, channelID string, options ...slack.MsgOption) (string, string, error) {
+	client, err := b.slackClients.get(teamID)
+	if err != nil {
+		return "", "", err
+	}
+
+	b.logOutgoingMessage(correlationID, channelID, options...)
+
+	if err := b.acquirePostSlot(ctx); err != nil {
+		return "", "", err
+	}
+	defer func() { <-b.postSlots }()
+
+	channel, ts, err := client.PostMessage(channelID, options...)
+	if !isSlackError(err, "token_revoked", "invalid_auth") {
+		return channel, ts, err
+	}
+
+	b.logger.Warn("slack token was rejected, refreshing it", "team_id", teamID, "error", err)
+	if client, err = b.slackClients.refresh(teamID); err != nil {
+		return "", "", err
+	}
+	channel, ts, err = client.PostMessage(channelID, options...)
+	if isSlackError(err, "token_revoked", "invalid_auth") {
+		b.logger.Error("slack token is no longer valid and needs to be replaced", "team_id", teamID, "error", err)
+		b.slackClients.tokens.MarkNeedsReauth(teamID)
+	}
+	return channel, ts, err
+}
+
+// acquirePostSlot waits for a free post slot, release it by reading from postSlots. A free slot
+// is taken even when ctx is done, so the error notice of a command that timed out is still posted.
+func (b *Bot) acquirePostSlot(ctx context.Context) error {
+	select {
+	case b.postSlots <- struct{}{}:
+		return nil
+	default:
+	}
+	select {
+	case b.postSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// responseURLMessage is the payload posted to a response URL. slack-go drops the unfurl settings
+// of the messages it sends to a response URL, so we encode them ourselves.
+type responseURLMessage struct {
+	Text            string          `json:"text,omitempty"`
+	Blocks          json.RawMessage `json:"blocks,omitempty"`
+	Attachments     json.RawMessage `json:"attachments,omitempty"`
+	ResponseType    string          `json:"response_type,omitempty"`
+	ReplaceOriginal bool            `json:"replace_original"`
+	UnfurlLinks     *bool           `json:"unfurl_links,omitempty"`
+	UnfurlMedia     *bool           `json:"unfurl_media,omitempty"`
+}
+
+// postToResponseURL posts a message to the response URL of the request, replacing the message
+// the request comes from when it is an interaction
+func (b *Bot) postToResponseURL(ctx context.Context, req commandRequest, options ...slack.MsgOption) error {
+	b.logOutgoingMessage(req.id, req.channelID, options...)
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", req.channelID, "", options...)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(responseURLMessage{
+		Text:            values.Get("text"),
+		Blocks:          rawJSONValue(values, "blocks"),
+		Attachments:     rawJSONValue(values, "attachments"),
+		ResponseType:    req.responseType,
+		ReplaceOriginal: req.messageTS != "",
+		UnfurlLinks:     boolValue(values, "unfurl_links"),
+		UnfurlMedia:     boolValue(values, "unfurl_media"),
+	})
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, req.responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if err := b.acquirePostSlot(ctx); err != nil {
+		return err
+	}
+	defer func() { <-b.postSlots }()
+
+	resp, err := b.slackClients.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("posting to the response url: %s", resp.Status)
+	}
+	// the response URL answers "ok" as plain text, except for some errors reported as JSON
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json" {
+		var result slack.SlackResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return err
+		}
+		return result.Err()
+	}
+	return nil
+}
+
+// rawJSONValue returns a message value already encoded as JSON by slack-go, or nil when unset
+func rawJSONValue(values url.Values, key string) json.RawMessage {
+	if value := values.Get(key); value != "" {
+		return json.RawMessage(value)
+	}
+	return nil
+}
+
+// boolValue returns a boolean message value, or nil when unset
+func boolValue(values url.Values, key string) *bool {
+	value, err := strconv.ParseBool(values.Get(key))
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// isAllowedResponseURL checks the response URL is an https URL pointing to an allowed host
+func (b *Bot) isAllowedResponseURL(responseURL string) bool {
+	u, err := url.Parse(responseURL)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	for _, host := range b.responseURLHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,1188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// postInteraction sends an interaction payload to the interactions endpoint of the bot, as the
+// form slack posts
+func postInteraction(t *testing.T, b *Bot, payload map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	if _, ok := payload["token"]; !ok {
+		payload["token"] = "verification-token"
+	}
+	form := url.Values{"payload": {jsonString(t, payload)}}
+	r := httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	b.HandleHelpInteraction(w, r)
+	return w
+}
+
+// testCommandRequest is a /news command sent from a public channel, answered through the response URL
+// of the fake slack
+func testCommandRequest(fake *fakeSlack) commandRequest {
+	return commandRequest{
+		id:           "test-request",
+		teamID:       "T0TEST",
+		channelID:    "C0TESTCHANNEL",
+		userID:       "U0TEST",
+		responseURL:  fake.responseURL,
+		responseType: defaultResponseType("C0TESTCHANNEL"),
+	}
+}
+
+// runCommand handles the text of a /news command and returns the messages posted to the response URL
+func runCommand(t *testing.T, b *Bot, fake *fakeSlack, req commandRequest, text string) []slackCall {
+	t.Helper()
+	b.processCommand(context.Background(), req, text)
+	return fake.received("response")
+}
+
+// quickReplyInteraction is a click on the quick reply of a section, below a message in a channel
+func quickReplyInteraction(responseURL string, section string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":         "block_actions",
+		"team":         map[string]string{"id": "T0TEST"},
+		"user":         map[string]string{"id": "U0TEST"},
+		"channel":      map[string]string{"id": "C0TESTCHANNEL"},
+		"container":    map[string]interface{}{"type": "message", "channel_id": "C0TESTCHANNEL", "message_ts": "1710417600.000100"},
+		"response_url": responseURL,
+		"actions": []map[string]string{
+			{"type": "button", "block_id": quickRepliesBlockID, "action_id": "quick_reply_" + section, "value": section},
+		},
+	}
+}
+
+func TestHandleHelpInteractionTypes(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}}}
+
+	tests := []struct {
+		name    string
+		payload func(fake *fakeSlack) map[string]interface{}
+		status  int
+		// posted tells whether the interaction posts the stories
+		posted bool
+	}{
+		{
+			name:    "block actions",
+			payload: func(fake *fakeSlack) map[string]interface{} { return quickReplyInteraction(fake.responseURL, "world") },
+			status:  http.StatusOK,
+			posted:  true,
+		},
+		{
+			name: "block actions without known input",
+			payload: func(fake *fakeSlack) map[string]interface{} {
+				interaction := quickReplyInteraction(fake.responseURL, "world")
+				interaction["actions"] = []map[string]string{{"type": "button", "block_id": "other", "action_id": "other"}}
+				return interaction
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "view submission",
+			payload: func(fake *fakeSlack) map[string]interface{} {
+				return map[string]interface{}{"type": "view_submission", "view": map[string]string{"id": "V0TEST"}}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "view closed",
+			payload: func(fake *fakeSlack) map[string]interface{} {
+				return map[string]interface{}{"type": "view_closed", "view": map[string]string{"id": "V0TEST"}}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "unknown type",
+			payload: func(fake *fakeSlack) map[string]interface{} {
+				return map[string]interface{}{"type": "message_action", "callback_id": "share"}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "invalid token",
+			payload: func(fake *fakeSlack) map[string]interface{} {
+				interaction := quickReplyInteraction(fake.responseURL, "world")
+				interaction["token"] = "forged"
+				return interaction
+			},
+			status: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, news, nil)
+			w := postInteraction(t, b, tt.payload(fake))
+			if w.Code != tt.status {
+				t.Errorf("got status %d, want %d", w.Code, tt.status)
+			}
+			waitTasks(t, b)
+
+			calls := fake.received()
+			if !tt.posted {
+				if len(calls) > 0 {
+					t.Errorf("got slack calls %+v, want none", calls)
+				}
+				return
+			}
+			if len(calls) != 1 || calls[0].method != "response" || !strings.Contains(calls[0].text(), "World story") {
+				t.Errorf("got slack calls %+v, want the world stories posted to the response url", calls)
+			}
+		})
+	}
+}
+
+func TestEmptySectionHint(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"home": {testArticle("Home story")}, "real estate": {}}}
+	b, fake := newTestBot(t, news, nil)
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories real estate")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	want := "The Real Estate section has no top stories right now — check back later."
+	if got := responses[0].message["text"]; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := responses[0].message["response_type"]; got != "ephemeral" {
+		t.Errorf("got response type %v, want the hint only visible to the user", got)
+	}
+}
+
+func TestIsAllowedResponseURL(t *testing.T) {
+	b := &Bot{responseURLHosts: []string{"hooks.slack.com"}}
+	tests := []struct {
+		responseURL string
+		allowed     bool
+	}{
+		{"https://hooks.slack.com/commands/T0TEST/123/abc", true},
+		{"https://HOOKS.SLACK.COM/commands/T0TEST/123/abc", true},
+		{"https://hooks.slack.com:443/actions/T0TEST/123/abc", true},
+		{"http://hooks.slack.com/commands/T0TEST/123/abc", false},
+		{"https://hooks.slack.com.attacker.example/commands", false},
+		{"https://attacker.example/hooks.slack.com", false},
+		{"https://hooks.slack.com@attacker.example/commands", false},
+		{"hooks.slack.com/commands", false},
+		{"", false},
+		{"://hooks.slack.com", false},
+	}
+	for _, tt := range tests {
+		if got := b.isAllowedResponseURL(tt.responseURL); got != tt.allowed {
+			t.Errorf("isAllowedResponseURL(%q) = %v, want %v", tt.responseURL, got, tt.allowed)
+		}
+	}
+}
+
+func TestBotWait(t *testing.T) {
+	b, _ := newTestBot(t, &fakeNews{}, nil)
+
+	finished := make(chan struct{})
+	b.runTask(func(ctx context.Context) {
+		time.Sleep(20 * time.Millisecond)
+		close(finished)
+	})
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatalf("got error %v, want the task waited for", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("returned before the task finished")
+	}
+}
+
+func TestBotWaitCancelsTasks(t *testing.T) {
+	b, _ := newTestBot(t, &fakeNews{}, nil)
+
+	cancelled := make(chan error, 1)
+	b.runTask(func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want the deadline of the wait", err)
+	}
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("the task got error %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the task wasn't cancelled")
+	}
+}
+
+func TestPostResponseRefusesMaliciousURL(t *testing.T) {
+	attacker := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("posted %s %s to the attacker", r.Method, r.URL)
+	}))
+	defer attacker.Close()
+	b, fake := newTestBot(t, &fakeNews{}, func(cfg *Config) {
+		cfg.slackResponseURLHosts = []string{"hooks.slack.com"}
+	})
+	b.slackClients.httpClient = attacker.Client()
+
+	req := testCommandRequest(fake)
+	req.responseURL = attacker.URL + "/steal"
+	req.result = &commandResult{status: statusOK}
+	b.postResponse(context.Background(), req, slack.MsgOptionText("secret stories", false))
+	if calls := fake.received(); len(calls) > 0 {
+		t.Errorf("got slack calls %+v, want none", calls)
+	}
+	if req.result.status != statusRejected {
+		t.Errorf("got status %q, want %q", req.result.status, statusRejected)
+	}
+}
+
+func TestPopularTopStories(t *testing.T) {
+	news := &fakeNews{
+		stories: map[string][]Article{"world": rankedArticles("a", "b", "c", "d")},
+		popular: rankedArticles("d", "x", "b"),
+	}
+	b, _ := newTestBot(t, news, nil)
+	articles, err := b.popularTopStories(context.Background(), "world", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := canonicalURLs(articles), []string{"d", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseInteraction(t *testing.T) {
+	payload := `{"type":"block_actions","token":"verification-token","user":{"id":"U0TEST"},"actions":[{"block_id":"quick_replies","action_id":"quick_reply_world","value":"world"}]}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{"form", "application/x-www-form-urlencoded", url.Values{"payload": {payload}}.Encode(), false},
+		{"json", "application/json", payload, false},
+		{"json with charset", "application/json; charset=utf-8", payload, false},
+		{"form without payload", "application/x-www-form-urlencoded", url.Values{"other": {payload}}.Encode(), true},
+		{"form with invalid payload", "application/x-www-form-urlencoded", url.Values{"payload": {"{"}}.Encode(), true},
+		{"invalid json", "application/json", "{", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			interaction, err := parseInteraction(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if interaction.Type != slack.InteractionTypeBlockActions || interaction.Token != "verification-token" || interaction.User.ID != "U0TEST" {
+				t.Errorf("got interaction %+v", interaction)
+			}
+			if section, quickReply, ok := helpInput(interaction); section != "world" || !quickReply || !ok {
+				t.Errorf("got input %q, %v, %v, want the world quick reply", section, quickReply, ok)
+			}
+		})
+	}
+}
+
+func TestHandleHelpInteractionJSON(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}}}
+	b, fake := newTestBot(t, news, nil)
+	interaction := quickReplyInteraction(fake.responseURL, "world")
+	interaction["token"] = "verification-token"
+	r := httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(jsonString(t, interaction)))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	b.HandleHelpInteraction(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	waitTasks(t, b)
+	if responses := fake.received("response"); len(responses) != 1 || !strings.Contains(responses[0].text(), "World story") {
+		t.Errorf("got responses %+v, want the world stories", responses)
+	}
+}
+
+func TestDefaultResponseType(t *testing.T) {
+	tests := []struct {
+		channelID string
+		want      string
+	}{
+		{"D0TESTDM01", slack.ResponseTypeInChannel},
+		{"C0TESTCHANNEL", slack.ResponseTypeEphemeral},
+		{"G0TESTGROUP", slack.ResponseTypeEphemeral},
+		{"", slack.ResponseTypeEphemeral},
+	}
+	for _, tt := range tests {
+		if got := defaultResponseType(tt.channelID); got != tt.want {
+			t.Errorf("defaultResponseType(%q) = %q, want %q", tt.channelID, got, tt.want)
+		}
+	}
+}
+
+func TestResponseVisibility(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}}}
+	tests := []struct {
+		name      string
+		channelID string
+		text      string
+		want      string
+	}{
+		{"dm", "D0TESTDM01", "stories world", slack.ResponseTypeInChannel},
+		{"public channel", "C0TESTCHANNEL", "stories world", slack.ResponseTypeEphemeral},
+		{"private flag in a dm", "D0TESTDM01", "stories world --private", slack.ResponseTypeEphemeral},
+		{"public flag in a public channel", "C0TESTCHANNEL", "stories --public world", slack.ResponseTypeInChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, news, nil)
+			req := testCommandRequest(fake)
+			req.channelID = tt.channelID
+			req.responseType = defaultResponseType(tt.channelID)
+			responses := runCommand(t, b, fake, req, tt.text)
+			if len(responses) != 1 || !strings.Contains(responses[0].text(), "World story") {
+				t.Fatalf("got responses %+v, want the world stories", responses)
+			}
+			if got := responses[0].message["response_type"]; got != tt.want {
+				t.Errorf("got response type %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmptySupportedSections(t *testing.T) {
+	b, fake := newTestBot(t, &fakeNews{}, nil)
+
+	picker := jsonBlocks(t, []slack.Block{b.sectionPicker()})[0]
+	if _, ok := picker["accessory"]; ok {
+		t.Errorf("got a section select without options: %v", picker)
+	}
+	if !strings.Contains(jsonString(t, picker), sectionsUnavailableMessage) {
+		t.Errorf("got picker %v, want the sections unavailable message", picker)
+	}
+	if b.isSupportedSection("home") || b.isSupportedSection("") {
+		t.Error("got a supported section without sections")
+	}
+
+	for _, text := range []string{"help", "stories world", "sections"} {
+		t.Run(text, func(t *testing.T) {
+			before := len(fake.received("response"))
+			responses := runCommand(t, b, fake, testCommandRequest(fake), text)
+			if len(responses) != before+1 {
+				t.Fatalf("got %d responses, want 1", len(responses)-before)
+			}
+			response := responses[len(responses)-1]
+			if !strings.Contains(response.text(), sectionsUnavailableMessage) {
+				t.Errorf("got response %v, want the sections unavailable message", response.message)
+			}
+			if strings.Contains(response.text(), "static_select") {
+				t.Errorf("got a section select without options: %v", response.message)
+			}
+		})
+	}
+	if requests := b.newsSource.(*fakeNews).requested(); len(requests) > 0 {
+		t.Errorf("got requests %v to the news source, want none", requests)
+	}
+}
+
+func TestHelpTip(t *testing.T) {
+	tips := []string{"try `/news stories world 5`", "try `/news briefing`", "try `/news sections`"}
+	news := &fakeNews{stories: map[string][]Article{"home": nil}}
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.helpTips = tips })
+	b.rng = rand.New(rand.NewSource(1))
+
+	shown := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		runCommand(t, b, fake, testCommandRequest(fake), "help")
+	}
+	for _, response := range fake.received("response") {
+		blocks, _ := response.message["blocks"].([]interface{})
+		if len(blocks) == 0 {
+			t.Fatalf("got response %v, want the help blocks", response.message)
+		}
+		last := blocks[len(blocks)-1].(map[string]interface{})
+		if last["type"] != "context" {
+			t.Fatalf("got last block %v, want the tip context", last)
+		}
+		text := last["elements"].([]interface{})[0].(map[string]interface{})["text"].(string)
+		tip := strings.TrimPrefix(text, "💡 Tip: ")
+		if tip == text || !contains(tips, tip) {
+			t.Fatalf("got tip %q, want one of the configured tips", text)
+		}
+		shown[tip] = true
+	}
+	if len(shown) < 2 {
+		t.Errorf("got the tips %v in 20 help views, want them to vary", shown)
+	}
+}
+
+func TestHelpWithoutTips(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"home": nil}}
+	b, fake := newTestBot(t, news, nil)
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "help")
+	if len(responses) != 1 || strings.Contains(responses[0].text(), "💡 Tip:") {
+		t.Errorf("got responses %+v, want the help without a tip", responses)
+	}
+}
+
+func TestStoriesLanguage(t *testing.T) {
+	news := &fakeNews{
+		stories:   map[string][]Article{"home": {testArticle("Home story")}, "world": {testArticle("World story")}},
+		localized: map[string][]Article{"es home": {testArticle("Noticia")}},
+	}
+	tests := []struct {
+		text  string
+		story string
+		note  string
+	}{
+		{"stories home --lang es", "Noticia", ""},
+		{"stories world --lang es", "World story", "ℹ️ Spanish content isn't available for the World section, showing it in English."},
+		{"stories world --lang en", "World story", ""},
+		{"stories world", "World story", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			b, fake := newTestBot(t, news, nil)
+			responses := runCommand(t, b, fake, testCommandRequest(fake), tt.text)
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			text := responses[0].text()
+			if !strings.Contains(text, tt.story) {
+				t.Errorf("the response doesn't show %q: %s", tt.story, text)
+			}
+			if tt.note != "" && !strings.Contains(text, tt.note) {
+				t.Errorf("the response doesn't note %q: %s", tt.note, text)
+			}
+			if tt.note == "" && strings.Contains(text, "isn't available") {
+				t.Errorf("got a fallback note: %s", text)
+			}
+		})
+	}
+}
+
+func TestPostToChannelOpensDM(t *testing.T) {
+	tests := []struct {
+		name      string
+		channelID string
+		fail      bool
+		// posts are the channels posted to, in order
+		posts []string
+	}{
+		{"channel found", "C0TESTCHANNEL", false, []string{"C0TESTCHANNEL"}},
+		{"channel not found", "D0UNKNOWNDM", true, []string{"D0UNKNOWNDM", "D0TESTDM01"}},
+		{"user id instead of a channel", "U0TEST", false, []string{"D0TESTDM01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, &fakeNews{}, nil)
+			if tt.fail {
+				fake.failNext("chat.postMessage", "channel_not_found")
+			}
+			req := testCommandRequest(fake)
+			req.channelID = tt.channelID
+			channel, _, err := b.postToChannel(context.Background(), req, slack.MsgOptionText("hello", false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.posts[len(tt.posts)-1]; channel != want {
+				t.Errorf("posted to %q, want %q", channel, want)
+			}
+			var posts []string
+			for _, call := range fake.received("chat.postMessage") {
+				posts = append(posts, call.values.Get("channel"))
+			}
+			if !reflect.DeepEqual(posts, tt.posts) {
+				t.Errorf("got posts to %v, want %v", posts, tt.posts)
+			}
+			opened := fake.received("conversations.open")
+			if wantOpened := len(tt.posts) > 1 || tt.channelID == "U0TEST"; wantOpened != (len(opened) == 1) {
+				t.Errorf("opened %d DMs", len(opened))
+			}
+			if len(opened) == 1 && opened[0].values.Get("users") != "U0TEST" {
+				t.Errorf("opened a DM with %q, want U0TEST", opened[0].values.Get("users"))
+			}
+		})
+	}
+}
+
+func TestPostToChannelWithoutUser(t *testing.T) {
+	b, fake := newTestBot(t, &fakeNews{}, nil)
+	fake.failNext("chat.postMessage", "channel_not_found")
+	req := testCommandRequest(fake)
+	req.userID = ""
+	if _, _, err := b.postToChannel(context.Background(), req, slack.MsgOptionText("hello", false)); !isSlackError(err, "channel_not_found") {
+		t.Errorf("got error %v, want channel_not_found", err)
+	}
+	if opened := fake.received("conversations.open"); len(opened) > 0 {
+		t.Errorf("opened %d DMs without a user", len(opened))
+	}
+}
+
+func TestStoryCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceMax  int
+		requested  int
+		count      int
+		wasClamped bool
+	}{
+		{"default", 20, 0, defaultStoryCount, false},
+		{"negative", 20, -1, defaultStoryCount, false},
+		{"within the limits", 20, 7, 7, false},
+		{"above the global max", 20, 15, maxStoryCount, true},
+		{"above the source max", 5, 8, 5, true},
+		{"at the source max", 5, 5, 5, false},
+		{"default above the source max", 2, 0, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{newsSource: &fakeNews{maxStories: tt.sourceMax}}
+			count, clamped := b.storyCount(tt.requested)
+			if count != tt.count || clamped != tt.wasClamped {
+				t.Errorf("storyCount(%d) = %d, %v, want %d, %v", tt.requested, count, clamped, tt.count, tt.wasClamped)
+			}
+		})
+	}
+}
+
+func TestStoriesClampedToSourceMax(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(8)}, maxStories: 5}
+	b, fake := newTestBot(t, news, nil)
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world 8")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	text := responses[0].text()
+	if !strings.Contains(text, "ℹ️ Showing 5 stories, the most we can show at once.") {
+		t.Errorf("the response doesn't note the clamped count: %s", text)
+	}
+	if !strings.Contains(text, "Story 5") || strings.Contains(text, "Story 6") {
+		t.Errorf("got %s, want the first 5 stories", text)
+	}
+}
+
+func TestPostToChannelUnfurl(t *testing.T) {
+	for _, links := range []bool{false, true} {
+		t.Run(fmt.Sprintf("links=%t", links), func(t *testing.T) {
+			b, fake := newTestBot(t, &fakeNews{}, nil)
+			message := renderMessage(testArticles(1), RenderOptions{Links: links, Now: b.now})
+			if _, _, err := b.postToChannel(context.Background(), testCommandRequest(fake), message); err != nil {
+				t.Fatal(err)
+			}
+			posts := fake.received("chat.postMessage")
+			if len(posts) != 1 {
+				t.Fatalf("got %d posts, want 1", len(posts))
+			}
+			if got, want := posts[0].values.Get("unfurl_links"), fmt.Sprint(links); got != want {
+				t.Errorf("got unfurl_links %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResponseURLUnfurl(t *testing.T) {
+	tests := []struct {
+		command     string
+		unfurlLinks interface{}
+		unfurlMedia interface{}
+	}{
+		{"stories world", false, false},
+		{"stories world --links", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			b, fake := newTestBot(t, &fakeNews{stories: map[string][]Article{"world": testArticles(2)}}, nil)
+			responses := runCommand(t, b, fake, testCommandRequest(fake), tt.command)
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			message := responses[0].message
+			if message["unfurl_links"] != tt.unfurlLinks {
+				t.Errorf("got unfurl_links %v, want %v", message["unfurl_links"], tt.unfurlLinks)
+			}
+			if message["unfurl_media"] != tt.unfurlMedia {
+				t.Errorf("got unfurl_media %v, want %v", message["unfurl_media"], tt.unfurlMedia)
+			}
+		})
+	}
+}
+
+// helpSelectInteraction is a section picked in the help message of a channel
+func helpSelectInteraction(responseURL string, section string, ephemeral bool) map[string]interface{} {
+	return map[string]interface{}{
+		"type":         "block_actions",
+		"team":         map[string]string{"id": "T0TEST"},
+		"user":         map[string]string{"id": "U0TEST"},
+		"channel":      map[string]string{"id": "C0TESTCHANNEL"},
+		"container":    map[string]interface{}{"type": "message", "channel_id": "C0TESTCHANNEL", "message_ts": "1710417600.000100", "is_ephemeral": ephemeral},
+		"response_url": responseURL,
+		"actions": []map[string]interface{}{{
+			"type":            "static_select",
+			"block_id":        sectionPickerBlockID,
+			"action_id":       sectionSelectActionID,
+			"selected_option": map[string]interface{}{"value": section},
+		}},
+	}
+}
+
+func TestHelpInteractionExpiredResponseURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		ephemeral      bool
+		responseStatus int
+		failUpdate     bool
+		// method is the call posting the stories
+		method string
+	}{
+		{"response url", false, 0, false, "response"},
+		{"expired response url", false, http.StatusNotFound, false, "chat.update"},
+		// the responses in channels are only shown to the user by default
+		{"expired response url and deleted message", false, http.StatusNotFound, true, "chat.postEphemeral"},
+		{"expired response url of an ephemeral message", true, http.StatusNotFound, false, "chat.postEphemeral"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}}}
+			b, fake := newTestBot(t, news, nil)
+			fake.responseStatus = tt.responseStatus
+			if tt.failUpdate {
+				fake.failNext("chat.update", "message_not_found")
+			}
+			postInteraction(t, b, helpSelectInteraction(fake.responseURL, "world", tt.ephemeral))
+			waitTasks(t, b)
+
+			posted := fake.received(tt.method)
+			if len(posted) != 1 || !strings.Contains(posted[0].text(), "World story") {
+				t.Fatalf("got %s calls %+v, want the stories posted once", tt.method, posted)
+			}
+			call := posted[0]
+			switch tt.method {
+			case "response":
+				if call.message["replace_original"] != true {
+					t.Errorf("got replace_original %v, want the help message replaced", call.message["replace_original"])
+				}
+			case "chat.update":
+				if call.values.Get("ts") != "1710417600.000100" || call.values.Get("channel") != "C0TESTCHANNEL" {
+					t.Errorf("updated %v, want the help message replaced", call.values)
+				}
+			case "chat.postEphemeral":
+				if call.values.Get("channel") != "C0TESTCHANNEL" || call.values.Get("user") != "U0TEST" {
+					t.Errorf("posted to %v, want the user who picked the section in the channel", call.values)
+				}
+			}
+		})
+	}
+}
+
+func TestStoriesNonPositiveCount(t *testing.T) {
+	tests := []struct {
+		text string
+		// stories is the number of stories shown, zero for the hint about the count
+		stories int
+	}{
+		{"stories world 0", defaultStoryCount},
+		{"stories world -1", defaultStoryCount},
+		{"stories world -100", defaultStoryCount},
+		{"stories world", defaultStoryCount},
+		{"stories world 4", 4},
+		{"stories world abc", 0},
+		{"stories world 2.5", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			news := &fakeNews{stories: map[string][]Article{"world": testArticles(20)}}
+			b, fake := newTestBot(t, news, nil)
+			var logs bytes.Buffer
+			b.logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			responses := runCommand(t, b, fake, testCommandRequest(fake), tt.text)
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			text := responses[0].text()
+
+			if tt.stories == 0 {
+				if !strings.Contains(text, "The number of stories must be a number") {
+					t.Errorf("got %s, want the hint about the count", text)
+				}
+				if requests := news.requested(); len(requests) > 0 {
+					t.Errorf("requested %v, want no request", requests)
+				}
+				return
+			}
+			last, next := fmt.Sprintf("Story %d", tt.stories), fmt.Sprintf("Story %d", tt.stories+1)
+			if !strings.Contains(text, last) || strings.Contains(text, next) {
+				t.Errorf("got %s, want %d stories", text, tt.stories)
+			}
+			ignored := strings.Contains(logs.String(), "ignoring the count")
+			if wantIgnored := strings.Contains(tt.text, "-") || strings.HasSuffix(tt.text, " 0"); ignored != wantIgnored {
+				t.Errorf("logged the ignored count: %t, want %t", ignored, wantIgnored)
+			}
+		})
+	}
+}
+
+func TestPostResponseNetworkFailure(t *testing.T) {
+	// a server without TLS behind an https URL, and a port nothing listens on
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("posted %s %s to a server failing the TLS handshake", r.Method, r.URL)
+	}))
+	defer plain.Close()
+	closed := httptest.NewTLSServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		responseURL string
+		public      bool
+		// method is the call posting the message instead of the response URL
+		method string
+	}{
+		{"tls failure", strings.Replace(plain.URL, "http://", "https://", 1) + "/response", false, "chat.postEphemeral"},
+		{"connection refused", closed.URL + "/response", false, "chat.postEphemeral"},
+		{"connection refused in channel", closed.URL + "/response", true, "chat.postMessage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, &fakeNews{}, nil)
+			req := testCommandRequest(fake)
+			req.responseURL = tt.responseURL
+			req.result = &commandResult{status: statusOK}
+			if tt.public {
+				req.responseType = slack.ResponseTypeInChannel
+			}
+			b.postResponse(context.Background(), req, slack.MsgOptionText("the stories", false))
+
+			posted := fake.received(tt.method)
+			if len(posted) != 1 || posted[0].values.Get("text") != "the stories" || posted[0].values.Get("channel") != "C0TESTCHANNEL" {
+				t.Fatalf("got %s calls %+v, want the message posted to the channel", tt.method, posted)
+			}
+			if tt.method == "chat.postEphemeral" && posted[0].values.Get("user") != "U0TEST" {
+				t.Errorf("posted to %q, want the message shown to the user only", posted[0].values.Get("user"))
+			}
+			if req.result.status != statusOK {
+				t.Errorf("got status %q, want ok after the fallback", req.result.status)
+			}
+		})
+	}
+}
+
+func TestPostResponseAPIErrorNoFallback(t *testing.T) {
+	b, fake := newTestBot(t, &fakeNews{}, nil)
+	fake.responseStatus = http.StatusInternalServerError
+	req := testCommandRequest(fake)
+	req.result = &commandResult{status: statusOK}
+	b.postResponse(context.Background(), req, slack.MsgOptionText("the stories", false))
+
+	if calls := fake.received("chat.postMessage", "chat.postEphemeral"); len(calls) > 0 {
+		t.Errorf("got calls %+v, want no fallback for an error of slack", calls)
+	}
+	if req.result.status != statusError {
+		t.Errorf("got status %q, want error", req.result.status)
+	}
+}
+
+func TestChannelIDPattern(t *testing.T) {
+	tests := map[string]bool{
+		"C0TESTCHANNEL": true,
+		"G0123456":      true,
+		"D0TESTDM01":    true,
+		"C012345":       true,
+		"C01234":        false,
+		"":              false,
+		"U0TEST":        false,
+		"c0testchannel": false,
+		"C0TEST CHAN":   false,
+		"#general":      false,
+		"C0TEST\n":      false,
+		"XC0TESTCHAN":   false,
+	}
+	for channelID, want := range tests {
+		if got := channelIDPattern.MatchString(channelID); got != want {
+			t.Errorf("channelIDPattern.MatchString(%q) = %t, want %t", channelID, got, want)
+		}
+	}
+}
+
+func TestPostMessageMalformedChannelID(t *testing.T) {
+	for _, channelID := range []string{"", "general", "U0TEST", "c0testchannel"} {
+		b, fake := newTestBot(t, &fakeNews{}, nil)
+		_, _, err := b.postMessage(context.Background(), "abc123", "T0TEST", channelID, slack.MsgOptionText("hello", false))
+		if !errors.Is(err, errInvalidChannelID) {
+			t.Errorf("%q: got error %v, want errInvalidChannelID", channelID, err)
+		}
+		if calls := fake.received(); len(calls) > 0 {
+			t.Errorf("%q: got slack calls %+v, want none", channelID, calls)
+		}
+	}
+}
+
+func TestPostMalformedChannelIDFallbacks(t *testing.T) {
+	// without a valid channel the message goes to the user, or through the response URL
+	b, fake := newTestBot(t, &fakeNews{}, nil)
+	req := testCommandRequest(fake)
+	req.channelID = ""
+	channel, _, err := b.postToChannel(context.Background(), req, slack.MsgOptionText("hello", false))
+	if err != nil || channel != "D0TESTDM01" {
+		t.Errorf("got channel %q, %v, want the DM with the user", channel, err)
+	}
+
+	req.userID = ""
+	if _, _, err := b.postToChannel(context.Background(), req, slack.MsgOptionText("hello", false)); !errors.Is(err, errInvalidChannelID) {
+		t.Errorf("got error %v without a user, want errInvalidChannelID", err)
+	}
+
+	b.postResponse(context.Background(), req, slack.MsgOptionText("hello", false))
+	if responses := fake.received("response"); len(responses) != 1 {
+		t.Errorf("got %d responses, want the message posted through the response URL", len(responses))
+	}
+}
+
+func TestStoriesCountInputs(t *testing.T) {
+	tests := []struct {
+		text    string
+		request string
+		// stories is the number of stories shown, zero for the hint about the count
+		stories int
+	}{
+		{"stories", "top home", defaultStoryCount},
+		{"stories politics", "top politics", defaultStoryCount},
+		{"stories politics 5", "top politics", 5},
+		{"stories politics 50", "top politics", maxStoryCount},
+		{"stories politics abc", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			news := &fakeNews{stories: map[string][]Article{"home": testArticles(20), "politics": testArticles(20)}}
+			b, fake := newTestBot(t, news, nil)
+			responses := runCommand(t, b, fake, testCommandRequest(fake), tt.text)
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			if requests := news.requested(); strings.Join(requests, ",") != tt.request {
+				t.Errorf("requested %v, want %q", requests, tt.request)
+			}
+
+			if tt.stories == 0 {
+				if responses[0].message["text"] != "⚠️ The number of stories must be a number, e.g. `/news stories politics 5`" ||
+					responses[0].message["response_type"] != "ephemeral" {
+					t.Errorf("got %+v, want the hint about the count only shown to the user", responses[0].message)
+				}
+				return
+			}
+			text := responses[0].text()
+			last, next := fmt.Sprintf("Story %d\n", tt.stories), fmt.Sprintf("Story %d\n", tt.stories+1)
+			if !strings.Contains(text, last) || strings.Contains(text, next) {
+				t.Errorf("got %s, want %d stories", text, tt.stories)
+			}
+		})
+	}
+}
+
+func TestRateLimitedMessage(t *testing.T) {
+	for _, err := range []error{ErrRateLimited, fmt.Errorf("top stories of world: %w", ErrRateLimited)} {
+		for _, text := range []string{"stories world", "stories world, science", "search climate", "author \"Paul Krugman\"", "popular viewed 7"} {
+			t.Run(text, func(t *testing.T) {
+				news := &fakeNews{stories: map[string][]Article{"world": nil, "science": nil}, err: err}
+				b, fake := newTestBot(t, news, nil)
+				responses := runCommand(t, b, fake, testCommandRequest(fake), text)
+				if len(responses) == 0 {
+					t.Fatal("got no response")
+				}
+				if !strings.Contains(responses[len(responses)-1].text(), rateLimitedMessage) {
+					t.Errorf("got %s, want the rate limit message", responses[len(responses)-1].text())
+				}
+				for _, response := range responses {
+					if response.message["response_type"] != "ephemeral" {
+						t.Errorf("got a %v response, want it only shown to the user", response.message["response_type"])
+					}
+				}
+			})
+		}
+	}
+	if got := newsErrorMessage(errors.New("boom")); got == rateLimitedMessage {
+		t.Error("got the rate limit message for another error")
+	}
+}
+
+// slowNews answers the top stories requests only once they're cancelled
+type slowNews struct {
+	*fakeNews
+}
+
+func (s slowNews) TopStories(ctx context.Context, section string, topN int) ([]Article, error) {
+	s.record("top " + section)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCommandTimeout(t *testing.T) {
+	news := slowNews{&fakeNews{stories: map[string][]Article{"world": nil}}}
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.commandTimeout = 50 * time.Millisecond })
+	start := time.Now()
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the command ran for %s, want it cut after 50ms", elapsed)
+	}
+	if len(responses) == 0 {
+		t.Fatal("got no response")
+	}
+	if got := responses[len(responses)-1].text(); !strings.Contains(got, timedOutMessage) {
+		t.Errorf("got %s, want the timeout message", got)
+	}
+	if got := newsErrorMessage(fmt.Errorf("top stories of world: %w", context.DeadlineExceeded)); got != timedOutMessage {
+		t.Errorf("got %q for a wrapped deadline, want the timeout message", got)
+	}
+}
+
+func TestSearchCommand(t *testing.T) {
+	docs := []map[string]interface{}{nytDoc("Carbon tax"), nytDoc("Emissions")}
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "Atlantis" {
+			writeJSONResponse(t, w, searchResponse())
+			return
+		}
+		writeJSONResponse(t, w, searchResponse(docs...))
+	})
+	b, fake := newTestBot(t, nyt, nil)
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "search Climate Policy")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	text := responses[0].text()
+	for _, want := range []string{"🔎 Latest stories about Climate Policy", "Carbon tax", "Emissions"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the results don't have %q:\n%s", want, text)
+		}
+	}
+
+	responses = runCommand(t, b, fake, testCommandRequest(fake), "search Atlantis")
+	if len(responses) != 2 || !strings.Contains(responses[1].text(), "No articles found for Atlantis.") {
+		t.Errorf("got responses %+v, want the empty results notice", responses)
+	}
+}
+
+func TestAuthorCommand(t *testing.T) {
+	news := &fakeNews{found: []Article{testArticle("The economy"), testArticle("Trade")}}
+	b, fake := newTestBot(t, news, nil)
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), `author "Paul Krugman"`)
+	if want := []string{"author Paul Krugman"}; !reflect.DeepEqual(news.requested(), want) {
+		t.Errorf("got requests %v, want %v", news.requested(), want)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	text := responses[0].text()
+	for _, want := range []string{"✍️ Latest stories by Paul Krugman", "The economy", "Trade"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the results don't have %q:\n%s", want, text)
+		}
+	}
+
+	news.found = nil
+	responses = runCommand(t, b, fake, testCommandRequest(fake), "author Nobody")
+	if len(responses) != 2 || !strings.Contains(responses[1].text(), "We couldn't find any recent articles by Nobody.") {
+		t.Errorf("got responses %+v, want the empty results notice", responses)
+	}
+
+	responses = runCommand(t, b, fake, testCommandRequest(fake), "author")
+	if len(responses) != 3 || !strings.Contains(responses[2].text(), "Tell us who to look for") {
+		t.Errorf("got responses %+v, want the usage notice", responses)
+	}
+}
+
+func TestInteractionContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    container
+	}{
+		{"message", `{"container": {"type": "message", "message_ts": "1710417600.000100", "channel_id": "C0TEST", "is_ephemeral": false}, "channel": {"id": "C0TEST"}}`,
+			container{channelID: "C0TEST", messageTS: "1710417600.000100", messageVisible: true}},
+		{"ephemeral message", `{"container": {"type": "message", "message_ts": "1710417600.000100", "channel_id": "C0TEST", "is_ephemeral": true}, "channel": {"id": "C0TEST"}}`,
+			container{channelID: "C0TEST", messageTS: "1710417600.000100"}},
+		{"attachment", `{"container": {"type": "message_attachment", "message_ts": "1710417600.000100", "channel_id": "D0TEST"}}`,
+			container{channelID: "D0TEST", messageTS: "1710417600.000100", messageVisible: true}},
+		{"modal opened from a channel", `{"container": {"type": "view", "view_id": "V0TEST"}, "channel": {"id": "C0TEST"}}`,
+			container{channelID: "C0TEST"}},
+		{"app home", `{"container": {"type": "view", "view_id": "V0TEST"}}`,
+			container{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var interaction slack.InteractionCallback
+			if err := json.Unmarshal([]byte(tt.payload), &interaction); err != nil {
+				t.Fatal(err)
+			}
+			if got := interactionContainer(interaction); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSectionsCommand(t *testing.T) {
+	news := &fakeNews{sections: []string{"world", "technology", "nyregion"}}
+	b, fake := newTestBot(t, news, nil)
+	req := testCommandRequest(fake)
+	// the list is only shown to the user, even in a conversation with the bot
+	req.channelID = "D0TESTDM"
+	req.responseType = defaultResponseType(req.channelID)
+
+	responses := runCommand(t, b, fake, req, "sections")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	if responses[0].message["response_type"] != "ephemeral" {
+		t.Errorf("got a %v response, want it only shown to the user", responses[0].message["response_type"])
+	}
+	text := responses[0].text()
+	for _, section := range news.sections {
+		if line := fmt.Sprintf("• %s: `/news stories %s`", news.UserFriendlySection(section), section); !strings.Contains(text, line) {
+			t.Errorf("the list doesn't have %q:\n%s", line, text)
+		}
+	}
+	if requests := news.requested(); len(requests) > 0 {
+		t.Errorf("got requests %v, want the sections listed without requests", requests)
+	}
+
+	b, fake = newTestBot(t, &fakeNews{}, nil)
+	responses = runCommand(t, b, fake, testCommandRequest(fake), "sections")
+	if len(responses) != 1 || !strings.Contains(responses[0].text(), sectionsUnavailableMessage) {
+		t.Errorf("got responses %+v, want the sections unavailable", responses)
+	}
+}
+
+func TestStoriesResolvesSections(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"technology": testArticles(3), "sports": testArticles(3), "world": nil}}
+	b, fake := newTestBot(t, news, nil)
+	runCommand(t, b, fake, testCommandRequest(fake), "stories tech")
+	runCommand(t, b, fake, testCommandRequest(fake), "stories sport, sprots")
+	if got, want := strings.Join(news.requested(), ", "), "top technology, top sports"; got != want {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories atlantis")
+	if got := responses[len(responses)-1].text(); !strings.Contains(got, invalidSectionMessage) {
+		t.Errorf("got %s, want the invalid section message", got)
+	}
+	if len(news.requested()) != 2 {
+		t.Errorf("got requests %v, want none for an unknown section", news.requested())
+	}
+}
+
+func TestHelpInput(t *testing.T) {
+	picker := `"state": {"values": {"section_picker": {"section_select": {"type": "static_select", "selected_option": {"value": "science"}}}}}`
+	selection := `{"type": "static_select", "block_id": "Ab1", "action_id": "x9Z", "selected_option": {"value": "arts"}}`
+	quickReply := `{"type": "button", "block_id": "quick_replies", "action_id": "quick_reply_world", "value": "world"}`
+	readMore := `{"type": "button", "block_id": "Cd2", "action_id": "read_more", "url": "https://nyti.ms/a"}`
+	tests := []struct {
+		name       string
+		payload    string
+		section    string
+		quickReply bool
+		ok         bool
+	}{
+		{"picker", `{"type": "block_actions", "actions": [` + selection + `], ` + picker + `}`, "science", false, true},
+		{"quick reply", `{"type": "block_actions", "actions": [` + quickReply + `], ` + picker + `}`, "world", true, true},
+		{"quick reply and selection", `{"type": "block_actions", "actions": [` + selection + `, ` + quickReply + `]}`, "world", true, true},
+		{"selection before the picker IDs", `{"type": "block_actions", "actions": [` + readMore + `, ` + selection + `]}`, "arts", false, true},
+		{"picker with other actions", `{"type": "block_actions", "actions": [` + readMore + `], ` + picker + `}`, "science", false, true},
+		{"unknown actions", `{"type": "block_actions", "actions": [` + readMore + `]}`, "", false, false},
+		{"no actions", `{"type": "block_actions", "actions": []}`, "", false, false},
+		{"empty picker", `{"type": "block_actions", "actions": [], "state": {"values": {"section_picker": {"section_select": {"type": "static_select"}}}}}`, "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var interaction slack.InteractionCallback
+			if err := json.Unmarshal([]byte(tt.payload), &interaction); err != nil {
+				t.Fatal(err)
+			}
+			section, quickReply, ok := helpInput(interaction)
+			if section != tt.section || quickReply != tt.quickReply || ok != tt.ok {
+				t.Errorf("got %q, quick reply %t, ok %t, want %q, %t, %t", section, quickReply, ok, tt.section, tt.quickReply, tt.ok)
+			}
+		})
+	}
+}
+
+func TestStoriesThumbnails(t *testing.T) {
+	withFallback := nytStory("Fallback")
+	withFallback["multimedia"] = []interface{}{
+		map[string]interface{}{"url": "https://static01.nyt.com/jumbo.jpg", "format": "superJumbo", "type": "image"},
+	}
+	withoutImage := nytStory("Bare")
+	withoutImage["multimedia"] = nil
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, topStoriesResponse(withFallback, withoutImage))
+	})
+	b, fake := newTestBot(t, nyt, func(cfg *Config) { cfg.renderImages = true })
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world 2")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+
+	accessories := map[string]map[string]interface{}{}
+	blocks, _ := responses[0].message["blocks"].([]interface{})
+	for _, block := range blocks {
+		block := block.(map[string]interface{})
+		if block["type"] != "section" {
+			continue
+		}
+		text := block["text"].(map[string]interface{})["text"].(string)
+		accessory, _ := block["accessory"].(map[string]interface{})
+		for _, title := range []string{"Fallback", "Bare"} {
+			if strings.Contains(text, "|"+title+">") {
+				accessories[title] = accessory
+			}
+		}
+	}
+	if image := accessories["Fallback"]; image["type"] != "image" || image["image_url"] != "https://static01.nyt.com/jumbo.jpg" {
+		t.Errorf("got accessory %v, want the fallback rendition as thumbnail", image)
+	}
+	if button := accessories["Bare"]; button["type"] != "button" {
+		t.Errorf("got accessory %v, want the read more button without a thumbnail", button)
+	}
+}
+
+func TestReadMoreInteraction(t *testing.T) {
+	b, fake := newTestBot(t, &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}, nil)
+	interaction := helpSelectInteraction(fake.responseURL, "world", false)
+	interaction["actions"] = []map[string]interface{}{{
+		"type":      "button",
+		"block_id":  "Ab1",
+		"action_id": readMoreActionID,
+		"url":       "https://nyti.ms/Story",
+	}}
+	w := postInteraction(t, b, interaction)
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want the click acknowledged", w.Code)
+	}
+	waitTasks(t, b)
+	if calls := fake.received(); len(calls) > 0 {
+		t.Errorf("got calls %+v, want nothing posted for a click on Read more", calls)
+	}
+}
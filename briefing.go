@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// sectionResult holds the outcome of fetching the top stories of one section
+type sectionResult struct {
+	section  string
+	articles []Article
+	err      error
+}
+
+// sectionTrailingPunctuation is the punctuation ignored at the end of a section
+const sectionTrailingPunctuation = ".!?;:"
+
+// parseSections splits a comma separated list of sections, dropping the repeated ones while
+// keeping the order. It also reports whether duplicates were removed.
+// Mobile keyboards like to end sentences, so trailing punctuation is dropped from each section.
+func parseSections(params string) ([]string, bool) {
+	var sections []string
+	seen := map[string]bool{}
+	duplicates := false
+	for _, section := range strings.Split(params, ",") {
+		section = normalizeSection(strings.TrimRight(strings.TrimSpace(section), sectionTrailingPunctuation))
+		if section == "" {
+			continue
+		}
+		if seen[section] {
+			duplicates = true
+			continue
+		}
+		seen[section] = true
+		sections = append(sections, section)
+	}
+	return sections, duplicates
+}
+
+// maxSections is the most sections shown in one message, to stay within the block limit of slack
+// and avoid hammering the news source
+const maxSections = 5
+
+// maxConcurrentFetches is the most sections fetched at once
+const maxConcurrentFetches = 3
+
+// multiSectionFlagsMessage tells the user the popular and translated stories are only fetched
+// for a single section
+const multiSectionFlagsMessage = "⚠️ `--popular` and `--lang` only work with a single section, e.g. `/news stories world --popular`."
+
+// handleMultiSectionRequest fetches the top stories of several sections and posts them in one
+// message. The sections that fail, e.g. because they're invalid, show their error inline, unless
+// all of them fail. With the links layout, the links of each section follow their header.
+func (b *Bot) handleMultiSectionRequest(ctx context.Context, req commandRequest, sections []string, duplicates bool, topN int, opts RenderOptions) {
+	var notes []string
+	if duplicates {
+		notes = append(notes, "ℹ️ Repeated sections were only fetched once.")
+	}
+	if len(sections) > maxSections {
+		notes = append(notes, fmt.Sprintf("ℹ️ Showing the first %d sections, the most we can show at once.", maxSections))
+		sections = sections[:maxSections]
+	}
+
+	results := b.fetchSections(ctx, sections, topN)
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			if !errors.Is(result.err, ErrInvalidSection) {
+				b.logger.Error("error requesting top stories", "correlation_id", req.id, "channel_id", req.channelID, "section", result.section, "error", result.err)
+			}
+			failed++
+		}
+	}
+	if failed == len(results) {
+		b.postNotice(ctx, req, newsErrorStatus(results[0].err), b.sectionErrorMessage(results[0]))
+		return
+	}
+
+	texts := notes
+	var blocks []slack.Block
+	for _, note := range notes {
+		blocks = append(blocks, slack.NewContextBlock("", slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: note,
+		}))
+	}
+	for _, result := range results {
+		sectionOpts := opts
+		sectionOpts.Header = b.newsSource.UserFriendlySection(result.section)
+		switch {
+		case result.err != nil:
+			sectionOpts.Notes = append(sectionOpts.Notes, b.sectionErrorMessage(result))
+		case len(result.articles) == 0:
+			sectionOpts.Notes = append(sectionOpts.Notes, "No top stories right now — check back later.")
+		}
+		articles, sectionOpts := b.prepareRender(ctx, result.articles, sectionOpts)
+		if opts.Links {
+			texts = append(texts, renderLinksText(articles, sectionOpts))
+			continue
+		}
+		blocks = append(blocks, renderStories(articles, sectionOpts)...)
+	}
+	if opts.Links {
+		b.postResponse(ctx, req, slack.MsgOptionText(strings.Join(texts, "\n\n"), false), slack.MsgOptionEnableLinkUnfurl())
+		return
+	}
+	b.postResponse(ctx, req, slack.MsgOptionBlocks(truncateBlocks(blocks, maxBlocks)...),
+		slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl())
+}
+
+// sectionErrorMessage explains why the stories of a section couldn't be fetched
+func (b *Bot) sectionErrorMessage(result sectionResult) string {
+	if errors.Is(result.err, ErrInvalidSection) {
+		return b.invalidSectionMessage(result.section)
+	}
+	return newsErrorMessage(result.err)
+}
+
+// fetchSections fetches the top N stories of each section, keeping the order of the sections.
+// At most maxConcurrentFetches sections are fetched at once, and unsupported sections are not
+// fetched at all but get ErrInvalidSection.
+func (b *Bot) fetchSections(ctx context.Context, sections []string, topN int) []sectionResult {
+	results := make([]sectionResult, len(sections))
+	slots := make(chan struct{}, maxConcurrentFetches)
+	var wg sync.WaitGroup
+	for i, section := range sections {
+		if !b.isSupportedSection(section) {
+			results[i] = sectionResult{section: section, err: ErrInvalidSection}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, section string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			articles, err := b.newsSource.TopStories(ctx, section, topN)
+			b.metrics.recordRequest(section, err)
+			results[i] = sectionResult{section: section, articles: articles, err: err}
+		}(i, section)
+	}
+	wg.Wait()
+	return results
+}
+
+// briefingGroup gathers several sections under a single heading in the briefing summary
+type briefingGroup struct {
+	Name     string
+	Sections []string
+}
+
+// parseBriefingGroups parses groups formatted as 'name=section,section', separated by '|'
+func parseBriefingGroups(value string) ([]briefingGroup, error) {
+	var groups []briefingGroup
+	for _, entry := range strings.Split(value, "|") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid briefing group %q, expected 'name=section,section'", entry)
+		}
+		sections, _ := parseSections(strings.ToLower(parts[1]))
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("briefing group %q has no sections", parts[0])
+		}
+		groups = append(groups, briefingGroup{Name: strings.TrimSpace(parts[0]), Sections: sections})
+	}
+	return groups, nil
+}
+
+// validateBriefingGroups checks every section of the groups is supported by the news source
+func validateBriefingGroups(groups []briefingGroup, newsSource NewsSource) error {
+	for _, group := range groups {
+		if section, ok := unsupportedSection(newsSource, group.Sections...); ok {
+			return fmt.Errorf("briefing group %q has unsupported section %q", group.Name, section)
+		}
+	}
+	return nil
+}
+
+// groupSections lists the sections of the groups in order, each section once
+func groupSections(groups []briefingGroup) []string {
+	var sections []string
+	for _, group := range groups {
+		sections = append(sections, group.Sections...)
+	}
+	sections, _ = parseSections(strings.Join(sections, ","))
+	return sections
+}
+
+// handleBriefingRequest posts a summary of several sections to the channel, then threads the
+// stories of each section as replies so the channel only shows one message
+func (b *Bot) handleBriefingRequest(ctx context.Context, req commandRequest, params string) {
+	sections, _ := b.resolveSections(parseSections(params))
+	// the groups only apply to the default briefing, sections picked by the user are listed as is
+	var groups []briefingGroup
+	if len(sections) == 0 {
+		sections = b.briefingSections
+		if len(b.briefingGroups) > 0 {
+			groups = b.briefingGroups
+			sections = groupSections(groups)
+		}
+	}
+	for _, section := range sections {
+		if !b.isSupportedSection(section) {
+			b.postNotice(ctx, req, statusRejected, invalidSectionMessage)
+			return
+		}
+	}
+
+	results := b.fetchSections(ctx, sections, 3)
+
+	// the summary is posted to the channel rather than the response URL, since we need its
+	// timestamp to thread the replies
+	channel, ts, err := b.postToChannel(ctx, req, slack.MsgOptionBlocks(b.renderBriefingSummary(results, groups)...))
+	if err != nil {
+		b.logger.Error("error posting briefing summary", "correlation_id", req.id, "channel_id", req.channelID, "error", err)
+		message := genericErrorMessage
+		if isSlackError(err, "not_in_channel", "channel_not_found") {
+			message = "⚠️ I need to be invited to this channel to post a briefing."
+		}
+		b.postNotice(ctx, req, statusError, message)
+		return
+	}
+
+	for _, result := range results {
+		if result.err != nil || len(result.articles) == 0 {
+			continue
+		}
+		opts := b.renderDefaults
+		opts.Header = b.newsSource.UserFriendlySection(result.section)
+		if _, _, err := b.postMessage(ctx, req.id, req.teamID, channel,
+			b.render(ctx, result.articles, opts),
+			slack.MsgOptionTS(ts),
+		); err != nil {
+			b.logger.Error("error posting briefing reply", "correlation_id", req.id, "channel_id", channel, "section", result.section, "error", err)
+		}
+	}
+}
+
+// renderBriefingSummary builds the parent message of a briefing, listing the lead story of each
+// section. With groups, the sections are listed under the heading of their group.
+func (b *Bot) renderBriefingSummary(results []sectionResult, groups []briefingGroup) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(&slack.TextBlockObject{
+			Type: "plain_text",
+			Text: "🗞 Here is your news briefing",
+		}),
+	}
+
+	if len(groups) == 0 {
+		blocks = append(blocks, b.renderBriefingLines("", results))
+	} else {
+		bySection := map[string]sectionResult{}
+		for _, result := range results {
+			bySection[result.section] = result
+		}
+		for _, group := range groups {
+			var groupResults []sectionResult
+			for _, section := range group.Sections {
+				groupResults = append(groupResults, bySection[section])
+			}
+			blocks = append(blocks, b.renderBriefingLines(group.Name, groupResults))
+		}
+	}
+
+	return append(blocks, slack.NewContextBlock("", slack.TextBlockObject{
+		Type: "mrkdwn",
+		Text: "More stories of each section in the thread 🧵",
+	}))
+}
+
+// renderBriefingLines lists the lead story of each section, below the heading if there is one
+func (b *Bot) renderBriefingLines(heading string, results []sectionResult) slack.Block {
+	var lines []string
+	if heading != "" {
+		lines = append(lines, fmt.Sprintf("*%s*", heading))
+	}
+	for _, result := range results {
+		name := b.newsSource.UserFriendlySection(result.section)
+		switch {
+		case result.err != nil:
+			lines = append(lines, fmt.Sprintf("• *%s*: unavailable right now", name))
+		case len(result.articles) == 0:
+			lines = append(lines, fmt.Sprintf("• *%s*: no top stories right now", name))
+		default:
+			lead := result.articles[0]
+			lines = append(lines, fmt.Sprintf("• *%s*: <%s|%s>", name, lead.URL, lead.Title))
+		}
+	}
+	return slack.NewSectionBlock(&slack.TextBlockObject{
+		Type: "mrkdwn",
+		Text: strings.Join(lines, "\n"),
+	}, nil, nil)
+}
+
+// handleBreakingRequest looks for breaking news across the breaking news sections
+func (b *Bot) handleBreakingRequest(ctx context.Context, req commandRequest) {
+	// consider every story of the sections since breaking news aren't always at the top
+	results := b.fetchSections(ctx, b.breakingSections, popularityCandidates)
+
+	var breaking []Article
+	seen := map[string]bool{}
+	failed := 0
+	var lastErr error
+	for _, result := range results {
+		if result.err != nil {
+			b.logger.Error("error requesting top stories", "correlation_id", req.id, "channel_id", req.channelID, "section", result.section, "error", result.err)
+			failed++
+			lastErr = result.err
+			continue
+		}
+		for _, a := range result.articles {
+			// the same story is often featured in several sections
+			if a.Breaking && !seen[a.CanonicalURL] {
+				seen[a.CanonicalURL] = true
+				breaking = append(breaking, a)
+			}
+		}
+	}
+
+	if len(breaking) == 0 {
+		if failed == len(results) {
+			b.postNotice(ctx, req, newsErrorStatus(lastErr), newsErrorMessage(lastErr))
+			return
+		}
+		b.postNotice(ctx, req, statusEmpty, "No breaking news right now.")
+		return
+	}
+
+	b.postResponse(ctx, req, b.render(ctx, breaking, breakingRenderOptions(b.renderDefaults)))
+}
+
+// breakingRenderOptions highlights the breaking news in red attachments
+func breakingRenderOptions(defaults RenderOptions) RenderOptions {
+	opts := defaults
+	opts.Header = "🔴 Breaking news"
+	opts.HeadlinesOnly = false
+	opts.Attachments = true
+	opts.Color = "#D40000"
+	return opts
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBriefingThreadsSections(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{
+		"world":   {testArticle("World lead"), testArticle("World second")},
+		"science": {testArticle("Science lead")},
+		"arts":    {},
+	}}
+	b, fake := newTestBot(t, news, nil)
+
+	runCommand(t, b, fake, testCommandRequest(fake), "briefing world, science, arts")
+
+	posts := fake.received("chat.postMessage")
+	if len(posts) != 3 {
+		t.Fatalf("got %d posts, want the summary and 2 replies", len(posts))
+	}
+	summary, replies := posts[0], posts[1:]
+	if summary.values.Get("channel") != "C0TESTCHANNEL" || summary.values.Get("thread_ts") != "" {
+		t.Errorf("got summary in channel %q and thread %q, want a new message in the channel", summary.values.Get("channel"), summary.values.Get("thread_ts"))
+	}
+	for _, line := range []string{"*World*: <https://nyti.ms/World%20lead|World lead>", "*Science*: <https://nyti.ms/Science%20lead|Science lead>", "*Arts*: no top stories right now"} {
+		if !strings.Contains(summary.text(), line) {
+			t.Errorf("the summary doesn't list %q: %s", line, summary.text())
+		}
+	}
+
+	for i, want := range []string{"World second", "Science lead"} {
+		reply := replies[i]
+		if reply.values.Get("thread_ts") != "1710417600.000100" || reply.values.Get("channel") != "C0TESTCHANNEL" {
+			t.Errorf("got reply %d in channel %q and thread %q, want it threaded below the summary", i, reply.values.Get("channel"), reply.values.Get("thread_ts"))
+		}
+		if !strings.Contains(reply.text(), want) {
+			t.Errorf("reply %d doesn't show %q", i, want)
+		}
+	}
+	if responses := fake.received("response"); len(responses) > 0 {
+		t.Errorf("got responses %+v, want the briefing posted to the channel only", responses)
+	}
+}
+
+func TestBriefingSummaryFailure(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World lead")}}}
+	b, fake := newTestBot(t, news, nil)
+	fake.failNext("chat.postMessage", "not_in_channel")
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "briefing world")
+	if posts := fake.received("chat.postMessage"); len(posts) != 1 {
+		t.Errorf("got %d posts, want no replies without the summary", len(posts))
+	}
+	if len(responses) != 1 || responses[0].message["text"] != "⚠️ I need to be invited to this channel to post a briefing." {
+		t.Errorf("got responses %+v, want the invite notice", responses)
+	}
+}
+
+func TestParseSectionsDuplicates(t *testing.T) {
+	tests := []struct {
+		params     string
+		sections   []string
+		duplicates bool
+	}{
+		{"world,science", []string{"world", "science"}, false},
+		{"world, world ,science,world", []string{"world", "science"}, true},
+		{"science,world,science", []string{"science", "world"}, true},
+		{"world,,", []string{"world"}, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		sections, duplicates := parseSections(tt.params)
+		if !reflect.DeepEqual(sections, tt.sections) || duplicates != tt.duplicates {
+			t.Errorf("parseSections(%q) = %v, %v, want %v, %v", tt.params, sections, duplicates, tt.sections, tt.duplicates)
+		}
+	}
+}
+
+func TestMultiSectionFetchesEachSectionOnce(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{
+		"world":   {testArticle("World story")},
+		"science": {testArticle("Science story")},
+	}}
+	b, fake := newTestBot(t, news, nil)
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world,world,science,world")
+	requests := news.requested()
+	sort.Strings(requests)
+	if want := []string{"top science", "top world"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %v, want %v", requests, want)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	text := responses[0].text()
+	for _, want := range []string{"ℹ️ Repeated sections were only fetched once.", "World story", "Science story"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the response doesn't show %q: %s", want, text)
+		}
+	}
+}
+
+func TestMultiSectionWithoutDuplicates(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}, "science": {testArticle("Science story")}}}
+	b, fake := newTestBot(t, news, nil)
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world,science")
+	if len(responses) != 1 || strings.Contains(responses[0].text(), "Repeated sections") {
+		t.Errorf("got responses %+v, want no note about repeated sections", responses)
+	}
+}
+
+func TestMultiSectionLinks(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}, "science": {testArticle("Science story")}}}
+	b, fake := newTestBot(t, news, nil)
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world,science --links")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	message := responses[0].message
+	if message["blocks"] != nil || message["unfurl_links"] != true {
+		t.Errorf("got blocks %v and unfurl_links %v, want the links as text with unfurls", message["blocks"], message["unfurl_links"])
+	}
+	text, _ := message["text"].(string)
+	for _, want := range []string{"*World*\nhttps://nyti.ms/World%20story", "*Science*\nhttps://nyti.ms/Science%20story"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the response doesn't show %q: %s", want, text)
+		}
+	}
+}
+
+func TestMultiSectionRejectsSingleSectionFlags(t *testing.T) {
+	for _, flag := range []string{"--popular", "--lang fr"} {
+		t.Run(flag, func(t *testing.T) {
+			news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}, "science": {testArticle("Science story")}}}
+			b, fake := newTestBot(t, news, nil)
+
+			responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world,science "+flag)
+			if len(responses) != 1 || !strings.Contains(responses[0].text(), multiSectionFlagsMessage) {
+				t.Fatalf("got responses %+v, want the single section notice", responses)
+			}
+			if requests := news.requested(); len(requests) > 0 {
+				t.Errorf("got requests %v, want none", requests)
+			}
+		})
+	}
+}
+
+// breakingArticle is a story flagged as breaking news
+func breakingArticle(title string) Article {
+	a := testArticle(title)
+	a.Breaking = true
+	a.CanonicalURL = a.URL
+	return a
+}
+
+func TestBreakingNews(t *testing.T) {
+	shared := breakingArticle("Shared breaking story")
+	news := &fakeNews{stories: map[string][]Article{
+		"home":  {testArticle("Home story"), shared},
+		"world": {breakingArticle("World breaking story"), shared, testArticle("World story")},
+	}}
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.breakingSections = []string{"home", "world"} })
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "breaking")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	message := responses[0].message
+	if !strings.Contains(jsonString(t, message["blocks"]), "🔴 Breaking news") {
+		t.Errorf("got blocks %v, want the breaking news header", message["blocks"])
+	}
+	attachments, _ := message["attachments"].([]interface{})
+	var titles []string
+	for _, attachment := range attachments {
+		attachment := attachment.(map[string]interface{})
+		if attachment["color"] != "#D40000" {
+			t.Errorf("got attachment color %v, want red", attachment["color"])
+		}
+		for _, title := range []string{"Shared breaking story", "World breaking story", "Home story", "World story"} {
+			if strings.Contains(jsonString(t, attachment), title) {
+				titles = append(titles, title)
+			}
+		}
+	}
+	if want := []string{"Shared breaking story", "World breaking story"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("got the stories %v, want %v", titles, want)
+	}
+}
+
+func TestBreakingNewsEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		news *fakeNews
+		want string
+	}{
+		{"nothing breaking", &fakeNews{stories: map[string][]Article{"home": {testArticle("Home story")}, "world": nil}}, "No breaking news right now."},
+		{"every section failing", &fakeNews{stories: map[string][]Article{"home": nil, "world": nil}, err: errors.New("boom")}, genericErrorMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, tt.news, func(cfg *Config) { cfg.breakingSections = []string{"home", "world"} })
+			responses := runCommand(t, b, fake, testCommandRequest(fake), "breaking")
+			if len(responses) != 1 || responses[0].message["text"] != tt.want {
+				t.Errorf("got responses %+v, want %q", responses, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSectionsTrailingPunctuation(t *testing.T) {
+	tests := []struct {
+		params string
+		want   []string
+	}{
+		{"technology.", []string{"technology"}},
+		{"world!", []string{"world"}},
+		{"science?", []string{"science"}},
+		{"arts,", []string{"arts"}},
+		{"world!!!", []string{"world"}},
+		{"world., science?", []string{"world", "science"}},
+		{"real estate.", []string{"realestate"}},
+		{"u.s.", []string{"us"}},
+	}
+	for _, tt := range tests {
+		if got, _ := parseSections(tt.params); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSections(%q) = %v, want %v", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestStoriesTrailingPunctuation(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"technology": {testArticle("Tech story")}, "world": {testArticle("World story")}}}
+	for _, text := range []string{"stories technology.", "stories technology!", "stories technology?", "stories technology,"} {
+		t.Run(text, func(t *testing.T) {
+			b, fake := newTestBot(t, news, nil)
+			responses := runCommand(t, b, fake, testCommandRequest(fake), text)
+			if len(responses) != 1 || !strings.Contains(responses[0].text(), "Tech story") {
+				t.Errorf("got responses %+v, want the technology stories", responses)
+			}
+		})
+	}
+}
+
+func TestSearchKeepsPunctuation(t *testing.T) {
+	news := &fakeNews{found: []Article{testArticle("Found story")}}
+	b, fake := newTestBot(t, news, nil)
+	runCommand(t, b, fake, testCommandRequest(fake), "search U.S. Open tickets.")
+	if requests := news.requested(); len(requests) != 1 || requests[0] != "search U.S. Open tickets." {
+		t.Errorf("got requests %v, want the query with its periods", requests)
+	}
+}
+
+func TestParseBriefingGroups(t *testing.T) {
+	groups, err := parseBriefingGroups("Tech & Science=technology,Science,health | World=world,us|")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []briefingGroup{
+		{Name: "Tech & Science", Sections: []string{"technology", "science", "health"}},
+		{Name: "World", Sections: []string{"world", "us"}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got %+v, want %+v", groups, want)
+	}
+
+	for _, value := range []string{"technology,science", "=technology", "Empty=", "Empty=,,"} {
+		if _, err := parseBriefingGroups(value); err == nil {
+			t.Errorf("parseBriefingGroups(%q): got no error", value)
+		}
+	}
+}
+
+func TestValidateBriefingGroups(t *testing.T) {
+	news := &fakeNews{sections: []string{"technology", "science", "world"}}
+	if err := validateBriefingGroups([]briefingGroup{{Name: "Tech", Sections: []string{"technology", "science"}}}, news); err != nil {
+		t.Errorf("got error %v for supported sections", err)
+	}
+	err := validateBriefingGroups([]briefingGroup{{Name: "Tech", Sections: []string{"technology", "gadgets"}}}, news)
+	if err == nil || !strings.Contains(err.Error(), `"gadgets"`) {
+		t.Errorf("got error %v, want the unsupported gadgets section", err)
+	}
+}
+
+func TestGroupedBriefing(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{
+		"technology": {testArticle("Tech lead")},
+		"science":    {testArticle("Science lead")},
+		"health":     {},
+		"world":      {testArticle("World lead")},
+	}}
+	groups := []briefingGroup{
+		{Name: "Tech & Science", Sections: []string{"technology", "science", "health"}},
+		{Name: "World news", Sections: []string{"world", "science"}},
+	}
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.briefingGroups = groups })
+
+	runCommand(t, b, fake, testCommandRequest(fake), "briefing")
+
+	requests := news.requested()
+	sort.Strings(requests)
+	if want := []string{"top health", "top science", "top technology", "top world"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %v, want one per section of the groups", requests)
+	}
+	posts := fake.received("chat.postMessage")
+	if len(posts) == 0 {
+		t.Fatal("the briefing wasn't posted")
+	}
+	var summary []map[string]interface{}
+	if err := json.Unmarshal([]byte(posts[0].values.Get("blocks")), &summary); err != nil {
+		t.Fatalf("decoding the summary: %v", err)
+	}
+	var sections []string
+	for _, block := range summary {
+		if block["type"] == "section" {
+			sections = append(sections, block["text"].(map[string]interface{})["text"].(string))
+		}
+	}
+	want := []string{
+		"*Tech & Science*\n• *Technology*: <https://nyti.ms/Tech%20lead|Tech lead>\n• *Science*: <https://nyti.ms/Science%20lead|Science lead>\n• *Health*: no top stories right now",
+		"*World news*\n• *World*: <https://nyti.ms/World%20lead|World lead>\n• *Science*: <https://nyti.ms/Science%20lead|Science lead>",
+	}
+	if !reflect.DeepEqual(sections, want) {
+		t.Errorf("got the groups\n%q\nwant\n%q", sections, want)
+	}
+	// each section is threaded once, the empty ones aren't
+	if replies := len(posts) - 1; replies != 3 {
+		t.Errorf("got %d replies, want 3", replies)
+	}
+}
+
+func TestBriefingWithSectionsIgnoresGroups(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World lead")}, "technology": {testArticle("Tech lead")}}}
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.briefingGroups = []briefingGroup{{Name: "Tech", Sections: []string{"technology"}}}
+	})
+	runCommand(t, b, fake, testCommandRequest(fake), "briefing world")
+	posts := fake.received("chat.postMessage")
+	if len(posts) == 0 || strings.Contains(posts[0].text(), "*Tech*") || !strings.Contains(posts[0].text(), "World lead") {
+		t.Errorf("got posts %+v, want the world section without the groups", posts)
+	}
+}
+
+func TestBriefingResolvesSections(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{
+		"politics":   {testArticle("Politics lead")},
+		"technology": {testArticle("Technology lead")},
+	}}
+	b, fake := newTestBot(t, news, nil)
+
+	runCommand(t, b, fake, testCommandRequest(fake), "briefing politcs, tech")
+	// the sections are fetched concurrently
+	requests := news.requested()
+	sort.Strings(requests)
+	if want := []string{"top politics", "top technology"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requested %v, want %v", requests, want)
+	}
+	posts := fake.received("chat.postMessage")
+	if len(posts) != 3 || !strings.Contains(posts[0].text(), "Politics lead") {
+		t.Errorf("got posts %+v, want the briefing of the resolved sections", posts)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errComputePanicked is returned to the callers waiting on a computation that panicked
+var errComputePanicked = errors.New("cache computation panicked")
+
+// ttlCache is a map whose entries expire after a TTL. It is safe for concurrent use, and evicts
+// the expired entries in the background until it is closed.
+type ttlCache[K comparable, V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	entries  map[K]ttlEntry[V]
+	inflight map[K]*ttlCall[V]
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// ttlCall is a computation of a value shared by the callers asking for the same key
+type ttlCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// newTTLCache creates a cache whose entries expire after ttl, which must be positive
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	c := &ttlCache[K, V]{
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[K]ttlEntry[V]{},
+		inflight: map[K]*ttlCall[V]{},
+		stop:     make(chan struct{}),
+	}
+	go c.evictEvery(ttl)
+	return c
+}
+
+// Get returns the value of key, if it is cached and not expired
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !c.now().Before(e.expires) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set caches the value of key for the TTL of the cache
+func (c *ttlCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = ttlEntry[V]{value: value, expires: c.now().Add(c.ttl)}
+}
+
+// GetOrCompute returns the cached value of key, computing and caching it on a miss. Concurrent
+// calls for the same key share a single computation, and give up waiting for it when their ctx is
+// done. Errors are returned but not cached.
+func (c *ttlCache[K, V]) GetOrCompute(ctx context.Context, key K, compute func() (V, error)) (V, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && c.now().Before(e.expires) {
+		c.mu.Unlock()
+		return e.value, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+	call := &ttlCall[V]{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	// release the waiting callers even if compute panics
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		if call.err == nil {
+			c.entries[key] = ttlEntry[V]{value: call.value, expires: c.now().Add(c.ttl)}
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.err = errComputePanicked
+	call.value, call.err = compute()
+	return call.value, call.err
+}
+
+// Delete removes key from the cache
+func (c *ttlCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Clear removes every entry from the cache
+func (c *ttlCache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[K]ttlEntry[V]{}
+}
+
+// Close stops the background eviction
+func (c *ttlCache[K, V]) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// evictEvery removes the expired entries every interval until the cache is closed
+func (c *ttlCache[K, V]) evictEvery(interval time.Duration) {
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.evictExpired()
+		}
+	}
+}
+
+func (c *ttlCache[K, V]) evictExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
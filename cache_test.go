@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, ttl time.Duration) (*ttlCache[string, int], *fakeClock) {
+	t.Helper()
+	clock := newFakeClock()
+	c := newTTLCache[string, int](ttl)
+	c.now = clock.Now
+	t.Cleanup(c.Close)
+	return c, clock
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	c, clock := newTestCache(t, time.Minute)
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("got %d, %v, want 1", v, ok)
+	}
+	clock.advance(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("the entry expired before its TTL")
+	}
+	clock.advance(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("the entry didn't expire after its TTL")
+	}
+
+	// setting again restarts the TTL
+	c.Set("a", 2)
+	clock.advance(30 * time.Second)
+	c.Set("a", 3)
+	clock.advance(45 * time.Second)
+	if v, ok := c.Get("a"); !ok || v != 3 {
+		t.Errorf("got %d, %v, want 3", v, ok)
+	}
+}
+
+func TestTTLCacheDeleteAndClear(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("got the deleted entry")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("deleting an entry removed another one")
+	}
+	c.Clear()
+	if _, ok := c.Get("b"); ok {
+		t.Error("got an entry after clearing the cache")
+	}
+}
+
+func TestTTLCacheEvictExpired(t *testing.T) {
+	c, clock := newTestCache(t, time.Minute)
+	c.Set("old", 1)
+	clock.advance(30 * time.Second)
+	c.Set("new", 2)
+	clock.advance(30 * time.Second)
+	c.evictExpired()
+
+	c.mu.Lock()
+	_, old := c.entries["old"]
+	_, fresh := c.entries["new"]
+	c.mu.Unlock()
+	if old || !fresh {
+		t.Errorf("got the old entry kept %v and the new one %v, want only the new one", old, fresh)
+	}
+}
+
+func TestTTLCacheGetOrCompute(t *testing.T) {
+	c, clock := newTestCache(t, time.Minute)
+	computes := 0
+	compute := func() (int, error) {
+		computes++
+		return computes, nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := c.GetOrCompute(context.Background(), "a", compute); err != nil || v != 1 {
+			t.Fatalf("got %d, %v, want the cached 1", v, err)
+		}
+	}
+	clock.advance(time.Minute)
+	if v, _ := c.GetOrCompute(context.Background(), "a", compute); v != 2 {
+		t.Errorf("got %d, want the value computed again once expired", v)
+	}
+
+	// errors aren't cached
+	boom := errors.New("boom")
+	if _, err := c.GetOrCompute(context.Background(), "b", func() (int, error) { return 0, boom }); !errors.Is(err, boom) {
+		t.Errorf("got error %v, want boom", err)
+	}
+	if v, err := c.GetOrCompute(context.Background(), "b", func() (int, error) { return 7, nil }); err != nil || v != 7 {
+		t.Errorf("got %d, %v, want the value computed again after the error", v, err)
+	}
+}
+
+func TestTTLCacheSingleFlight(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	var computes atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	compute := func() (int, error) {
+		computes.Add(1)
+		close(started)
+		<-release
+		return 42, nil
+	}
+
+	const callers = 50
+	results := make(chan int, callers)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		v, _ := c.GetOrCompute(context.Background(), "key", compute)
+		results <- v
+	}()
+	<-started
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := c.GetOrCompute(context.Background(), "key", compute)
+			results <- v
+		}()
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := computes.Load(); n != 1 {
+		t.Errorf("computed %d times, want a single computation", n)
+	}
+	for v := range results {
+		if v != 42 {
+			t.Errorf("got %d, want the shared 42", v)
+		}
+	}
+}
+
+func TestTTLCacheGetOrComputeWaiterCancelled(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	computed := make(chan int)
+	go func() {
+		v, _ := c.GetOrCompute(context.Background(), "key", func() (int, error) {
+			close(started)
+			<-release
+			return 42, nil
+		})
+		computed <- v
+	}()
+	<-started
+
+	// the waiter gives up on its own context, the computation goes on for the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetOrCompute(ctx, "key", func() (int, error) { return 1, nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want the waiter cancelled", err)
+	}
+	close(release)
+	if v := <-computed; v != 42 {
+		t.Errorf("computed %d, want 42", v)
+	}
+	if v, ok := c.Get("key"); !ok || v != 42 {
+		t.Errorf("got %d, %t, want the computed value cached", v, ok)
+	}
+}
+
+func TestTTLCacheComputePanics(t *testing.T) {
+	c, _ := newTestCache(t, time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		c.GetOrCompute(context.Background(), "key", func() (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, err := c.GetOrCompute(context.Background(), "key", func() (int, error) { return 1, nil })
+		waiter <- err
+	}()
+	// the waiters can't be observed, give this one time to join the computation before it panics
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if r := <-panicked; r != "boom" {
+		t.Errorf("got panic %v, want the panic to reach the caller computing", r)
+	}
+	if err := <-waiter; err != nil && !errors.Is(err, errComputePanicked) {
+		t.Errorf("got error %v, want errComputePanicked", err)
+	}
+	if _, ok := c.Get("key"); ok {
+		t.Error("the panicked computation was cached")
+	}
+	if v, err := c.GetOrCompute(context.Background(), "key", func() (int, error) { return 2, nil }); err != nil || v != 2 {
+		t.Errorf("got %d, %v, want the value computed again after the panic", v, err)
+	}
+}
+
+func TestTTLCacheConcurrentAccess(t *testing.T) {
+	c, clock := newTestCache(t, time.Minute)
+	var wg sync.WaitGroup
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("key-%d", i%10)
+				switch (g + i) % 6 {
+				case 0:
+					c.Set(key, i)
+				case 1:
+					c.Get(key)
+				case 2:
+					c.GetOrCompute(context.Background(), key, func() (int, error) { return i, nil })
+				case 3:
+					c.Delete(key)
+				case 4:
+					c.evictExpired()
+					clock.advance(time.Second)
+				case 5:
+					if i%50 == 0 {
+						c.Clear()
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestTTLCacheClose(t *testing.T) {
+	c := newTTLCache[string, int](time.Minute)
+	c.Close()
+	c.Close()
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a closed cache stopped caching")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// staleTTL is how long the last articles fetched are kept past their TTL, to serve them while the
+// source rate limits us
+const staleTTL = time.Hour
+
+// topStoriesKey identifies a top stories request in the cache
+type topStoriesKey struct {
+	section string
+	topN    int
+}
+
+// searchKey identifies a search in the cache. The query is normalized, so trivially different
+// queries share an entry.
+type searchKey struct {
+	filter string
+	query  string
+	topN   int
+}
+
+// CachedNewsSource is a NewsSource caching the top stories and the search results of another one.
+// Searches are more expensive and their results more stable, so they usually get a longer TTL.
+// A zero TTL disables the cache of the matching requests.
+// While the source rate limits us, the last articles fetched are served instead, even expired.
+type CachedNewsSource struct {
+	NewsSource
+
+	topStories *ttlCache[topStoriesKey, []Article]
+	searches   *ttlCache[searchKey, []Article]
+	// staleTopStories and staleSearches keep the last articles fetched for staleTTL
+	staleTopStories *ttlCache[topStoriesKey, []Article]
+	staleSearches   *ttlCache[searchKey, []Article]
+}
+
+func NewCachedNewsSource(source NewsSource, topStoriesTTL time.Duration, searchTTL time.Duration) *CachedNewsSource {
+	c := &CachedNewsSource{NewsSource: source}
+	if topStoriesTTL > 0 {
+		c.topStories = newTTLCache[topStoriesKey, []Article](topStoriesTTL)
+		c.staleTopStories = newTTLCache[topStoriesKey, []Article](max(topStoriesTTL, staleTTL))
+	}
+	if searchTTL > 0 {
+		c.searches = newTTLCache[searchKey, []Article](searchTTL)
+		c.staleSearches = newTTLCache[searchKey, []Article](max(searchTTL, staleTTL))
+	}
+	return c
+}
+
+// TopStories returns the cached top stories of a section, fetching them on a miss
+func (c *CachedNewsSource) TopStories(ctx context.Context, section string, topN int) ([]Article, error) {
+	if c.topStories == nil {
+		return c.NewsSource.TopStories(ctx, section, topN)
+	}
+	key := topStoriesKey{section: normalizeSection(section), topN: topN}
+	return getOrFetch(ctx, c.topStories, c.staleTopStories, key, func() ([]Article, error) {
+		return c.NewsSource.TopStories(ctx, section, topN)
+	})
+}
+
+// SearchByAuthor returns the cached stories of an author, searching them on a miss
+func (c *CachedNewsSource) SearchByAuthor(ctx context.Context, author string, topN int) ([]Article, error) {
+	if c.searches == nil {
+		return c.NewsSource.SearchByAuthor(ctx, author, topN)
+	}
+	key := searchKey{filter: "author", query: normalizeQuery(author), topN: topN}
+	return getOrFetch(ctx, c.searches, c.staleSearches, key, func() ([]Article, error) {
+		return c.NewsSource.SearchByAuthor(ctx, author, topN)
+	})
+}
+
+// SearchArticles returns the cached stories matching a query, searching them on a miss
+func (c *CachedNewsSource) SearchArticles(ctx context.Context, query string, limit int) ([]Article, error) {
+	if c.searches == nil {
+		return c.NewsSource.SearchArticles(ctx, query, limit)
+	}
+	key := searchKey{filter: "query", query: normalizeQuery(query), topN: limit}
+	return getOrFetch(ctx, c.searches, c.staleSearches, key, func() ([]Article, error) {
+		return c.NewsSource.SearchArticles(ctx, query, limit)
+	})
+}
+
+// getOrFetch returns the cached articles of key, fetching them on a miss. The articles fetched
+// are also kept in stale, which answers instead of the source while it rate limits us.
+func getOrFetch[K comparable](ctx context.Context, cache *ttlCache[K, []Article], stale *ttlCache[K, []Article], key K, fetch func() ([]Article, error)) ([]Article, error) {
+	articles, err := cache.GetOrCompute(ctx, key, func() ([]Article, error) {
+		articles, err := fetch()
+		if err == nil {
+			stale.Set(key, copyArticles(articles))
+		}
+		return articles, err
+	})
+	if errors.Is(err, ErrRateLimited) {
+		if articles, ok := stale.Get(key); ok {
+			return copyArticles(articles), nil
+		}
+	}
+	return copyArticles(articles), err
+}
+
+// Refresh fetches the top stories of a section and replaces the cached ones, so the next requests
+// hit a fresh entry. Nothing is cached on errors, the previous entry is kept until it expires.
+func (c *CachedNewsSource) Refresh(ctx context.Context, section string, topN int) error {
+	if c.topStories == nil {
+		return nil
+	}
+	articles, err := c.NewsSource.TopStories(ctx, section, topN)
+	if err != nil {
+		return err
+	}
+	key := topStoriesKey{section: normalizeSection(section), topN: topN}
+	c.topStories.Set(key, copyArticles(articles))
+	c.staleTopStories.Set(key, copyArticles(articles))
+	return nil
+}
+
+// Flush empties the caches, see cacheFlusher
+func (c *CachedNewsSource) Flush() {
+	if c.topStories != nil {
+		c.topStories.Clear()
+		c.staleTopStories.Clear()
+	}
+	if c.searches != nil {
+		c.searches.Clear()
+		c.staleSearches.Clear()
+	}
+}
+
+// Close stops the background eviction of the caches, and closes the wrapped source when it has
+// caches of its own, see cacheCloser
+func (c *CachedNewsSource) Close() {
+	for _, cache := range []*ttlCache[topStoriesKey, []Article]{c.topStories, c.staleTopStories} {
+		if cache != nil {
+			cache.Close()
+		}
+	}
+	for _, cache := range []*ttlCache[searchKey, []Article]{c.searches, c.staleSearches} {
+		if cache != nil {
+			cache.Close()
+		}
+	}
+	if closer, ok := c.NewsSource.(cacheCloser); ok {
+		closer.Close()
+	}
+}
+
+// normalizeQuery lowercases a search query, trimming and collapsing its spaces
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// copyArticles copies the cached articles, so callers can't change the cache
+func copyArticles(articles []Article) []Article {
+	if articles == nil {
+		return nil
+	}
+	return append([]Article{}, articles...)
+}
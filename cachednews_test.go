@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestCachedNews caches the stories of source with the given TTLs, on a fake clock
+func newTestCachedNews(t *testing.T, source NewsSource, topStoriesTTL time.Duration, searchTTL time.Duration) (*CachedNewsSource, *fakeClock) {
+	t.Helper()
+	clock := newFakeClock()
+	c := NewCachedNewsSource(source, topStoriesTTL, searchTTL)
+	t.Cleanup(c.Close)
+	if c.topStories != nil {
+		c.topStories.now = clock.Now
+		c.staleTopStories.now = clock.Now
+	}
+	if c.searches != nil {
+		c.searches.now = clock.Now
+		c.staleSearches.now = clock.Now
+	}
+	return c, clock
+}
+
+func TestCachedSearchNormalizedQueries(t *testing.T) {
+	news := &fakeNews{found: testArticles(3)}
+	c, _ := newTestCachedNews(t, news, 0, time.Minute)
+	ctx := context.Background()
+
+	for _, query := range []string{"Climate Policy", "climate policy", "  CLIMATE   policy ", "climate\tpolicy"} {
+		articles, err := c.SearchArticles(ctx, query, 3)
+		if err != nil || len(articles) != 3 {
+			t.Fatalf("%q: got %d stories, %v", query, len(articles), err)
+		}
+	}
+	for _, author := range []string{"Paul Krugman", "paul  krugman"} {
+		if _, err := c.SearchByAuthor(ctx, author, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the number of stories and the kind of search are part of the key
+	c.SearchArticles(ctx, "climate policy", 5)
+	c.SearchByAuthor(ctx, "climate policy", 3)
+
+	want := []string{"search Climate Policy", "author Paul Krugman", "search climate policy", "author climate policy"}
+	if requests := news.requested(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("requested %v, want %v", requests, want)
+	}
+}
+
+func TestCachedSearchErrors(t *testing.T) {
+	news := &fakeNews{found: testArticles(3), err: errors.New("boom")}
+	c, _ := newTestCachedNews(t, news, 0, time.Minute)
+	ctx := context.Background()
+	if _, err := c.SearchArticles(ctx, "climate", 3); err == nil {
+		t.Fatal("got no error")
+	}
+	news.mu.Lock()
+	news.err = nil
+	news.mu.Unlock()
+	if articles, err := c.SearchArticles(ctx, "climate", 3); err != nil || len(articles) != 3 {
+		t.Errorf("got %d stories, %v, want the search sent again after the error", len(articles), err)
+	}
+	if requests := news.requested(); len(requests) != 2 {
+		t.Errorf("requested %v, want the error not cached", requests)
+	}
+}
+
+func TestCachedSearchDisabled(t *testing.T) {
+	news := &fakeNews{found: testArticles(3)}
+	c, _ := newTestCachedNews(t, news, time.Minute, 0)
+	for i := 0; i < 2; i++ {
+		c.SearchArticles(context.Background(), "climate", 3)
+		c.SearchByAuthor(context.Background(), "Paul Krugman", 3)
+	}
+	if requests := news.requested(); len(requests) != 4 {
+		t.Errorf("requested %v, want every search sent with a zero TTL", requests)
+	}
+}
+
+func TestCachedSearchCopies(t *testing.T) {
+	news := &fakeNews{found: testArticles(3)}
+	c, _ := newTestCachedNews(t, news, 0, time.Minute)
+	articles, _ := c.SearchArticles(context.Background(), "climate", 3)
+	articles[0].Title = "Changed"
+	if articles, _ := c.SearchArticles(context.Background(), "climate", 3); articles[0].Title != "Story 1" {
+		t.Errorf("got title %q, want the cached stories left unchanged by the callers", articles[0].Title)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := map[string]string{
+		"Climate Policy":          "climate policy",
+		"  climate \t\n policy  ": "climate policy",
+		"":                        "",
+		"ÉLECTIONS":               "élections",
+	}
+	for query, want := range tests {
+		if got := normalizeQuery(query); got != want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestCachedTopStoriesExpiry(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3), "us": testArticles(3)}}
+	c, clock := newTestCachedNews(t, news, 5*time.Minute, 0)
+	ctx := context.Background()
+
+	c.TopStories(ctx, "world", 3)
+	clock.advance(5*time.Minute - time.Second)
+	c.TopStories(ctx, "World", 3)
+	if requests := news.requested(); len(requests) != 1 {
+		t.Errorf("requested %v before the TTL, want the cached stories", requests)
+	}
+	clock.advance(time.Second)
+	c.TopStories(ctx, "world", 3)
+	if requests := news.requested(); len(requests) != 2 {
+		t.Errorf("requested %v after the TTL, want the stories fetched again", requests)
+	}
+
+	// the sections are normalized and the number of stories is part of the key
+	c.TopStories(ctx, "u.s.", 3)
+	c.TopStories(ctx, "us", 3)
+	c.TopStories(ctx, "us", 2)
+	want := []string{"top world", "top world", "top u.s.", "top us"}
+	if requests := news.requested(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("requested %v, want %v", requests, want)
+	}
+}
+
+func TestCachedTopStoriesRefreshAndFlush(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+	c, clock := newTestCachedNews(t, news, 5*time.Minute, time.Minute)
+	ctx := context.Background()
+
+	c.TopStories(ctx, "world", 3)
+	clock.advance(4 * time.Minute)
+	news.mu.Lock()
+	news.stories["world"] = []Article{testArticle("Fresh")}
+	news.mu.Unlock()
+	if err := c.Refresh(ctx, "world", 3); err != nil {
+		t.Fatal(err)
+	}
+	// the refreshed entry lives a whole TTL
+	clock.advance(4 * time.Minute)
+	articles, _ := c.TopStories(ctx, "world", 3)
+	if len(articles) != 1 || articles[0].Title != "Fresh" {
+		t.Errorf("got %+v, want the refreshed stories", articles)
+	}
+	if requests := news.requested(); len(requests) != 2 {
+		t.Errorf("requested %v, want the refreshed stories served from the cache", requests)
+	}
+
+	c.SearchArticles(ctx, "climate", 3)
+	c.Flush()
+	c.TopStories(ctx, "world", 3)
+	c.SearchArticles(ctx, "climate", 3)
+	if requests := news.requested(); len(requests) != 5 {
+		t.Errorf("requested %v, want everything fetched again after the flush", requests)
+	}
+}
+
+func TestCachedTopStoriesRefreshError(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+	c, _ := newTestCachedNews(t, news, 5*time.Minute, 0)
+	ctx := context.Background()
+	c.TopStories(ctx, "world", 3)
+
+	news.mu.Lock()
+	news.err = errors.New("boom")
+	news.mu.Unlock()
+	if err := c.Refresh(ctx, "world", 3); err == nil {
+		t.Error("got no error")
+	}
+	// the previous entry is kept
+	if articles, err := c.TopStories(ctx, "world", 3); err != nil || len(articles) != 3 {
+		t.Errorf("got %d stories, %v, want the previous stories", len(articles), err)
+	}
+}
+
+func TestCachedStoriesServedWhileRateLimited(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}, found: testArticles(2)}
+	c, clock := newTestCachedNews(t, news, 5*time.Minute, 5*time.Minute)
+	ctx := context.Background()
+	c.TopStories(ctx, "world", 3)
+	c.SearchArticles(ctx, "climate", 3)
+
+	clock.advance(10 * time.Minute)
+	setErr := func(err error) {
+		news.mu.Lock()
+		news.err = err
+		news.mu.Unlock()
+	}
+	setErr(ErrRateLimited)
+	if articles, err := c.TopStories(ctx, "world", 3); err != nil || len(articles) != 3 {
+		t.Errorf("got %d stories, %v, want the expired stories while rate limited", len(articles), err)
+	}
+	if articles, err := c.SearchArticles(ctx, "Climate", 3); err != nil || len(articles) != 2 {
+		t.Errorf("got %d results, %v, want the expired results while rate limited", len(articles), err)
+	}
+	if _, err := c.TopStories(ctx, "us", 3); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v for a section never fetched, want ErrRateLimited", err)
+	}
+
+	// the other errors aren't hidden
+	setErr(errors.New("boom"))
+	if _, err := c.TopStories(ctx, "world", 3); err == nil {
+		t.Error("got no error, want the error of the source")
+	}
+
+	setErr(ErrRateLimited)
+	clock.advance(staleTTL)
+	if _, err := c.TopStories(ctx, "world", 3); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v past the stale TTL, want ErrRateLimited", err)
+	}
+}
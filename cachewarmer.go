@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// maxWarmBackoff caps the delay before retrying to warm a failing section
+const maxWarmBackoff = 30 * time.Minute
+
+// cacheWarmer refreshes the top stories of the hot sections in the cache on a schedule, so the
+// requests for them almost always hit the cache. A section failing to refresh is retried after an
+// exponentially growing delay, to leave NYT alone while it struggles.
+type cacheWarmer struct {
+	cache    *CachedNewsSource
+	sections []string
+	interval time.Duration
+	now      func() time.Time
+	logger   *slog.Logger
+
+	mu sync.Mutex
+	// failures is the number of consecutive failed refreshes of each section, and due when the
+	// failing sections are next refreshed
+	failures map[string]int
+	due      map[string]time.Time
+}
+
+// newCacheWarmer returns the warmer of the hot sections, or nil when there is nothing to warm
+func newCacheWarmer(newsSource NewsSource, sections []string, interval time.Duration, logger *slog.Logger) *cacheWarmer {
+	cache, ok := newsSource.(*CachedNewsSource)
+	if !ok || cache.topStories == nil || len(sections) == 0 || interval <= 0 {
+		return nil
+	}
+	return &cacheWarmer{
+		cache:    cache,
+		sections: sections,
+		interval: interval,
+		now:      time.Now,
+		logger:   logger,
+		failures: make(map[string]int),
+		due:      make(map[string]time.Time),
+	}
+}
+
+// validateWarmSections checks every hot section is supported by the news source
+func validateWarmSections(sections []string, newsSource NewsSource) error {
+	if section, ok := unsupportedSection(newsSource, sections...); ok {
+		return fmt.Errorf("unsupported cache warming section %q", section)
+	}
+	return nil
+}
+
+// warm refreshes the hot sections that are due, at most maxConcurrentFetches at once
+func (w *cacheWarmer) warm(ctx context.Context) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentFetches)
+	for _, section := range w.sections {
+		if !w.isDue(section) {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(section string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			err := w.cache.Refresh(ctx, section, defaultStoryCount)
+			w.record(section, err)
+			if err != nil && ctx.Err() == nil {
+				w.logger.Warn("error warming the cache", "section", section, "error", err)
+			}
+		}(section)
+	}
+	wg.Wait()
+}
+
+func (w *cacheWarmer) isDue(section string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.now().Before(w.due[section])
+}
+
+// record resets the backoff of a section refreshed successfully, or doubles it after a failure
+func (w *cacheWarmer) record(section string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil {
+		delete(w.failures, section)
+		delete(w.due, section)
+		return
+	}
+	w.failures[section]++
+	w.due[section] = w.now().Add(warmBackoff(w.interval, w.failures[section]))
+}
+
+// warmBackoff returns the delay before refreshing a section again after failures consecutive
+// failures: the interval doubled for each failure, capped at maxWarmBackoff
+func warmBackoff(interval time.Duration, failures int) time.Duration {
+	backoff := interval
+	for i := 0; i < failures && backoff < maxWarmBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxWarmBackoff {
+		return maxWarmBackoff
+	}
+	return backoff
+}
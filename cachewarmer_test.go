@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingSections fails the top stories requests of the sections set in failing
+type failingSections struct {
+	*fakeNews
+	mu      sync.Mutex
+	failing map[string]bool
+}
+
+func (f *failingSections) TopStories(ctx context.Context, section string, topN int) ([]Article, error) {
+	f.mu.Lock()
+	failing := f.failing[section]
+	f.mu.Unlock()
+	if failing {
+		f.record("top " + section)
+		return nil, errors.New("boom")
+	}
+	return f.fakeNews.TopStories(ctx, section, topN)
+}
+
+func (f *failingSections) setFailing(section string, failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing[section] = failing
+}
+
+// newTestCacheWarmer warms sections of news every minute on a fake clock
+func newTestCacheWarmer(t *testing.T, news NewsSource, sections ...string) (*cacheWarmer, *CachedNewsSource, *fakeClock) {
+	t.Helper()
+	cache, clock := newTestCachedNews(t, news, time.Hour, 0)
+	w := newCacheWarmer(cache, sections, time.Minute, discardLogger())
+	if w == nil {
+		t.Fatal("got no cache warmer")
+	}
+	w.now = clock.Now
+	return w, cache, clock
+}
+
+func TestCacheWarmerRefreshes(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(5), "science": testArticles(5)}}
+	w, cache, _ := newTestCacheWarmer(t, news, "world", "science")
+	ctx := context.Background()
+
+	w.warm(ctx)
+	w.warm(ctx)
+	if got := strings.Join(news.requested(), ", "); strings.Count(got, "top world") != 2 || strings.Count(got, "top science") != 2 {
+		t.Errorf("got requests %q, want every section refreshed on each run", got)
+	}
+
+	// the commands hit the warmed entries
+	n := len(news.requested())
+	articles, err := cache.TopStories(ctx, "world", defaultStoryCount)
+	if err != nil || len(articles) != defaultStoryCount {
+		t.Fatalf("got %d stories, %v", len(articles), err)
+	}
+	if len(news.requested()) != n {
+		t.Errorf("got requests %q, want the warmed entry to be served", news.requested()[n:])
+	}
+}
+
+func TestCacheWarmerBackoff(t *testing.T) {
+	news := &failingSections{fakeNews: &fakeNews{stories: map[string][]Article{"world": testArticles(3), "science": testArticles(3)}}, failing: map[string]bool{"world": true}}
+	w, _, clock := newTestCacheWarmer(t, news, "world", "science")
+	ctx := context.Background()
+
+	// refreshes counts the refreshes of world as the clock moves a minute at a time
+	refreshes := func(minutes int) int {
+		before := strings.Count(strings.Join(news.requested(), ","), "top world")
+		for i := 0; i < minutes; i++ {
+			clock.advance(time.Minute)
+			w.warm(ctx)
+		}
+		return strings.Count(strings.Join(news.requested(), ","), "top world") - before
+	}
+
+	w.warm(ctx)
+	// retried after 2 minutes, then after 4 minutes
+	if got := refreshes(1); got != 0 {
+		t.Errorf("refreshed a failing section %d times a minute after its failure, want 0", got)
+	}
+	if got := refreshes(1); got != 1 {
+		t.Errorf("refreshed a failing section %d times 2 minutes after its failure, want 1", got)
+	}
+	if got := refreshes(3); got != 0 {
+		t.Errorf("refreshed a section failing twice %d times within 3 minutes, want 0", got)
+	}
+	if got := refreshes(1); got != 1 {
+		t.Errorf("refreshed a section failing twice %d times after 4 minutes, want 1", got)
+	}
+	// the sections that succeed are refreshed on every run meanwhile
+	if got := strings.Count(strings.Join(news.requested(), ","), "top science"); got != 7 {
+		t.Errorf("refreshed the healthy section %d times, want 7", got)
+	}
+
+	// a success goes back to the regular interval
+	news.setFailing("world", false)
+	refreshes(8)
+	if got := refreshes(3); got != 3 {
+		t.Errorf("refreshed a recovered section %d times in 3 minutes, want 3", got)
+	}
+}
+
+func TestWarmBackoff(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		0:  time.Minute,
+		1:  2 * time.Minute,
+		3:  8 * time.Minute,
+		5:  maxWarmBackoff,
+		50: maxWarmBackoff,
+	} {
+		if got := warmBackoff(time.Minute, failures); got != want {
+			t.Errorf("warmBackoff(1m, %d) = %s, want %s", failures, got, want)
+		}
+	}
+}
+
+func TestNewCacheWarmerDisabled(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": nil}}
+	logger := discardLogger()
+	cache, _ := newTestCachedNews(t, news, time.Hour, 0)
+	uncached, _ := newTestCachedNews(t, news, 0, time.Hour)
+	tests := []struct {
+		name     string
+		source   NewsSource
+		sections []string
+		interval time.Duration
+	}{
+		{"no cache", news, []string{"world"}, time.Minute},
+		{"no top stories cache", uncached, []string{"world"}, time.Minute},
+		{"no sections", cache, nil, time.Minute},
+		{"no interval", cache, []string{"world"}, 0},
+	}
+	for _, tt := range tests {
+		if w := newCacheWarmer(tt.source, tt.sections, tt.interval, logger); w != nil {
+			t.Errorf("%s: got a cache warmer, want none", tt.name)
+		}
+	}
+}
+
+func TestSchedulerCadence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newScheduler()
+	var mu sync.Mutex
+	runs := map[string]int{}
+	job := func(name string) func(context.Context) {
+		return func(context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[name]++
+		}
+	}
+	s.now(ctx, job("now"))
+	s.every(ctx, 10*time.Millisecond, job("every"))
+	s.every(ctx, 0, job("disabled"))
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	s.wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if runs["now"] != 1 {
+		t.Errorf("ran the immediate job %d times, want once", runs["now"])
+	}
+	if runs["every"] < 3 {
+		t.Errorf("ran the periodic job %d times in 100ms, want it every 10ms", runs["every"])
+	}
+	if runs["disabled"] != 0 {
+		t.Errorf("ran a job without an interval %d times", runs["disabled"])
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// cooldown throttles the commands of each channel, allowing one every period. It is safe for
+// concurrent use. A non-positive period disables it.
+type cooldown struct {
+	period time.Duration
+	now    func() time.Time
+	// last holds the time of the last command of the channels cooling down
+	last *ttlCache[string, time.Time]
+}
+
+func newCooldown(period time.Duration) *cooldown {
+	c := &cooldown{period: period, now: time.Now}
+	if period > 0 {
+		c.last = newTTLCache[string, time.Time](period)
+		c.last.now = c.nowFunc
+	}
+	return c
+}
+
+// nowFunc lets the cache follow the clock of the cooldown, even when it is replaced
+func (c *cooldown) nowFunc() time.Time {
+	return c.now()
+}
+
+// try records a command in the channel if the channel isn't cooling down. Otherwise it returns
+// false and how long until the next command is allowed.
+func (c *cooldown) try(channelID string) (bool, time.Duration) {
+	if c.period <= 0 {
+		return true, 0
+	}
+
+	now := c.now()
+	recorded := false
+	last, _ := c.last.GetOrCompute(context.Background(), channelID, func() (time.Time, error) {
+		recorded = true
+		return now, nil
+	})
+	if recorded {
+		return true, 0
+	}
+	return false, last.Add(c.period).Sub(now)
+}
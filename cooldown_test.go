@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCooldown(t *testing.T) {
+	clock := newFakeClock()
+	c := newCooldown(30 * time.Second)
+	c.now = clock.Now
+
+	if ok, _ := c.try("C0TESTCHANNEL"); !ok {
+		t.Fatal("the first command was throttled")
+	}
+	clock.advance(10 * time.Second)
+	ok, wait := c.try("C0TESTCHANNEL")
+	if ok || wait != 20*time.Second {
+		t.Errorf("got %v and a wait of %s, want the channel cooling down for 20s", ok, wait)
+	}
+	if ok, _ := c.try("C0OTHERCHANNEL"); !ok {
+		t.Error("another channel was throttled")
+	}
+
+	// throttled commands don't extend the cooldown
+	clock.advance(20 * time.Second)
+	if ok, _ := c.try("C0TESTCHANNEL"); !ok {
+		t.Error("the command was throttled after the cooldown expired")
+	}
+	if ok, wait := c.try("C0TESTCHANNEL"); ok || wait != 30*time.Second {
+		t.Errorf("got %v and a wait of %s, want a new cooldown of 30s", ok, wait)
+	}
+}
+
+func TestCooldownDisabled(t *testing.T) {
+	for _, period := range []time.Duration{0, -time.Second} {
+		c := newCooldown(period)
+		for i := 0; i < 3; i++ {
+			if ok, wait := c.try("C0TESTCHANNEL"); !ok || wait != 0 {
+				t.Errorf("period %s: got %v and a wait of %s, want no cooldown", period, ok, wait)
+			}
+		}
+	}
+}
+
+func TestCommandCooldown(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}}}
+	clock := newFakeClock()
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.commandCooldown = time.Minute })
+	b.cooldown.now = clock.Now
+
+	run := func(text string) string {
+		responses := runCommand(t, b, fake, testCommandRequest(fake), text)
+		return responses[len(responses)-1].text()
+	}
+	if text := run("stories world --public"); !strings.Contains(text, "World story") {
+		t.Fatalf("got %q, want the world stories", text)
+	}
+	clock.advance(15 * time.Second)
+	if text := run("stories world --public"); !strings.Contains(text, "⏳ This channel was updated recently, try again in 45s.") {
+		t.Errorf("got %q, want the cooldown notice", text)
+	}
+	// the private responses and the help don't spam the channel
+	if text := run("stories world"); !strings.Contains(text, "World story") {
+		t.Errorf("got %q, want the private stories despite the cooldown", text)
+	}
+	if text := run("help --public"); strings.Contains(text, "⏳") {
+		t.Errorf("got %q, want the help despite the cooldown", text)
+	}
+	clock.advance(45 * time.Second)
+	if text := run("stories world --public"); !strings.Contains(text, "World story") {
+		t.Errorf("got %q, want the world stories after the cooldown", text)
+	}
+}
+
+func TestCooldownCoversChannelPosts(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}}}
+	clock := newFakeClock()
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.commandCooldown = time.Minute })
+	b.cooldown.now = clock.Now
+
+	// the briefing is posted to the channel even when its notices are private
+	runCommand(t, b, fake, testCommandRequest(fake), "briefing world --private")
+	if posts := fake.received("chat.postMessage"); len(posts) != 2 {
+		t.Fatalf("got %d posts, want the briefing and its thread", len(posts))
+	}
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "briefing world --private")
+	if text := responses[len(responses)-1].text(); !strings.Contains(text, "⏳ This channel was updated recently") {
+		t.Errorf("got %q, want the cooldown notice", text)
+	}
+	if posts := fake.received("chat.postMessage"); len(posts) != 2 {
+		t.Errorf("got %d posts, want the second briefing throttled", len(posts))
+	}
+
+	// picking a section replaces the visible help message
+	postInteraction(t, b, helpSelectInteraction(fake.responseURL, "world", false))
+	waitTasks(t, b)
+	responses = fake.received("response")
+	if text := responses[len(responses)-1].text(); !strings.Contains(text, "⏳ This channel was updated recently") {
+		t.Errorf("got %q, want the cooldown notice", text)
+	}
+	postInteraction(t, b, helpSelectInteraction(fake.responseURL, "world", true))
+	waitTasks(t, b)
+	responses = fake.received("response")
+	if text := responses[len(responses)-1].text(); !strings.Contains(text, "World story") {
+		t.Errorf("got %q, want the stories of the ephemeral help despite the cooldown", text)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// HandleEvent handles a request coming from the slack Events API
+// (https://api.slack.com/apis/connections/events-api)
+func (b *Bot) HandleEvent(w http.ResponseWriter, r *http.Request) {
+	verified, err := b.verifySignature(r)
+	if err != nil {
+		b.logger.Warn("invalid request signature", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		b.logger.Error("error reading event request", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	verifyToken := slackevents.OptionVerifyToken(&slackevents.TokenComparator{VerificationToken: b.slackVerificationToken})
+	if verified {
+		verifyToken = slackevents.OptionNoVerifyToken()
+	}
+	event, err := slackevents.ParseEvent(json.RawMessage(body), verifyToken)
+	if err != nil {
+		b.logger.Warn("error parsing event", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		// slack verifies the events URL by expecting the challenge back
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			b.logger.Warn("error parsing url verification challenge", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(challenge.Challenge))
+	case slackevents.CallbackEvent:
+		// return 200 immediately, slack retries events that aren't acknowledged within 3 seconds
+		w.WriteHeader(http.StatusOK)
+		switch ev := event.InnerEvent.Data.(type) {
+		case *slackevents.AppHomeOpenedEvent:
+			if ev.Tab == "home" {
+				b.runTask(func(ctx context.Context) { b.publishHomeView(event.TeamID, ev.User) })
+			}
+		default:
+			b.logger.Warn("unexpected event", "event", event.InnerEvent.Type)
+		}
+	default:
+		b.logger.Warn("unexpected event type", "event_type", event.Type)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// publishHomeView publishes the app's Home tab for a user
+func (b *Bot) publishHomeView(teamID string, userID string) {
+	client, err := b.slackClients.get(teamID)
+	if err != nil {
+		b.logger.Error("error getting slack client", "team_id", teamID, "error", err)
+		return
+	}
+
+	view := slack.HomeTabViewRequest{
+		Type:   slack.VTHomeTab,
+		Blocks: slack.Blocks{BlockSet: b.homeViewBlocks()},
+	}
+	if _, err := client.PublishView(userID, view, ""); err != nil {
+		b.logger.Error("error publishing home view", "team_id", teamID, "user_id", userID, "error", err)
+	}
+}
+
+// homeViewBlocks builds the Home tab landing page, showing the available commands and a section picker
+func (b *Bot) homeViewBlocks() []slack.Block {
+	return []slack.Block{
+		slack.NewHeaderBlock(&slack.TextBlockObject{
+			Type: "plain_text",
+			Text: "See what's happening in the world 🗣",
+		}),
+		slack.NewSectionBlock(&slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: "*Here's what you can ask me:*\n" +
+				"• `/news stories [section] [count]` the top stories of a section\n" +
+				"• `/news author \"name\"` the latest stories by an author\n" +
+				"• `/news search [keywords]` the latest stories about a topic\n" +
+				"• `/news briefing [sections]` a threaded briefing of several sections\n" +
+				"• `/news breaking` the breaking news, if any\n" +
+				"• `/news popular [viewed|emailed|shared] [days]` the most popular stories of the last 1, 7 or 30 days\n" +
+				"• `/news onthisday` a story published on this day in a past year\n" +
+				"• `/news sections` the list of sections you can ask for\n" +
+				"• `/news help` the list of sections to choose from",
+		}, nil, nil),
+		slack.NewDividerBlock(),
+		b.sectionPicker(),
+	}
+}
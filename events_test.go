@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHomeViewBlocks(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"home": nil, "world": nil, "science": nil}}
+	b, _ := newTestBot(t, news, nil)
+	blocks := jsonBlocks(t, b.homeViewBlocks())
+
+	if got, want := blockTypes(blocks), []string{"header", "section", "divider", "section"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got blocks %v, want %v", got, want)
+	}
+	text := blocks[1]["text"].(map[string]interface{})["text"].(string)
+	for _, command := range append([]string{"help"}, commands...) {
+		if !strings.Contains(text, "`/news "+command) {
+			t.Errorf("the home view doesn't list /news %s", command)
+		}
+	}
+
+	picker := blocks[3]
+	if picker["block_id"] != sectionPickerBlockID {
+		t.Errorf("got picker block id %v, want %s", picker["block_id"], sectionPickerBlockID)
+	}
+	accessory := picker["accessory"].(map[string]interface{})
+	if accessory["type"] != "static_select" || accessory["action_id"] != sectionSelectActionID {
+		t.Errorf("got accessory %v, want the section select", accessory)
+	}
+	var values []string
+	for _, option := range accessory["options"].([]interface{}) {
+		values = append(values, option.(map[string]interface{})["value"].(string))
+	}
+	if want := []string{"home", "science", "world"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got options %v, want %v", values, want)
+	}
+}
+
+func TestSectionSelectInitialOption(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections []string
+		initial  string
+	}{
+		{"default section", []string{"world", "home", "science"}, "home"},
+		{"no default section", []string{"world", "science"}, "science"},
+		{"no sections", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t, &fakeNews{sections: tt.sections}, nil)
+			selection := b.sectionSelect()
+			if tt.initial == "" {
+				if selection.InitialOption != nil {
+					t.Errorf("got initial option %q without options", selection.InitialOption.Value)
+				}
+				return
+			}
+			if selection.InitialOption == nil || selection.InitialOption.Value != tt.initial {
+				t.Fatalf("got initial option %+v, want %q", selection.InitialOption, tt.initial)
+			}
+			// slack rejects an initial option that isn't one of the options
+			if !reflect.DeepEqual(selection.InitialOption, selection.Options[0]) {
+				t.Errorf("got initial option %+v, want the first option %+v", selection.InitialOption, selection.Options[0])
+			}
+		})
+	}
+}
+
+func TestHandleEventAppHomeOpened(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"home": nil}}
+	tests := []struct {
+		tab       string
+		published bool
+	}{
+		{"home", true},
+		{"messages", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tab, func(t *testing.T) {
+			b, fake := newTestBot(t, news, nil)
+			event := map[string]interface{}{
+				"token":   "verification-token",
+				"type":    "event_callback",
+				"team_id": "T0TEST",
+				"event":   map[string]string{"type": "app_home_opened", "user": "U0TEST", "tab": tt.tab},
+			}
+			r := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(jsonString(t, event)))
+			w := httptest.NewRecorder()
+			b.HandleEvent(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+			}
+			waitTasks(t, b)
+
+			calls := fake.received("views.publish")
+			if !tt.published {
+				if len(calls) > 0 {
+					t.Errorf("published %d views, want none", len(calls))
+				}
+				return
+			}
+			if len(calls) != 1 || calls[0].message["user_id"] != "U0TEST" {
+				t.Fatalf("got calls %+v, want the home view published for U0TEST", calls)
+			}
+			view, _ := calls[0].message["view"].(map[string]interface{})
+			if view["type"] != "home" {
+				t.Errorf("got view type %v, want home", view["type"])
+			}
+		})
+	}
+}
+
+func TestHandleEventURLVerification(t *testing.T) {
+	b, _ := newTestBot(t, &fakeNews{}, nil)
+	body := `{"token":"verification-token","type":"url_verification","challenge":"the-challenge"}`
+	w := httptest.NewRecorder()
+	b.HandleEvent(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+	if w.Code != http.StatusOK || w.Body.String() != "the-challenge" {
+		t.Errorf("got status %d and body %q, want the challenge back", w.Code, w.Body.String())
+	}
+}
+
+func TestSectionOptions(t *testing.T) {
+	nyt, err := NewNYTimes("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newTestBot(t, nyt, nil)
+	options := b.addNewsSectionsOptions()
+
+	values := map[string]bool{}
+	for _, option := range options {
+		values[option.Value] = true
+		if want := nyt.UserFriendlySection(option.Value); option.Text.Text != want {
+			t.Errorf("got label %q for %s, want %q", option.Text.Text, option.Value, want)
+		}
+	}
+	for _, section := range nyt.SupportedSections() {
+		if !values[section] {
+			t.Errorf("the dropdown has no option for %s", section)
+		}
+	}
+	if len(options) != len(nyt.SupportedSections()) {
+		t.Errorf("got %d options for %d sections", len(options), len(nyt.SupportedSections()))
+	}
+
+	if options[0].Value != defaultSection {
+		t.Errorf("got %s first, want the default section", options[0].Value)
+	}
+	for i := 2; i < len(options); i++ {
+		if options[i-1].Text.Text > options[i].Text.Text {
+			t.Errorf("got %q before %q, want the options sorted by name", options[i-1].Text.Text, options[i].Text.Text)
+		}
+	}
+}
+
+func TestSectionOptionsLimit(t *testing.T) {
+	var sections []string
+	for i := 0; i < maxSelectOptions+20; i++ {
+		sections = append(sections, fmt.Sprintf("section%03d", i))
+	}
+	b, _ := newTestBot(t, &fakeNews{sections: sections}, nil)
+	if got := len(b.addNewsSectionsOptions()); got != maxSelectOptions {
+		t.Errorf("got %d options, want slack's limit of %d", got, maxSelectOptions)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gated features, which can be turned off globally and overridden per request by admins
+const (
+	featureAuthor   = "author"
+	featureBriefing = "briefing"
+	featurePopular  = "popular"
+	featureLang     = "lang"
+)
+
+var knownFeatures = []string{featureAuthor, featureBriefing, featurePopular, featureLang}
+
+const featureDisabledMessage = "⚠️ That feature isn't available right now."
+
+// featureFlags tells which gated features are enabled. Features missing from the map are enabled.
+type featureFlags map[string]bool
+
+// parseFeatureFlags parses flags in the 'feature=bool' format, rejecting unknown features
+func parseFeatureFlags(entries []string) (featureFlags, error) {
+	flags := featureFlags{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !isKnownFeature(parts[0]) {
+			return nil, fmt.Errorf("invalid feature flag %q, expected 'feature=true|false' with a feature in %v", entry, knownFeatures)
+		}
+		enabled, err := strconv.ParseBool(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature flag %q: %w", entry, err)
+		}
+		flags[parts[0]] = enabled
+	}
+	return flags, nil
+}
+
+func isKnownFeature(feature string) bool {
+	for _, f := range knownFeatures {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether a feature is enabled
+func (f featureFlags) enabled(feature string) bool {
+	enabled, ok := f[feature]
+	return !ok || enabled
+}
+
+// with returns a copy of the flags with the overrides applied on top
+func (f featureFlags) with(overrides featureFlags) featureFlags {
+	result := make(featureFlags, len(f)+len(overrides))
+	for feature, enabled := range f {
+		result[feature] = enabled
+	}
+	for feature, enabled := range overrides {
+		result[feature] = enabled
+	}
+	return result
+}
+
+// featureOverrideFlags maps the flags overriding a feature to whether they enable it
+var featureOverrideFlags = map[string]bool{"--enable": true, "--disable": false}
+
+// extractFeatureOverrides extracts the '--enable <feature>' and '--disable <feature>' flags from params,
+// returning the overrides, the sorted unknown features, which aren't overridden, and the params
+// without the flags. The flags can be repeated, the last one wins for a feature.
+func extractFeatureOverrides(params string) (featureFlags, []string, string) {
+	overrides := featureFlags{}
+	var unknown []string
+	var words []string
+	fields := strings.Fields(params)
+	for i := 0; i < len(fields); i++ {
+		enabled, ok := featureOverrideFlags[fields[i]]
+		if !ok {
+			words = append(words, fields[i])
+			continue
+		}
+		if i+1 == len(fields) {
+			continue
+		}
+		i++
+		if feature := fields[i]; isKnownFeature(feature) {
+			overrides[feature] = enabled
+		} else {
+			unknown = append(unknown, feature)
+		}
+	}
+	sort.Strings(unknown)
+	return overrides, unknown, strings.Join(words, " ")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractFeatureOverrides(t *testing.T) {
+	tests := []struct {
+		params    string
+		overrides featureFlags
+		unknown   []string
+		rest      string
+	}{
+		{"stories world", featureFlags{}, nil, "stories world"},
+		{"popular --enable popular", featureFlags{featurePopular: true}, nil, "popular"},
+		{"stories world --disable lang --enable author", featureFlags{featureLang: false, featureAuthor: true}, nil, "stories world"},
+		{"stories --enable search world --enable magic", featureFlags{}, []string{"magic", "search"}, "stories world"},
+		{"popular --enable popular --enable author", featureFlags{featurePopular: true, featureAuthor: true}, nil, "popular"},
+		{"author --enable author --disable author", featureFlags{featureAuthor: false}, nil, "author"},
+		{"stories world --enable", featureFlags{}, nil, "stories world"},
+	}
+	for _, tt := range tests {
+		overrides, unknown, rest := extractFeatureOverrides(tt.params)
+		if !reflect.DeepEqual(overrides, tt.overrides) || !reflect.DeepEqual(unknown, tt.unknown) || strings.Join(strings.Fields(rest), " ") != tt.rest {
+			t.Errorf("extractFeatureOverrides(%q) = %v, %v, %q, want %v, %v, %q", tt.params, overrides, unknown, rest, tt.overrides, tt.unknown, tt.rest)
+		}
+	}
+}
+
+func TestFeatureFlagsWith(t *testing.T) {
+	flags := featureFlags{featurePopular: false, featureAuthor: false}
+	overridden := flags.with(featureFlags{featurePopular: true})
+	if !overridden.enabled(featurePopular) || overridden.enabled(featureAuthor) || !overridden.enabled(featureLang) {
+		t.Errorf("got %v, want popular enabled over the disabled flags", overridden)
+	}
+	if flags.enabled(featurePopular) {
+		t.Error("the override changed the global flags")
+	}
+}
+
+func TestFeatureOverridesPerRequest(t *testing.T) {
+	news := &fakeNews{popular: []Article{testArticle("Popular story")}}
+	tests := []struct {
+		name   string
+		userID string
+		text   string
+		// texts are the texts expected in the responses, in order
+		texts []string
+	}{
+		{"admin enables a disabled feature", "U0ADMIN", "popular --enable popular", []string{"Popular story"}},
+		{"admin with an unknown feature", "U0ADMIN", "popular --enable popular --enable magic", []string{
+			"ℹ️ Ignored the unknown features `magic`, the features are `author`, `briefing`, `popular`, `lang`.",
+			"Popular story",
+		}},
+		{"non admin", "U0TEST", "popular --enable popular", []string{
+			"ℹ️ Feature overrides are only available to admins, so they were ignored.",
+			featureDisabledMessage,
+		}},
+		{"without overrides", "U0ADMIN", "popular", []string{featureDisabledMessage}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, news, func(cfg *Config) {
+				cfg.features = featureFlags{featurePopular: false}
+				cfg.adminUserIDs = []string{"U0ADMIN"}
+			})
+			req := testCommandRequest(fake)
+			req.userID = tt.userID
+			responses := runCommand(t, b, fake, req, tt.text)
+			if len(responses) != len(tt.texts) {
+				t.Fatalf("got %d responses, want %d", len(responses), len(tt.texts))
+			}
+			for i, text := range tt.texts {
+				if !strings.Contains(responses[i].text(), text) {
+					t.Errorf("response %d doesn't show %q: %s", i, text, responses[i].text())
+				}
+			}
+			if b.features.enabled(featurePopular) {
+				t.Error("the override changed the global flags")
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// extractFlag reports whether flag is present in params, and returns the params without it
+func extractFlag(params string, flag string) (bool, string) {
+	found := false
+	var words []string
+	for _, w := range strings.Fields(params) {
+		if w == flag {
+			found = true
+			continue
+		}
+		words = append(words, w)
+	}
+	return found, strings.Join(words, " ")
+}
+
+// extractFlagValue returns the value following flag in params (e.g. '--lang es'), and the params
+// without the flag and its value. The value is empty when the flag is absent.
+func extractFlagValue(params string, flag string) (string, string) {
+	value := ""
+	var words []string
+	fields := strings.Fields(params)
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == flag {
+			if i+1 < len(fields) {
+				value = fields[i+1]
+				i++
+			}
+			continue
+		}
+		words = append(words, fields[i])
+	}
+	return value, strings.Join(words, " ")
+}
+
+// extractCount returns the number trailing params (e.g. 'world 5'), whether there was one, and the
+// params without it.
+func extractCount(params string) (int, bool, string) {
+	fields := strings.Fields(params)
+	if len(fields) == 0 {
+		return 0, false, params
+	}
+	count, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return 0, false, params
+	}
+	return count, true, strings.Join(fields[:len(fields)-1], " ")
+}
+
+// unquote trims the spaces and the surrounding quotes of a command argument.
+// Slack clients may turn straight quotes into curly ones, so both are supported.
+func unquote(s string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(s), `"“”'‘’`))
+}
+
+// maxSearchQueryLength is the maximum number of characters of a search query sent to NYT
+const maxSearchQueryLength = 100
+
+// searchQuerySpecialChars are the Lucene special characters removed from the search queries.
+// Hyphens and apostrophes are kept, since they're common in names.
+const searchQuerySpecialChars = `"\(){}[]^~*?:/!+&|`
+
+// errEmptySearchQuery is returned when nothing is left of a search query once sanitized
+var errEmptySearchQuery = errors.New("empty search query")
+
+// sanitizeSearchQuery prepares a query for the NYT Article Search API: control and Lucene special
+// characters are removed, spaces collapsed and the query truncated to maxSearchQueryLength.
+func sanitizeSearchQuery(q string) (string, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(searchQuerySpecialChars, r) {
+			return ' '
+		}
+		return r
+	}, q)
+	query := []rune(strings.Join(strings.Fields(cleaned), " "))
+	if len(query) > maxSearchQueryLength {
+		query = []rune(strings.TrimSpace(string(query[:maxSearchQueryLength])))
+	}
+	if len(query) == 0 {
+		return "", errEmptySearchQuery
+	}
+	return string(query), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExtractCount(t *testing.T) {
+	tests := []struct {
+		params     string
+		count      int
+		found      bool
+		wantParams string
+	}{
+		{"world 5", 5, true, "world"},
+		{"world 0", 0, true, "world"},
+		{"world -1", -1, true, "world"},
+		{"world", 0, false, "world"},
+		{"world five", 0, false, "world five"},
+		{"5", 5, true, ""},
+		{"", 0, false, ""},
+		{"  world   science  7 ", 7, true, "world science"},
+	}
+	for _, tt := range tests {
+		count, found, params := extractCount(tt.params)
+		if count != tt.count || found != tt.found || params != tt.wantParams {
+			t.Errorf("extractCount(%q) = %d, %t, %q, want %d, %t, %q",
+				tt.params, count, found, params, tt.count, tt.found, tt.wantParams)
+		}
+	}
+}
+
+func TestSanitizeSearchQuery(t *testing.T) {
+	long := strings.Repeat("climate ", 20)
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"climate policy", "climate policy"},
+		{"  climate   policy  ", "climate policy"},
+		{`"climate" AND (policy OR law)`, "climate AND policy OR law"},
+		{"title:climate^2 ~fuzzy* [a TO b] {c} a+b a&&b a||b !not back\\slash /path/", "title climate 2 fuzzy a TO b c a b a b a b not back slash path"},
+		{"Ocasio-Cortez O'Brien", "Ocasio-Cortez O'Brien"},
+		{"tab\tnew\nline\x00null\x7fdel", "tab new line null del"},
+		{"café Ünïcode", "café Ünïcode"},
+		{long, strings.TrimSpace(long[:maxSearchQueryLength])},
+		{strings.Repeat("é", 150), strings.Repeat("é", maxSearchQueryLength)},
+	}
+	for _, tt := range tests {
+		got, err := sanitizeSearchQuery(tt.query)
+		if err != nil || got != tt.want {
+			t.Errorf("sanitizeSearchQuery(%q) = %q, %v, want %q", tt.query, got, err, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); n > maxSearchQueryLength {
+			t.Errorf("sanitizeSearchQuery(%q) has %d characters, want at most %d", tt.query, n, maxSearchQueryLength)
+		}
+	}
+	for _, query := range []string{"", "   ", `"*?"`, "\x00\x01", "():[]"} {
+		if got, err := sanitizeSearchQuery(query); !errors.Is(err, errEmptySearchQuery) {
+			t.Errorf("sanitizeSearchQuery(%q) = %q, %v, want errEmptySearchQuery", query, got, err)
+		}
+	}
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	for _, text := range []string{"search", "search ***", `search "?"`, "author", `author "()"`} {
+		t.Run(text, func(t *testing.T) {
+			news := &fakeNews{}
+			b, fake := newTestBot(t, news, nil)
+			responses := runCommand(t, b, fake, testCommandRequest(fake), text)
+			if len(responses) != 1 || !strings.HasPrefix(responses[0].message["text"].(string), "⚠️ Tell us") {
+				t.Fatalf("got responses %+v, want the hint about the query", responses)
+			}
+			if responses[0].message["response_type"] != "ephemeral" {
+				t.Error("the hint is shown to the whole channel")
+			}
+			if requests := news.requested(); len(requests) > 0 {
+				t.Errorf("requested %v for an empty query", requests)
+			}
+		})
+	}
+}
@@ -1,9 +1,22 @@
 module github.com/commit-app-playground/taina-backend
 
-go 1.16
+go 1.21
 
 require (
 	github.com/joho/godotenv v1.4.0
+	github.com/prometheus/client_golang v1.19.1
 	github.com/slack-go/slack v0.9.5
 	github.com/tainacleal/nyt-go v0.1.1
 )
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/pkg/errors v0.8.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
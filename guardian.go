@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	guardianBaseURL = "https://content.guardianapis.com"
+	// guardianTimeout bounds each request to the Guardian
+	guardianTimeout = 10 * time.Second
+	// guardianMaxStories is the most results the Guardian returns in a page
+	guardianMaxStories = 50
+)
+
+func init() {
+	registerNewsProvider("guardian", newGuardianProvider)
+}
+
+// newGuardianProvider is the news provider of the Guardian, configured by GUARDIAN_API_KEY
+func newGuardianProvider(cfg Config, observe requestObserver, logger *slog.Logger) (NewsSource, error) {
+	if cfg.guardianAPIKey == "" {
+		return nil, errors.New("missing GUARDIAN_API_KEY")
+	}
+	return NewGuardian(cfg.guardianAPIKey, observe, logger), nil
+}
+
+// guardianSections maps the sections of the commands to the Guardian section IDs, in display
+// order. The home section isn't filtered by section.
+var guardianSections = []struct {
+	section string
+	id      string
+	name    string
+}{
+	{"home", "", "Home"},
+	{"world", "world", "World"},
+	{"us", "us-news", "U.S."},
+	{"politics", "politics", "Politics"},
+	{"business", "business", "Business"},
+	{"technology", "technology", "Technology"},
+	{"science", "science", "Science"},
+	{"sports", "sport", "Sports"},
+	{"arts", "culture", "Arts"},
+	{"books", "books", "Books"},
+	{"movies", "film", "Movies"},
+	{"theater", "stage", "Theater"},
+	{"fashion", "fashion", "Fashion"},
+	{"food", "food", "Food"},
+	{"travel", "travel", "Travel"},
+}
+
+// Guardian can communicate with the Guardian Open Platform API. It implements the NewsSource interface.
+type Guardian struct {
+	APIKey string
+
+	baseURL    string
+	httpClient *http.Client
+	// gate fails the requests fast while the Guardian rate limits us
+	gate *backoffGate
+
+	// sections lists the supported sections in display order, sectionIDs maps them to the
+	// Guardian section IDs, sectionsByID the other way around and names to their display name
+	sections     []string
+	sectionIDs   map[string]string
+	sectionsByID map[string]string
+	names        map[string]string
+
+	// observe is called after each request with the section it was for, if set
+	observe requestObserver
+	logger  *slog.Logger
+}
+
+func NewGuardian(apiKey string, observe requestObserver, logger *slog.Logger) *Guardian {
+	g := &Guardian{
+		APIKey:       apiKey,
+		baseURL:      guardianBaseURL,
+		httpClient:   &http.Client{Timeout: guardianTimeout},
+		gate:         newBackoffGate(),
+		sectionIDs:   map[string]string{},
+		sectionsByID: map[string]string{},
+		names:        map[string]string{},
+		observe:      observe,
+		logger:       logger,
+	}
+	for _, s := range guardianSections {
+		g.sections = append(g.sections, s.section)
+		g.sectionIDs[s.section] = s.id
+		if s.id != "" {
+			g.sectionsByID[s.id] = s.section
+		}
+		g.names[s.section] = s.name
+	}
+	return g
+}
+
+// guardianSearchResponse is the response of the Guardian content search
+type guardianSearchResponse struct {
+	Response struct {
+		Status  string            `json:"status"`
+		Message string            `json:"message"`
+		Results []guardianContent `json:"results"`
+	} `json:"response"`
+}
+
+// guardianContent is a piece of content of the Guardian, with the fields requested by
+// guardianFields
+type guardianContent struct {
+	Type               string `json:"type"`
+	SectionID          string `json:"sectionId"`
+	WebTitle           string `json:"webTitle"`
+	WebURL             string `json:"webUrl"`
+	WebPublicationDate string `json:"webPublicationDate"`
+	PillarName         string `json:"pillarName"`
+	Fields             struct {
+		TrailText    string `json:"trailText"`
+		Thumbnail    string `json:"thumbnail"`
+		LastModified string `json:"lastModified"`
+	} `json:"fields"`
+}
+
+// guardianFields are the optional fields requested with the content
+const guardianFields = "trailText,thumbnail,lastModified"
+
+// htmlTagPattern matches the tags of the trail texts, which are HTML
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// article maps the content to an Article, it returns false for content without a title or URL
+func (g *Guardian) article(c guardianContent) (Article, bool) {
+	if c.WebTitle == "" || c.WebURL == "" {
+		return Article{}, false
+	}
+	article := Article{
+		Title:        c.WebTitle,
+		Abstract:     strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(c.Fields.TrailText, ""))),
+		URL:          c.WebURL,
+		CanonicalURL: c.WebURL,
+		Breaking:     c.Type == "liveblog",
+		Section:      g.sectionsByID[c.SectionID],
+		NewsDesk:     c.PillarName,
+	}
+	if thumbnail, err := url.Parse(c.Fields.Thumbnail); err == nil && thumbnail.Scheme == "https" && thumbnail.Host != "" {
+		article.ImageURL = c.Fields.Thumbnail
+	}
+	if publishedAt, err := time.Parse(time.RFC3339, c.WebPublicationDate); err == nil {
+		article.PublishedAt = publishedAt.Local().Format("January 02, 2006")
+		article.PublishedTime = publishedAt
+	}
+	if updatedAt, err := time.Parse(time.RFC3339, c.Fields.LastModified); err == nil {
+		article.UpdatedTime = updatedAt
+	}
+	return article, true
+}
+
+// search queries the Guardian content search, returning at most topN articles. section labels
+// the request for the observer.
+func (g *Guardian) search(ctx context.Context, section string, query url.Values, topN int) (articles []Article, err error) {
+	if g.observe != nil {
+		start := time.Now()
+		defer func() { g.observe(section, time.Since(start), err) }()
+	}
+	if !g.gate.open() {
+		return nil, ErrRateLimited
+	}
+
+	values := url.Values{}
+	for key, value := range query {
+		values[key] = value
+	}
+	values.Set("api-key", g.APIKey)
+	values.Set("show-fields", guardianFields)
+	values.Set("page-size", strconv.Itoa(topN))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/search?"+values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		backoff := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		g.logger.Warn("rate limited by the Guardian, backing off", "backoff", backoff)
+		g.gate.backoff(backoff)
+		return nil, ErrRateLimited
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: request status: %d", ErrProductNotEnabled, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("request status: %d", resp.StatusCode)
+	case !isJSONContentType(resp.Header.Get("Content-Type")):
+		return nil, ErrUpstreamUnavailable
+	}
+
+	var body guardianSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Response.Status != "ok" {
+		return nil, fmt.Errorf("guardian error: %s", body.Response.Message)
+	}
+
+	articles = []Article{}
+	for _, c := range body.Response.Results {
+		if len(articles) == topN {
+			break
+		}
+		if article, ok := g.article(c); ok {
+			articles = append(articles, article)
+		}
+	}
+	return articles, nil
+}
+
+// TopStories retrieves the latest stories of a section from the Guardian
+func (g *Guardian) TopStories(ctx context.Context, section string, topN int) ([]Article, error) {
+	section = normalizeSection(section)
+	id, ok := g.sectionIDs[section]
+	if !ok {
+		return nil, ErrInvalidSection
+	}
+	query := url.Values{}
+	query.Set("order-by", "newest")
+	if id != "" {
+		query.Set("section", id)
+	}
+	return g.search(ctx, section, query, topN)
+}
+
+// PopularStories isn't served by the Guardian content API, it returns ErrUnsupported
+func (g *Guardian) PopularStories(ctx context.Context, metric string, period int) ([]Article, error) {
+	return nil, fmt.Errorf("%w: popular stories", ErrUnsupported)
+}
+
+// SearchByAuthor retrieves the most recent Guardian articles written by author
+func (g *Guardian) SearchByAuthor(ctx context.Context, author string, topN int) ([]Article, error) {
+	query := url.Values{}
+	query.Set("q", strconv.Quote(author))
+	query.Set("query-fields", "byline")
+	query.Set("order-by", "newest")
+	return g.search(ctx, "search", query, topN)
+}
+
+// SearchArticles retrieves the most recent Guardian articles matching a keyword query
+func (g *Guardian) SearchArticles(ctx context.Context, q string, limit int) ([]Article, error) {
+	query := url.Values{}
+	query.Set("q", q)
+	query.Set("order-by", "newest")
+	return g.search(ctx, "search", query, limit)
+}
+
+// LocalizedStories only serves English, the Guardian publishes in no other language
+func (g *Guardian) LocalizedStories(ctx context.Context, section string, lang string, topN int) ([]Article, error) {
+	if _, ok := g.sectionIDs[normalizeSection(section)]; !ok {
+		return nil, ErrInvalidSection
+	}
+	if lang != "en" {
+		return nil, ErrLanguageUnavailable
+	}
+	return g.TopStories(ctx, section, topN)
+}
+
+// ArchiveStories retrieves the Guardian articles published on a day, at most a page of them
+func (g *Guardian) ArchiveStories(ctx context.Context, year int, month time.Month, day int) ([]Article, error) {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	query := url.Values{}
+	query.Set("from-date", date)
+	query.Set("to-date", date)
+	query.Set("order-by", "oldest")
+	return g.search(ctx, "archive", query, guardianMaxStories)
+}
+
+// SupportedSections returns the names of the supported sections
+func (g *Guardian) SupportedSections() []string {
+	return append([]string(nil), g.sections...)
+}
+
+// BrandColor returns the color used to highlight Guardian stories
+func (g *Guardian) BrandColor() string {
+	return "#052962"
+}
+
+// MaxStories returns the size of a page of Guardian results
+func (g *Guardian) MaxStories() int {
+	return guardianMaxStories
+}
+
+// UserFriendlySection receives a section name and returns the user readable name for it
+func (g *Guardian) UserFriendlySection(section string) string {
+	section = normalizeSection(section)
+	if name, ok := g.names[section]; ok {
+		return name
+	}
+	return titleCase(section)
+}
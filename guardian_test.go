@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestGuardian returns a Guardian client requesting a server with handler
+func newTestGuardian(t *testing.T, handler http.HandlerFunc) *Guardian {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	g := NewGuardian("test-key", nil, discardLogger())
+	g.baseURL = server.URL
+	return g
+}
+
+// guardianResponse is a Guardian search response with the given results
+func guardianResponse(results ...map[string]interface{}) map[string]interface{} {
+	if results == nil {
+		results = []map[string]interface{}{}
+	}
+	return map[string]interface{}{"response": map[string]interface{}{"status": "ok", "results": results}}
+}
+
+// guardianContentJSON is a Guardian article in the given section, as the search returns it
+func guardianContentJSON(title string, sectionID string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":               "article",
+		"sectionId":          sectionID,
+		"webTitle":           title,
+		"webUrl":             "https://www.theguardian.com/" + sectionID + "/" + url.PathEscape(title),
+		"webPublicationDate": "2024-03-14T08:00:00Z",
+		"pillarName":         "News",
+		"fields": map[string]interface{}{
+			"trailText":    "The <strong>abstract</strong> of " + title + " &amp; more",
+			"thumbnail":    "https://media.guim.co.uk/" + url.PathEscape(title) + ".jpg",
+			"lastModified": "2024-03-14T10:00:00Z",
+		},
+	}
+}
+
+func TestGuardianTopStories(t *testing.T) {
+	var query url.Values
+	g := newTestGuardian(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" {
+			t.Errorf("got path %s, want /search", r.URL.Path)
+		}
+		query = r.URL.Query()
+		live := guardianContentJSON("Live", "world")
+		live["type"] = "liveblog"
+		live["fields"].(map[string]interface{})["thumbnail"] = "http://media.guim.co.uk/insecure.jpg"
+		untitled := guardianContentJSON("", "world")
+		writeJSONResponse(t, w, guardianResponse(guardianContentJSON("Story", "world"), untitled, live, guardianContentJSON("Extra", "world")))
+	})
+
+	articles, err := g.TopStories(context.Background(), "World", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"api-key": "test-key", "section": "world", "page-size": "2", "order-by": "newest", "show-fields": guardianFields} {
+		if got := query.Get(key); got != want {
+			t.Errorf("got %s=%q, want %q", key, got, want)
+		}
+	}
+	if len(articles) != 2 {
+		t.Fatalf("got %d stories, want the 2 requested with a title", len(articles))
+	}
+
+	story := articles[0]
+	want := Article{
+		Title:         "Story",
+		Abstract:      "The abstract of Story & more",
+		URL:           "https://www.theguardian.com/world/Story",
+		CanonicalURL:  "https://www.theguardian.com/world/Story",
+		ImageURL:      "https://media.guim.co.uk/Story.jpg",
+		Section:       "world",
+		NewsDesk:      "News",
+		PublishedTime: time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC),
+		UpdatedTime:   time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC),
+	}
+	if story.Title != want.Title || story.Abstract != want.Abstract || story.URL != want.URL || story.CanonicalURL != want.CanonicalURL ||
+		story.ImageURL != want.ImageURL || story.Section != want.Section || story.NewsDesk != want.NewsDesk || story.Breaking {
+		t.Errorf("got story %+v, want %+v", story, want)
+	}
+	if !story.PublishedTime.Equal(want.PublishedTime) || !story.UpdatedTime.Equal(want.UpdatedTime) || story.PublishedAt == "" {
+		t.Errorf("got published %s (%q) and updated %s, want %s and %s", story.PublishedTime, story.PublishedAt, story.UpdatedTime, want.PublishedTime, want.UpdatedTime)
+	}
+
+	live := articles[1]
+	if !live.Breaking {
+		t.Error("the live blog isn't breaking news")
+	}
+	if live.ImageURL != "" {
+		t.Errorf("got image %q, want the insecure thumbnail dropped", live.ImageURL)
+	}
+}
+
+func TestGuardianSections(t *testing.T) {
+	var mu sync.Mutex
+	var queries []url.Values
+	g := newTestGuardian(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query())
+		mu.Unlock()
+		writeJSONResponse(t, w, guardianResponse())
+	})
+	ctx := context.Background()
+
+	if _, err := g.TopStories(ctx, "home", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.TopStories(ctx, "us", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := queries[0]["section"]; ok {
+		t.Errorf("got section %q for home, want every section", queries[0].Get("section"))
+	}
+	if got := queries[1].Get("section"); got != "us-news" {
+		t.Errorf("got section %q for us, want us-news", got)
+	}
+
+	if _, err := g.TopStories(ctx, "atlantis", 3); !errors.Is(err, ErrInvalidSection) {
+		t.Errorf("got error %v, want ErrInvalidSection", err)
+	}
+	if len(queries) != 2 {
+		t.Errorf("sent %d requests, want none for an unsupported section", len(queries))
+	}
+
+	sections := g.SupportedSections()
+	if len(sections) != len(guardianSections) || sections[0] != "home" {
+		t.Errorf("got sections %v", sections)
+	}
+	for section, want := range map[string]string{"us": "U.S.", "Movies": "Movies", "obituaries": "Obituaries"} {
+		if got := g.UserFriendlySection(section); got != want {
+			t.Errorf("UserFriendlySection(%q) = %q, want %q", section, got, want)
+		}
+	}
+}
+
+func TestGuardianSearches(t *testing.T) {
+	var query url.Values
+	g := newTestGuardian(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		writeJSONResponse(t, w, guardianResponse(guardianContentJSON("Story", "science")))
+	})
+	ctx := context.Background()
+
+	if _, err := g.SearchByAuthor(ctx, "Jane Doe", 3); err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("q") != `"Jane Doe"` || query.Get("query-fields") != "byline" {
+		t.Errorf("got query %v, want the quoted author in the bylines", query)
+	}
+
+	articles, err := g.SearchArticles(ctx, "climate", 3)
+	if err != nil || len(articles) != 1 {
+		t.Fatalf("got %d results, %v", len(articles), err)
+	}
+	if query.Get("q") != "climate" || query.Get("query-fields") != "" {
+		t.Errorf("got query %v, want the keywords", query)
+	}
+
+	if _, err := g.ArchiveStories(ctx, 2020, time.February, 29); err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("from-date") != "2020-02-29" || query.Get("to-date") != "2020-02-29" {
+		t.Errorf("got query %v, want the stories of the day", query)
+	}
+
+	if _, err := g.PopularStories(ctx, "viewed", 1); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("got error %v, want ErrUnsupported", err)
+	}
+	if _, err := g.LocalizedStories(ctx, "world", "fr", 3); !errors.Is(err, ErrLanguageUnavailable) {
+		t.Errorf("got error %v, want ErrLanguageUnavailable", err)
+	}
+	if _, err := g.LocalizedStories(ctx, "world", "en", 3); err != nil {
+		t.Errorf("got error %v for the stories in English", err)
+	}
+}
+
+func TestGuardianErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+		message string
+	}{
+		{"unauthorized", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}, ErrProductNotEnabled, ""},
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}, nil, "502"},
+		{"html", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>maintenance</html>"))
+		}, ErrUpstreamUnavailable, ""},
+		{"error status", func(w http.ResponseWriter, r *http.Request) {
+			writeJSONResponse(t, w, map[string]interface{}{"response": map[string]interface{}{"status": "error", "message": "The api key provided is invalid"}})
+		}, nil, "The api key provided is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGuardian(t, tt.handler)
+			_, err := g.TopStories(context.Background(), "world", 3)
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("got error %v, want it to mention %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestGuardianRateLimited(t *testing.T) {
+	requests := 0
+	g := newTestGuardian(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := g.TopStories(ctx, "world", 3); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("got error %v, want ErrRateLimited", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want the next ones to wait for Retry-After", requests)
+	}
+}
+
+func TestGuardianObserver(t *testing.T) {
+	var observed []string
+	g := newTestGuardian(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, guardianResponse())
+	})
+	g.observe = func(section string, elapsed time.Duration, err error) {
+		observed = append(observed, section)
+	}
+	ctx := context.Background()
+	g.TopStories(ctx, "science", 3)
+	g.SearchArticles(ctx, "climate", 3)
+	if got := strings.Join(observed, ", "); got != "science, search" {
+		t.Errorf("observed %q, want the section and the search", got)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readinessTimeout bounds the news source request checking the readiness
+	readinessTimeout = 2 * time.Second
+	// readinessTTL is how long the result of a readiness check is reused. The probes run every
+	// few seconds on every pod, and each check spends a request of the API key's quota.
+	readinessTTL = 5 * time.Minute
+	// readinessRetryTTL is how long a failed check is reused, so a pod recovers sooner
+	readinessRetryTTL = time.Minute
+)
+
+// handleHealthz is the liveness probe. It only confirms the process is serving, so it doesn't
+// depend on the news source being reachable.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ----//----
+
+// readiness checks the bot can serve the commands, by requesting a single top story from the news source.
+// Being rate limited still counts as ready: the news source is reachable, and failing every pod at
+// once would only take the whole service down. It is safe for concurrent use.
+type readiness struct {
+	newsSource NewsSource
+	logger     *slog.Logger
+	now        func() time.Time
+
+	mu sync.Mutex
+	// checkedAt is the time of the last check and err its result
+	checkedAt time.Time
+	err       error
+}
+
+func newReadiness(newsSource NewsSource, logger *slog.Logger) *readiness {
+	return &readiness{newsSource: newsSource, logger: logger, now: time.Now}
+}
+
+// check returns the result of the last check while it is fresh, checking again otherwise. The
+// checks are serialized, so concurrent probes share the same request.
+func (c *readiness) check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ttl := readinessTTL
+	if c.err != nil {
+		ttl = readinessRetryTTL
+	}
+	if !c.checkedAt.IsZero() && c.now().Sub(c.checkedAt) < ttl {
+		return c.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	_, err := c.newsSource.TopStories(ctx, defaultSection, 1)
+	if errors.Is(err, ErrRateLimited) {
+		err = nil
+	}
+	c.err = err
+	c.checkedAt = c.now()
+	return c.err
+}
+
+// ServeHTTP is the readiness probe, answering 503 while the news source is unavailable
+func (c *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := c.check(r.Context()); err != nil {
+		c.logger.Warn("readiness check failed", "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
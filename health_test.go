@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleHealthz))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got content type %q, want JSON", got)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != "ok" {
+		t.Errorf("got body %v, want the ok status", body)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   string
+	}{
+		{"ready", nil, http.StatusOK, "ready"},
+		{"not ready", errors.New("connection refused"), http.StatusServiceUnavailable, "unavailable"},
+		{"rate limited", fmt.Errorf("top stories of home: %w", ErrRateLimited), http.StatusOK, "ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			news := &fakeNews{stories: map[string][]Article{"home": testArticles(3)}, err: tt.err}
+			server := httptest.NewServer(newReadiness(news, discardLogger()))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/readyz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body["status"] != tt.body {
+				t.Errorf("got body %v, want the %s status", body, tt.body)
+			}
+			if got := strings.Join(news.requested(), ", "); got != "top "+defaultSection {
+				t.Errorf("got requests %q, want a single top stories request", got)
+			}
+		})
+	}
+}
+
+func TestReadinessReusesChecks(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"home": testArticles(1)}}
+	clock := newFakeClock()
+	r := newReadiness(news, discardLogger())
+	r.now = clock.Now
+	ctx := context.Background()
+
+	// checks counts the requests of the checks run as the clock moves a minute at a time
+	checks := func(minutes int) int {
+		before := len(news.requested())
+		for i := 0; i < minutes; i++ {
+			r.check(ctx)
+			clock.advance(time.Minute)
+		}
+		return len(news.requested()) - before
+	}
+	if got := checks(10); got != 2 {
+		t.Errorf("checked %d times in 10 minutes while ready, want every %s", got, readinessTTL)
+	}
+
+	news.mu.Lock()
+	news.err = errors.New("boom")
+	news.mu.Unlock()
+	clock.advance(readinessTTL)
+	if err := r.check(ctx); err == nil {
+		t.Fatal("got ready after the news source failed")
+	}
+	if got := checks(5); got != 4 {
+		t.Errorf("checked %d times in 5 minutes while not ready, want every %s", got, readinessRetryTTL)
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// testNow is the fixed clock of the tests
+var testNow = time.Date(2024, time.March, 14, 12, 0, 0, 0, time.UTC)
+
+// fakeClock is a clock that only moves when told to. It is safe for concurrent use.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: testNow}
+}
+
+// Now returns the time of the clock
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// advance moves the clock forward by d
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testArticle is a complete story, with an abstract, a date and an image
+func testArticle(title string) Article {
+	return Article{
+		Title:         title,
+		Abstract:      "The abstract of " + title,
+		URL:           "https://nyti.ms/" + url.PathEscape(title),
+		PublishedAt:   "March 13, 2024",
+		PublishedTime: testNow.Add(-24 * time.Hour),
+		ImageURL:      "https://static01.nyt.com/" + url.PathEscape(title) + ".jpg",
+	}
+}
+
+// testArticles returns n stories titled 'Story 1' to 'Story n'
+func testArticles(n int) []Article {
+	var articles []Article
+	for i := 1; i <= n; i++ {
+		articles = append(articles, testArticle(fmt.Sprintf("Story %d", i)))
+	}
+	return articles
+}
+
+// jsonBlocks decodes blocks into the JSON slack receives, to assert on it
+func jsonBlocks(t *testing.T, blocks []slack.Block) []map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		t.Fatalf("encoding blocks: %v", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decoding blocks: %v", err)
+	}
+	return decoded
+}
+
+// jsonString encodes v, e.g. to look for a text anywhere in some blocks
+func jsonString(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encoding %T: %v", v, err)
+	}
+	return string(data)
+}
+
+// blockTypes lists the types of the blocks, in order
+func blockTypes(blocks []map[string]interface{}) []string {
+	var types []string
+	for _, block := range blocks {
+		types = append(types, block["type"].(string))
+	}
+	return types
+}
+
+// messageValues applies the message options the way chat.postMessage sends them
+func messageValues(t *testing.T, options ...slack.MsgOption) url.Values {
+	t.Helper()
+	_, values, err := slack.UnsafeApplyMsgOptions("token", "C0123456", "https://slack.com/api/", options...)
+	if err != nil {
+		t.Fatalf("applying message options: %v", err)
+	}
+	return values
+}
+
+// messageBlocks decodes the blocks of a message
+func messageBlocks(t *testing.T, options ...slack.MsgOption) []map[string]interface{} {
+	t.Helper()
+	var blocks []map[string]interface{}
+	if data := messageValues(t, options...).Get("blocks"); data != "" {
+		if err := json.Unmarshal([]byte(data), &blocks); err != nil {
+			t.Fatalf("decoding message blocks: %v", err)
+		}
+	}
+	return blocks
+}
+
+// newTestNYTimes returns a NYT source sending its requests to handler
+func newTestNYTimes(t *testing.T, handler http.HandlerFunc, options ...NYTimesOption) *NYTimes {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	nyt, err := NewNYTimes("test-key", append([]NYTimesOption{WithRetryDelay(0)}, options...)...)
+	if err != nil {
+		t.Fatalf("NewNYTimes: %v", err)
+	}
+	t.Cleanup(nyt.Close)
+	nyt.baseURL = server.URL
+	return nyt
+}
+
+// writeJSONResponse answers a request with v encoded as JSON
+func writeJSONResponse(t *testing.T, w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("encoding response: %v", err)
+	}
+}
+
+// topStoriesResponse is a NYT top stories response with the given stories, each a map of the
+// JSON fields of a story
+func topStoriesResponse(stories ...map[string]interface{}) map[string]interface{} {
+	if stories == nil {
+		stories = []map[string]interface{}{}
+	}
+	return map[string]interface{}{"status": "OK", "results": stories}
+}
+
+// nytStory is the JSON of a NYT top story with a title and links
+func nytStory(title string) map[string]interface{} {
+	return map[string]interface{}{
+		"title":          title,
+		"abstract":       "The abstract of " + title,
+		"url":            "https://www.nytimes.com/2024/03/14/" + url.PathEscape(title) + ".html",
+		"short_url":      "https://nyti.ms/" + url.PathEscape(title),
+		"published_date": "2024-03-14T08:00:00-04:00",
+		"updated_date":   "2024-03-14T08:00:00-04:00",
+	}
+}
+
+// fakeNews is a news source serving fixed stories, counting the requests it gets
+type fakeNews struct {
+	mu sync.Mutex
+	// stories are the top stories of each section
+	stories map[string][]Article
+	// localized are the top stories of the sections in other languages, keyed by 'lang section'
+	localized map[string][]Article
+	// popular are the popular stories, found are the results of the searches and archive the
+	// stories of the archive
+	popular []Article
+	found   []Article
+	archive []Article
+	// err is returned by every request when set
+	err error
+	// sections are the supported sections, the sections of stories when empty
+	sections   []string
+	maxStories int
+	// requests lists the requests received, e.g. 'top world' or 'search climate'
+	requests []string
+}
+
+func (f *fakeNews) record(request string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request)
+	return f.err
+}
+
+// requested returns the requests received so far
+func (f *fakeNews) requested() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.requests...)
+}
+
+func firstStories(articles []Article, n int) []Article {
+	if n > 0 && n < len(articles) {
+		articles = articles[:n]
+	}
+	return append([]Article{}, articles...)
+}
+
+func (f *fakeNews) TopStories(ctx context.Context, section string, topN int) ([]Article, error) {
+	if err := f.record("top " + section); err != nil {
+		return nil, err
+	}
+	return firstStories(f.stories[section], topN), nil
+}
+
+func (f *fakeNews) PopularStories(ctx context.Context, metric string, period int) ([]Article, error) {
+	if err := f.record("popular " + metric); err != nil {
+		return nil, err
+	}
+	return firstStories(f.popular, 0), nil
+}
+
+func (f *fakeNews) SearchByAuthor(ctx context.Context, author string, topN int) ([]Article, error) {
+	if err := f.record("author " + author); err != nil {
+		return nil, err
+	}
+	return firstStories(f.found, topN), nil
+}
+
+func (f *fakeNews) SearchArticles(ctx context.Context, query string, limit int) ([]Article, error) {
+	if err := f.record("search " + query); err != nil {
+		return nil, err
+	}
+	return firstStories(f.found, limit), nil
+}
+
+func (f *fakeNews) LocalizedStories(ctx context.Context, section string, lang string, topN int) ([]Article, error) {
+	if err := f.record("localized " + lang + " " + section); err != nil {
+		return nil, err
+	}
+	articles, ok := f.localized[lang+" "+section]
+	if !ok {
+		return nil, ErrLanguageUnavailable
+	}
+	return firstStories(articles, topN), nil
+}
+
+func (f *fakeNews) ArchiveStories(ctx context.Context, year int, month time.Month, day int) ([]Article, error) {
+	if err := f.record("archive " + time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02")); err != nil {
+		return nil, err
+	}
+	return firstStories(f.archive, 0), nil
+}
+
+func (f *fakeNews) SupportedSections() []string {
+	if len(f.sections) > 0 {
+		return f.sections
+	}
+	var sections []string
+	for section := range f.stories {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+func (f *fakeNews) UserFriendlySection(section string) string {
+	return titleCase(section)
+}
+
+func (f *fakeNews) BrandColor() string {
+	return "#567b95"
+}
+
+func (f *fakeNews) MaxStories() int {
+	if f.maxStories > 0 {
+		return f.maxStories
+	}
+	return 20
+}
+
+// slackCall is a request received by the fake slack server
+type slackCall struct {
+	// method is the API method, e.g. 'chat.postMessage', or 'response' for the response URL
+	method string
+	values url.Values
+	// message is the JSON body of the call, e.g. the message posted to the response URL
+	message map[string]interface{}
+}
+
+// fakeSlack is a slack API answering every call successfully unless told otherwise, and
+// recording the calls it receives. Its response URL is responseURL.
+type fakeSlack struct {
+	t           *testing.T
+	server      *httptest.Server
+	responseURL string
+
+	mu    sync.Mutex
+	calls []slackCall
+	// errors are returned, in order, by the next calls to a method
+	errors map[string][]string
+	// responseStatus is the HTTP status of the response URL when set
+	responseStatus int
+	// handling is called with the method of each call before it is answered, e.g. to delay it
+	handling func(method string)
+}
+
+func newFakeSlack(t *testing.T) *fakeSlack {
+	t.Helper()
+	f := &fakeSlack{t: t, errors: map[string][]string{}}
+	f.server = httptest.NewTLSServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	f.responseURL = f.server.URL + "/response"
+	return f
+}
+
+// options point a slack client to the fake server
+func (f *fakeSlack) options() []slack.Option {
+	return []slack.Option{slack.OptionAPIURL(f.server.URL + "/api/"), slack.OptionHTTPClient(f.server.Client())}
+}
+
+// failNext makes the next calls to method fail with the given slack errors, one per call
+func (f *fakeSlack) failNext(method string, codes ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[method] = append(f.errors[method], codes...)
+}
+
+func (f *fakeSlack) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	call := slackCall{method: strings.TrimPrefix(r.URL.Path, "/api/")}
+	if r.URL.Path == "/response" {
+		call.method = "response"
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&call.message); err != nil {
+			f.t.Errorf("decoding the %s call: %v", call.method, err)
+		}
+	} else if err := r.ParseForm(); err != nil {
+		f.t.Errorf("parsing the %s call: %v", call.method, err)
+	}
+	call.values = r.PostForm
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	var code string
+	if codes := f.errors[call.method]; len(codes) > 0 {
+		code, f.errors[call.method] = codes[0], codes[1:]
+	}
+	status := f.responseStatus
+	handling := f.handling
+	f.mu.Unlock()
+	if handling != nil {
+		handling(call.method)
+	}
+
+	switch {
+	case code != "":
+		writeJSONResponse(f.t, w, map[string]interface{}{"ok": false, "error": code})
+	case call.method == "response" && status != 0:
+		w.WriteHeader(status)
+	case call.method == "conversations.open":
+		writeJSONResponse(f.t, w, map[string]interface{}{"ok": true, "channel": map[string]string{"id": "D0TESTDM01"}})
+	case call.method == "conversations.setTopic":
+		channel := map[string]interface{}{"id": call.values.Get("channel"), "topic": map[string]string{"value": call.values.Get("topic")}}
+		writeJSONResponse(f.t, w, map[string]interface{}{"ok": true, "channel": channel})
+	default:
+		channel := call.values.Get("channel")
+		writeJSONResponse(f.t, w, map[string]interface{}{"ok": true, "channel": channel, "ts": "1710417600.000100"})
+	}
+}
+
+// received returns the calls to the given methods, all the calls without methods
+func (f *fakeSlack) received(methods ...string) []slackCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []slackCall
+	for _, call := range f.calls {
+		if len(methods) == 0 || contains(methods, call.method) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// contains tells whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// text gathers the strings of a call, e.g. the texts, URLs and types of its blocks and
+// attachments, one per line, to look for a story in it
+func (c slackCall) text() string {
+	var parts []string
+	if c.message != nil {
+		parts = stringLeaves(c.message, parts)
+	}
+	for _, field := range []string{"text", "blocks", "attachments"} {
+		value := c.values.Get(field)
+		var decoded interface{}
+		if field == "text" || json.Unmarshal([]byte(value), &decoded) != nil {
+			parts = append(parts, value)
+			continue
+		}
+		parts = stringLeaves(decoded, parts)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// stringLeaves appends the strings of a decoded JSON document to parts
+func stringLeaves(v interface{}, parts []string) []string {
+	switch v := v.(type) {
+	case string:
+		parts = append(parts, v)
+	case []interface{}:
+		for _, item := range v {
+			parts = stringLeaves(item, parts)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts = stringLeaves(v[key], parts)
+		}
+	}
+	return parts
+}
+
+// newTestBot returns a bot serving the stories of source and posting to a fake slack. The config
+// trusts the verification token 'verification-token' and the response URL of the fake slack, and
+// configure may change it before the bot is built. The clock of the bot is testNow.
+func newTestBot(t *testing.T, source NewsSource, configure func(cfg *Config)) (*Bot, *fakeSlack) {
+	t.Helper()
+	fake := newFakeSlack(t)
+	cfg := Config{
+		slackTokens:            staticTokenStore{token: "xoxb-test"},
+		slackVerificationToken: "verification-token",
+		slackResponseURLHosts:  []string{"127.0.0.1"},
+		maxConcurrentPosts:     4,
+		commandTimeout:         5 * time.Second,
+		quotaLocation:          time.UTC,
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	b := NewBot(source, cfg, discardLogger(), newPromMetrics())
+	b.slackClients.options = fake.options()
+	b.slackClients.httpClient = fake.server.Client()
+	b.now = func() time.Time { return testNow }
+	// wait for the tasks of the bot before the fake slack is closed
+	t.Cleanup(func() {
+		waitTasks(t, b)
+		b.Close()
+	})
+	return b, fake
+}
+
+// discardLogger is a logger dropping every record, for the tests not checking the logs
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// waitTasks waits for the commands handled in the background by the bot
+func waitTasks(t *testing.T, b *Bot) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("waiting for the tasks of the bot: %v", err)
+	}
+}
+
+// searchResponse is a NYT article search response with the given docs, each a map of the JSON
+// fields of a doc
+func searchResponse(docs ...map[string]interface{}) map[string]interface{} {
+	if docs == nil {
+		docs = []map[string]interface{}{}
+	}
+	return map[string]interface{}{"status": "OK", "response": map[string]interface{}{"docs": docs}}
+}
+
+// nytDoc is the JSON of a NYT article search doc with a headline and a link
+func nytDoc(headline string) map[string]interface{} {
+	return map[string]interface{}{
+		"headline": map[string]string{"main": headline},
+		"abstract": "The abstract of " + headline,
+		"web_url":  "https://www.nytimes.com/2024/03/14/" + url.PathEscape(headline) + ".html",
+		"pub_date": "2024-03-14T08:00:00+0000",
+	}
+}
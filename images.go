@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// imageValidator checks image URLs are reachable before we render them, since slack rejects the
+// whole message when one of its images can't be downloaded. Results are cached for a short while.
+type imageValidator struct {
+	client *http.Client
+	cache  *ttlCache[string, bool]
+}
+
+func newImageValidator(timeout time.Duration, ttl time.Duration) *imageValidator {
+	return &imageValidator{
+		client: &http.Client{Timeout: timeout},
+		cache:  newTTLCache[string, bool](ttl),
+	}
+}
+
+// valid reports whether the image URL answers a HEAD request successfully with an image
+func (v *imageValidator) valid(ctx context.Context, imageURL string) bool {
+	valid, _ := v.cache.GetOrCompute(ctx, imageURL, func() (bool, error) {
+		return v.check(ctx, imageURL), nil
+	})
+	return valid
+}
+
+func (v *imageValidator) check(ctx context.Context, imageURL string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, imageURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "image/")
+}
+
+// dropInvalidImages returns a copy of the articles without the images that failed validation
+func (v *imageValidator) dropInvalidImages(ctx context.Context, articles []Article) []Article {
+	result := make([]Article, len(articles))
+	copy(result, articles)
+
+	var wg sync.WaitGroup
+	for i := range result {
+		if result[i].ImageURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(a *Article) {
+			defer wg.Done()
+			if !v.valid(ctx, a.ImageURL) {
+				a.ImageURL = ""
+			}
+		}(&result[i])
+	}
+	wg.Wait()
+	return result
+}
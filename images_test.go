@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newImageServer serves an image at /ok.jpg, a page at /page and nothing else, counting the requests
+func newImageServer(t *testing.T) (*httptest.Server, func(path string) int) {
+	t.Helper()
+	var mu sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		if r.Method != http.MethodHead {
+			t.Errorf("got a %s request, want HEAD", r.Method)
+		}
+		switch r.URL.Path {
+		case "/ok.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[path]
+	}
+}
+
+func TestImageValidator(t *testing.T) {
+	server, hits := newImageServer(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	v := newImageValidator(time.Second, time.Minute)
+	tests := []struct {
+		imageURL string
+		valid    bool
+	}{
+		{server.URL + "/ok.jpg", true},
+		{server.URL + "/missing.jpg", false},
+		{server.URL + "/page", false},
+		{closed.URL + "/ok.jpg", false},
+		{"://invalid", false},
+	}
+	for _, tt := range tests {
+		if got := v.valid(context.Background(), tt.imageURL); got != tt.valid {
+			t.Errorf("valid(%q) = %v, want %v", tt.imageURL, got, tt.valid)
+		}
+	}
+
+	// the results are cached, valid or not
+	v.valid(context.Background(), server.URL+"/ok.jpg")
+	v.valid(context.Background(), server.URL+"/missing.jpg")
+	if hits("/ok.jpg") != 1 || hits("/missing.jpg") != 1 {
+		t.Errorf("got %d and %d requests, want the results cached", hits("/ok.jpg"), hits("/missing.jpg"))
+	}
+}
+
+func TestImageValidatorTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	v := newImageValidator(50*time.Millisecond, time.Minute)
+	start := time.Now()
+	if v.valid(context.Background(), slow.URL+"/slow.jpg") {
+		t.Error("got a valid image from a server that doesn't answer")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the validation took %s, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestDropInvalidImages(t *testing.T) {
+	server, _ := newImageServer(t)
+	reachable, unreachable, none := testArticle("Reachable"), testArticle("Unreachable"), testArticle("No image")
+	reachable.ImageURL = server.URL + "/ok.jpg"
+	unreachable.ImageURL = server.URL + "/missing.jpg"
+	none.ImageURL = ""
+	articles := []Article{reachable, unreachable, none}
+
+	v := newImageValidator(time.Second, time.Minute)
+	result := v.dropInvalidImages(context.Background(), articles)
+	if result[0].ImageURL != reachable.ImageURL || result[1].ImageURL != "" || result[2].ImageURL != "" {
+		t.Errorf("got images %q, %q and %q, want only the reachable one", result[0].ImageURL, result[1].ImageURL, result[2].ImageURL)
+	}
+	if articles[1].ImageURL != unreachable.ImageURL {
+		t.Error("the images were dropped from the original articles")
+	}
+}
+
+func TestRenderDropsUnreachableImages(t *testing.T) {
+	server, hits := newImageServer(t)
+	reachable, unreachable := testArticle("Reachable"), testArticle("Unreachable")
+	reachable.ImageURL = server.URL + "/ok.jpg"
+	unreachable.ImageURL = server.URL + "/missing.jpg"
+	articles := []Article{reachable, unreachable}
+
+	for _, validate := range []bool{true, false} {
+		b, _ := newTestBot(t, &fakeNews{}, func(cfg *Config) { cfg.validateImages = validate })
+		text := jsonString(t, messageBlocks(t, b.render(context.Background(), articles, RenderOptions{Images: true})))
+		if !strings.Contains(text, reachable.ImageURL) {
+			t.Errorf("validate=%v: the reachable image is missing", validate)
+		}
+		if got := strings.Contains(text, unreachable.ImageURL); got == validate {
+			t.Errorf("validate=%v: got the unreachable image shown %v", validate, got)
+		}
+	}
+	if hits("/ok.jpg") != 1 {
+		t.Errorf("got %d requests for the image, want it checked once with validation only", hits("/ok.jpg"))
+	}
+}
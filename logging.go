@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// logLevels are the supported values of LOG_LEVEL
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// newLogger returns the logger of the bot writing to w, logging JSON in production and readable
+// text locally
+func newLogger(cfg Config, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.logLevel}
+	if cfg.logJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// newCorrelationID returns a random ID tying together the logs of a single request
+func newCorrelationID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// logOutgoingMessage logs the blocks and attachments of a message as JSON before it is posted,
+// when debug logging is enabled. The message options are encoded by slack-go itself, so the
+// logged payload is exactly the one sent to slack.
+func (b *Bot) logOutgoingMessage(correlationID string, channelID string, options ...slack.MsgOption) {
+	if !b.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	_, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	if err != nil {
+		b.logger.Debug("error encoding message", "correlation_id", correlationID, "channel_id", channelID, "error", err)
+		return
+	}
+	var payload []string
+	for _, field := range []string{"blocks", "attachments", "text"} {
+		if value := values.Get(field); value != "" {
+			payload = append(payload, field+"="+value)
+		}
+	}
+	b.logger.Debug("posting message", "correlation_id", correlationID, "channel_id", channelID, "payload", strings.Join(payload, " "))
+}
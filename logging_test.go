@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestNewLogger(t *testing.T) {
+	for _, logJSON := range []bool{false, true} {
+		t.Run(fmt.Sprintf("json=%t", logJSON), func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := newLogger(Config{logLevel: logLevels["warn"], logJSON: logJSON}, &logs)
+			logger.Info("dropped below the level")
+			logger.Warn("section hidden", "section", "world")
+
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("got logs %q, want the warning only", logs.String())
+			}
+			var record map[string]interface{}
+			err := json.Unmarshal([]byte(lines[0]), &record)
+			if logJSON != (err == nil) {
+				t.Fatalf("got log %q, want JSON %t", lines[0], logJSON)
+			}
+			if logJSON && (record["level"] != "WARN" || record["msg"] != "section hidden" || record["section"] != "world") {
+				t.Errorf("got log %v", record)
+			}
+			if !logJSON && !strings.Contains(lines[0], `level=WARN msg="section hidden" section=world`) {
+				t.Errorf("got log %q", lines[0])
+			}
+		})
+	}
+}
+
+func TestLogOutgoingMessage(t *testing.T) {
+	blocks := slack.MsgOptionBlocks(slack.NewSectionBlock(&slack.TextBlockObject{Type: "mrkdwn", Text: "*<https://nyti.ms/story|Story>*"}, nil, nil))
+	tests := []struct {
+		level  slog.Level
+		logged bool
+	}{
+		{slog.LevelDebug, true},
+		{slog.LevelInfo, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			var logs bytes.Buffer
+			b := &Bot{logger: slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: tt.level}))}
+			b.logOutgoingMessage("test-request", "C0TESTCHANNEL", blocks)
+
+			if !tt.logged {
+				if logs.Len() > 0 {
+					t.Errorf("got logs %q, want none", logs.String())
+				}
+				return
+			}
+			var record map[string]interface{}
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("decoding the log %q: %v", logs.String(), err)
+			}
+			if record["msg"] != "posting message" || record["level"] != "DEBUG" || record["correlation_id"] != "test-request" || record["channel_id"] != "C0TESTCHANNEL" {
+				t.Errorf("got log %v", record)
+			}
+			payload, _ := record["payload"].(string)
+			if !strings.HasPrefix(payload, "blocks=") {
+				t.Fatalf("got payload %q, want the blocks", payload)
+			}
+			var logged []map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(payload, "blocks=")), &logged); err != nil {
+				t.Fatalf("the logged blocks aren't JSON: %v", err)
+			}
+			if len(logged) != 1 || logged[0]["type"] != "section" {
+				t.Errorf("got blocks %v, want the section", logged)
+			}
+		})
+	}
+}
@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -16,26 +22,85 @@ import (
 func main() {
 	cfg := initConfig()
 
-	nytClient := NewNYTimes(cfg.nytAPIKey)
+	// the standard logger goes through the same handler, so every log line shares the format
+	logger := newLogger(cfg, os.Stderr)
+	slog.SetDefault(logger)
 
-	bot := NewBot(nytClient, cfg.slackBotToken, cfg.slackVerificationToken)
+	prom := newPromMetrics()
+	source, err := newNewsSource(cfg.newsProvider, cfg, prom.observeSourceRequest, logger)
+	if err != nil {
+		fatal("error configuring the news provider", "provider", cfg.newsProvider, "error", err)
+	}
+	if err := validateBriefingGroups(cfg.briefingGroups, source); err != nil {
+		fatal("invalid BRIEFING_GROUPS", "error", err)
+	}
+	if err := validateSectionRotation(cfg.sectionRotation, source); err != nil {
+		fatal("invalid ROTATION_SECTIONS", "error", err)
+	}
+	if err := validateRelatedSections(cfg.relatedSections, source); err != nil {
+		fatal("invalid RELATED_SECTIONS", "error", err)
+	}
+	if err := validateWarmSections(cfg.cacheWarmSections, source); err != nil {
+		fatal("invalid CACHE_WARM_SECTIONS", "error", err)
+	}
+
+	// every command hits the provider otherwise, which is usually rate limited and slow
+	newsSource := source
+	if cfg.topStoriesCacheTTL > 0 || cfg.searchCacheTTL > 0 {
+		newsSource = NewCachedNewsSource(source, cfg.topStoriesCacheTTL, cfg.searchCacheTTL)
+	}
+
+	bot := NewBot(newsSource, cfg, logger, prom)
+
+	// background jobs share a context that is cancelled on shutdown
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobs := newScheduler()
+
+	snapshotter := newMetricsSnapshotter(bot.metrics, cfg.metricsSnapshotPath, logger)
+	jobs.every(jobsCtx, cfg.metricsSnapshotInterval, func(ctx context.Context) {
+		if err := snapshotter.write(); err != nil {
+			logger.Error("error writing metrics snapshot", "error", err)
+		}
+	})
+	jobs.every(jobsCtx, cfg.digestInterval, bot.postDigests)
+	if cfg.topicHeadlines {
+		jobs.every(jobsCtx, cfg.topicInterval, bot.updateTopicHeadlines)
+	}
+	// warm the hot sections right away, then keep them fresh
+	if warmer := newCacheWarmer(newsSource, cfg.cacheWarmSections, cfg.cacheWarmInterval, logger); warmer != nil {
+		jobs.now(jobsCtx, warmer.warm)
+		jobs.every(jobsCtx, cfg.cacheWarmInterval, warmer.warm)
+	}
+	if bot.rotationPoster != nil {
+		// check often enough to post within a few minutes of the rotation hour
+		jobs.every(jobsCtx, 5*time.Minute, bot.postSectionOfTheDay)
+	}
 
 	r := http.NewServeMux()
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "Hello world!")
 	})
-	r.HandleFunc("/receive", bot.HandleSlashCommand)
-	r.HandleFunc("/receive/help", bot.HandleHelpInteraction)
+	r.HandleFunc("/healthz", handleHealthz)
+	r.Handle("/metrics", prom.Handler())
+	// readiness checks the provider itself, a cached response would hide an outage
+	r.Handle("/readyz", newReadiness(source, logger))
+	// slack only POSTs, health checkers and browsers may GET the slack routes
+	r.Handle("/receive", onlyMethod(http.MethodPost, bot.HandleSlashCommand))
+	r.Handle("/receive/help", onlyMethod(http.MethodPost, bot.HandleHelpInteraction))
+	r.Handle("/events", onlyMethod(http.MethodPost, bot.HandleEvent))
+	r.HandleFunc("/api/stories", bot.HandleStoriesAPI)
+	if cfg.adminAPIToken != "" {
+		r.Handle("/admin/", newAdminAPI(cfg, newsSource, bot.subscriptions, logger).Handler())
+	}
 
-	serverAddress := fmt.Sprintf("0.0.0.0:%s", "80")
-	server := &http.Server{Addr: serverAddress, Handler: r}
+	server := newServer(cfg, r)
 
-	fmt.Printf("Serving at http://%s/", serverAddress)
+	logger.Info("serving the bot", "url", fmt.Sprintf("http://%s/", server.Addr))
 
 	// start service in a go routine to support graceful shutdown
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("error shutting down bot service")
+			fatal("error serving the bot", "error", err)
 		}
 	}()
 
@@ -44,34 +109,488 @@ func main() {
 	// kill (no param) default send syscall.SIGTERM
 	// kill -2 is syscall.SIGINT
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// kill -HUP reloads the maintenance mode settings
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := bot.maintenance.reload(dotenvPath()); err != nil {
+				logger.Error("error reloading maintenance settings", "error", err)
+				continue
+			}
+			_, enabled := bot.maintenance.active()
+			logger.Info("reloaded maintenance settings", "maintenance", enabled)
+		}
+	}()
+
 	sig := <-quit
-	fmt.Printf("caught signal %s, shutting down...", sig)
+	logger.Info("caught signal, shutting down", "signal", sig.String())
+	// a second signal means the operator doesn't want to wait for the drain
+	go exitOnSignal(quit, logger, os.Exit)
 
 	// The context is used to inform the server it has X seconds to finish the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
-		fmt.Println("error shutting down server cleanly", err)
+		logger.Error("error shutting down server cleanly", "error", err)
 	} else {
-		fmt.Println("gracefully shut down bot service")
+		logger.Info("gracefully shut down bot service")
+	}
+	// the commands are handled in the background, finish them within the same deadline
+	if err := bot.Wait(ctx); err != nil {
+		logger.Error("error waiting for pending commands", "error", err)
 	}
+
+	stopJobs()
+	jobs.wait()
+	if err := snapshotter.write(); err != nil {
+		logger.Error("error writing final metrics snapshot", "error", err)
+	}
+	bot.Close()
+	if closer, ok := newsSource.(cacheCloser); ok {
+		closer.Close()
+	}
+}
+
+// fatal logs an error and exits. The config is read before the logger is configured from it, so
+// its errors go through slog's default logger until main replaces it.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// exitOnSignal exits with a failure status as soon as a signal is received on quit
+func exitOnSignal(quit <-chan os.Signal, logger *slog.Logger, exit func(code int)) {
+	sig := <-quit
+	logger.Warn("caught signal again, exiting now", "signal", sig.String())
+	exit(1)
+}
+
+// newServer builds the HTTP server. The timeouts bound the connections, so slow clients can't
+// hold them open indefinitely.
+func newServer(cfg Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         fmt.Sprintf("0.0.0.0:%d", cfg.port),
+		Handler:      handler,
+		ReadTimeout:  cfg.httpReadTimeout,
+		WriteTimeout: cfg.httpWriteTimeout,
+		IdleTimeout:  cfg.httpIdleTimeout,
+	}
+}
+
+// onlyMethod rejects the requests with any other method than the given one with a 405
+func onlyMethod(method string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	})
 }
 
 // ----//----
 
 type Config struct {
+	port             int
+	httpReadTimeout  time.Duration
+	httpWriteTimeout time.Duration
+	httpIdleTimeout  time.Duration
+
+	newsProvider           string
 	nytAPIKey              string
+	guardianAPIKey         string
+	nytSectionOverrides    map[string]string
+	nytPreferFullURLs      bool
+	nytTimeout             time.Duration
+	nytAttempts            int
+	nytRetryDelay          time.Duration
+	nytMinAttemptTimeout   time.Duration
+	topStoriesCacheTTL     time.Duration
+	searchCacheTTL         time.Duration
+	cacheWarmSections      []string
+	cacheWarmInterval      time.Duration
 	slackBotToken          string
+	slackTokens            TokenStore
 	slackVerificationToken string
+	slackSigningSecret     string
+	slackResponseURLHosts  []string
+	maxConcurrentPosts     int
+
+	metricsSnapshotPath     string
+	metricsSnapshotInterval time.Duration
+
+	headlinesOnly       bool
+	highlightLead       bool
+	renderAttachments   bool
+	attachmentColor     string
+	renderImages        bool
+	imageStyle          string
+	validateImages      bool
+	abstractPlaceholder string
+	badges              map[string]string
+	articleTemplate     *template.Template
+	freshness           FreshnessThresholds
+	briefingSections    []string
+	briefingGroups      []briefingGroup
+	relatedSections     map[string][]string
+	breakingSections    []string
+	helpTips            []string
+
+	subscriptions        []subscription
+	digestInterval       time.Duration
+	pinDigests           bool
+	compactDigestHeaders bool
+	deduplicateDigests   bool
+	topicHeadlines       bool
+	topicInterval        time.Duration
+
+	sectionRotation  *sectionRotation
+	rotationChannels []string
+	rotationHour     int
+
+	commandCooldown time.Duration
+	commandTimeout  time.Duration
+	dailyQuota      int
+	quotaLocation   *time.Location
+	logLevel        slog.Level
+	logJSON         bool
+
+	maintenance        bool
+	maintenanceMessage string
+
+	auditChannel string
+	auditLog     bool
+
+	adminAPIToken string
+
+	// apiMaxStories caps the number of stories of a request to the stories API
+	apiMaxStories int
+	adminUserIDs  []string
+	features      featureFlags
+}
+
+// configProfile holds the defaults that depend on the environment the bot runs in
+type configProfile struct {
+	nytTimeout  time.Duration
+	nytAttempts int
+	logJSON     bool
+}
+
+// profiles are the config profiles. Local development should fail fast, while production
+// should be patient with NYT.
+var profiles = map[string]configProfile{
+	"local":      {nytTimeout: 3 * time.Second, nytAttempts: 1},
+	"production": {nytTimeout: 8 * time.Second, nytAttempts: 3, logJSON: true},
+}
+
+// loadProfile returns the profile of the environment, with its NYT settings overridden by the
+// NYT_TIMEOUT_SECONDS and NYT_ATTEMPTS env vars
+func loadProfile(env string) configProfile {
+	profile := profiles["production"]
+	if env == "taina-local" {
+		profile = profiles["local"]
+	}
+	profile.nytTimeout = time.Duration(getEnvInt("NYT_TIMEOUT_SECONDS", int(profile.nytTimeout/time.Second))) * time.Second
+	profile.nytAttempts = getEnvInt("NYT_ATTEMPTS", profile.nytAttempts)
+	return profile
+}
+
+// parseFlags parses the command line flags. The -port flag overrides PORT, which must be a number
+// between 1 and 65535, and the -provider flag overrides NEWS_PROVIDER.
+func parseFlags(args []string) (port int, newsProvider string, err error) {
+	portValue := os.Getenv("PORT")
+	if portValue == "" {
+		portValue = "8080"
+	}
+	newsProvider = os.Getenv("NEWS_PROVIDER")
+	if newsProvider == "" {
+		newsProvider = "nyt"
+	}
+	flags := flag.NewFlagSet("taina-backend", flag.ContinueOnError)
+	flags.StringVar(&portValue, "port", portValue, "port the server listens on")
+	flags.StringVar(&newsProvider, "provider", newsProvider, "news provider of the stories")
+	if err := flags.Parse(args); err != nil {
+		return 0, "", err
+	}
+	port, err = strconv.Atoi(portValue)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, "", fmt.Errorf("invalid port %q, expected a number between 1 and 65535", portValue)
+	}
+	return port, newsProvider, nil
 }
 
 func initConfig() Config {
-	if os.Getenv("ENV") == "taina-local" {
-		godotenv.Load()
+	env := os.Getenv("ENV")
+	if env == "taina-local" {
+		loadDotenv()
+	}
+	profile := loadProfile(env)
+
+	portNumber, newsProvider, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fatal("invalid command line", "error", err)
+	}
+
+	subscriptions, err := parseSubscriptions(getEnvList("SUBSCRIPTIONS", nil))
+	if err != nil {
+		fatal("invalid SUBSCRIPTIONS", "error", err)
+	}
+
+	var nytSectionOverrides map[string]string
+	if overrides := os.Getenv("NYT_SECTION_OVERRIDES"); overrides != "" {
+		if err := json.Unmarshal([]byte(overrides), &nytSectionOverrides); err != nil {
+			fatal("invalid NYT_SECTION_OVERRIDES, expected a JSON object mapping sections to NYT keys", "error", err)
+		}
 	}
+
+	sectionRotation, err := parseSectionRotation(getEnvList("ROTATION_SECTIONS", nil), os.Getenv("ROTATION_DEFAULT_SECTION"))
+	if err != nil {
+		fatal("invalid ROTATION_SECTIONS or ROTATION_DEFAULT_SECTION", "error", err)
+	}
+
+	badges, err := parseBadges(getEnvList("BADGES", nil))
+	if err != nil {
+		fatal("invalid BADGES", "error", err)
+	}
+
+	imageStyle := strings.ToLower(os.Getenv("IMAGE_STYLE"))
+	if imageStyle == "" {
+		imageStyle = imageStyleAccessory
+	}
+	if !imageStyles[imageStyle] {
+		fatal("invalid IMAGE_STYLE, expected accessory, block or none", "image_style", imageStyle)
+	}
+
+	// a zero threshold disables its freshness badge
+	freshness := FreshnessThresholds{
+		New:    time.Duration(getEnvInt("FRESHNESS_NEW_MINUTES", int(defaultFreshness.New/time.Minute))) * time.Minute,
+		Recent: time.Duration(getEnvInt("FRESHNESS_RECENT_MINUTES", int(defaultFreshness.Recent/time.Minute))) * time.Minute,
+	}
+	if freshness.New < 0 || freshness.Recent < 0 {
+		fatal("invalid FRESHNESS_NEW_MINUTES or FRESHNESS_RECENT_MINUTES, the thresholds can't be negative")
+	}
+
+	articleTemplate, err := parseArticleTemplate(os.Getenv("ARTICLE_TEMPLATE"))
+	if err != nil {
+		fatal("invalid ARTICLE_TEMPLATE", "error", err)
+	}
+
+	briefingGroups, err := parseBriefingGroups(os.Getenv("BRIEFING_GROUPS"))
+	if err != nil {
+		fatal("invalid BRIEFING_GROUPS", "error", err)
+	}
+
+	relatedSections, err := parseRelatedSections(os.Getenv("RELATED_SECTIONS"))
+	if err != nil {
+		fatal("invalid RELATED_SECTIONS", "error", err)
+	}
+
+	// the daily quota resets at midnight in QUOTA_TIMEZONE, UTC by default
+	quotaLocation, err := time.LoadLocation(os.Getenv("QUOTA_TIMEZONE"))
+	if err != nil {
+		fatal("invalid QUOTA_TIMEZONE", "error", err)
+	}
+
+	features, err := parseFeatureFlags(getEnvList("FEATURE_FLAGS", nil))
+	if err != nil {
+		fatal("invalid FEATURE_FLAGS", "error", err)
+	}
+
+	logLevelName := strings.ToLower(os.Getenv("LOG_LEVEL"))
+	if logLevelName == "" {
+		logLevelName = "info"
+	}
+	logLevel, ok := logLevels[logLevelName]
+	if !ok {
+		fatal("invalid LOG_LEVEL, expected debug, info, warn or error", "log_level", logLevelName)
+	}
+
+	maxConcurrentPosts := getEnvInt("MAX_CONCURRENT_POSTS", 3)
+	if maxConcurrentPosts < 1 {
+		fatal("invalid MAX_CONCURRENT_POSTS, at least one post must be allowed", "max_concurrent_posts", maxConcurrentPosts)
+	}
+
+	apiMaxStories := getEnvInt("API_MAX_STORIES", 10)
+	if apiMaxStories < 1 {
+		fatal("invalid API_MAX_STORIES, the API must return at least a story", "api_max_stories", apiMaxStories)
+	}
+
+	commandTimeout := getEnvInt("COMMAND_TIMEOUT_SECONDS", 8)
+	if commandTimeout < 1 {
+		fatal("invalid COMMAND_TIMEOUT_SECONDS, commands need at least a second", "command_timeout_seconds", commandTimeout)
+	}
+
+	var slackTokens TokenStore = staticTokenStore{token: os.Getenv("SLACK_BOT_TOKEN")}
+	if path := os.Getenv("SLACK_BOT_TOKEN_FILE"); path != "" {
+		slackTokens = fileTokenStore{path: path}
+	}
+
 	return Config{
+		port:             portNumber,
+		httpReadTimeout:  time.Duration(getEnvInt("HTTP_READ_TIMEOUT_SECONDS", 10)) * time.Second,
+		httpWriteTimeout: time.Duration(getEnvInt("HTTP_WRITE_TIMEOUT_SECONDS", 15)) * time.Second,
+		httpIdleTimeout:  time.Duration(getEnvInt("HTTP_IDLE_TIMEOUT_SECONDS", 60)) * time.Second,
+
+		newsProvider:           newsProvider,
 		nytAPIKey:              os.Getenv("NYT_API_KEY"),
+		guardianAPIKey:         os.Getenv("GUARDIAN_API_KEY"),
+		nytSectionOverrides:    nytSectionOverrides,
+		nytPreferFullURLs:      getEnvBool("NYT_PREFER_FULL_URLS", false),
+		nytTimeout:             profile.nytTimeout,
+		nytAttempts:            profile.nytAttempts,
+		nytRetryDelay:          time.Duration(getEnvInt("NYT_RETRY_DELAY_MS", 500)) * time.Millisecond,
+		nytMinAttemptTimeout:   time.Duration(getEnvInt("NYT_MIN_ATTEMPT_TIMEOUT_MS", 1000)) * time.Millisecond,
+		topStoriesCacheTTL:     time.Duration(getEnvInt("TOP_STORIES_CACHE_TTL_SECONDS", 300)) * time.Second,
+		searchCacheTTL:         time.Duration(getEnvInt("SEARCH_CACHE_TTL_SECONDS", 900)) * time.Second,
+		cacheWarmSections:      getEnvList("CACHE_WARM_SECTIONS", nil),
+		cacheWarmInterval:      time.Duration(getEnvInt("CACHE_WARM_INTERVAL_SECONDS", 180)) * time.Second,
 		slackBotToken:          os.Getenv("SLACK_BOT_TOKEN"),
+		slackTokens:            slackTokens,
 		slackVerificationToken: os.Getenv("SLACK_VERIFICATION_TOKEN"),
+		slackSigningSecret:     os.Getenv("SLACK_SIGNING_SECRET"),
+		slackResponseURLHosts:  getEnvList("SLACK_RESPONSE_URL_HOSTS", []string{"hooks.slack.com"}),
+		maxConcurrentPosts:     maxConcurrentPosts,
+
+		metricsSnapshotPath:     os.Getenv("METRICS_SNAPSHOT_PATH"),
+		metricsSnapshotInterval: time.Duration(getEnvInt("METRICS_SNAPSHOT_INTERVAL_MINUTES", 5)) * time.Minute,
+
+		headlinesOnly:       getEnvBool("HEADLINES_ONLY", false),
+		highlightLead:       getEnvBool("HIGHLIGHT_LEAD", false),
+		renderAttachments:   getEnvBool("RENDER_ATTACHMENTS", false),
+		attachmentColor:     os.Getenv("ATTACHMENT_COLOR"),
+		renderImages:        getEnvBool("RENDER_IMAGES", false),
+		imageStyle:          imageStyle,
+		validateImages:      getEnvBool("VALIDATE_IMAGES", false),
+		abstractPlaceholder: os.Getenv("ABSTRACT_PLACEHOLDER"),
+		badges:              badges,
+		articleTemplate:     articleTemplate,
+		freshness:           freshness,
+		briefingSections:    getEnvList("BRIEFING_SECTIONS", []string{"home", "world", "us", "business", "technology"}),
+		briefingGroups:      briefingGroups,
+		relatedSections:     relatedSections,
+		breakingSections:    getEnvList("BREAKING_SECTIONS", []string{"home", "world", "us", "politics", "business"}),
+		helpTips:            getHelpTips(),
+
+		subscriptions:        subscriptions,
+		digestInterval:       time.Duration(getEnvInt("DIGEST_INTERVAL_MINUTES", 60)) * time.Minute,
+		pinDigests:           getEnvBool("PIN_DIGESTS", false),
+		compactDigestHeaders: getEnvBool("COMPACT_DIGEST_HEADERS", false),
+		deduplicateDigests:   getEnvBool("DEDUPLICATE_DIGESTS", false),
+		topicHeadlines:       getEnvBool("TOPIC_HEADLINES", false),
+		topicInterval:        time.Duration(getEnvInt("TOPIC_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		sectionRotation:  sectionRotation,
+		rotationChannels: getEnvList("ROTATION_CHANNELS", nil),
+		rotationHour:     getEnvInt("ROTATION_HOUR", 9),
+
+		commandCooldown: time.Duration(getEnvInt("COMMAND_COOLDOWN_SECONDS", 0)) * time.Second,
+		commandTimeout:  time.Duration(commandTimeout) * time.Second,
+		dailyQuota:      getEnvInt("DAILY_QUOTA", 0),
+		quotaLocation:   quotaLocation,
+		logLevel:        logLevel,
+		logJSON:         profile.logJSON,
+
+		maintenance:        getEnvBool("MAINTENANCE", false),
+		maintenanceMessage: os.Getenv("MAINTENANCE_MESSAGE"),
+
+		auditChannel: os.Getenv("AUDIT_CHANNEL"),
+		auditLog:     getEnvBool("AUDIT_LOG", false),
+
+		adminAPIToken: os.Getenv("ADMIN_API_TOKEN"),
+		apiMaxStories: apiMaxStories,
+		adminUserIDs:  getEnvList("ADMIN_USER_IDS", nil),
+		features:      features,
+	}
+}
+
+// loadDotenv loads the local environment from the file at DOTENV_PATH, or from .env in the working
+// directory. Only a missing DOTENV_PATH file is warned about, the default .env is optional.
+func loadDotenv() {
+	path := dotenvPath()
+	if os.Getenv("DOTENV_PATH") == "" {
+		if err := godotenv.Load(path); err == nil {
+			slog.Info("loaded environment", "path", path)
+		}
+		return
+	}
+	if err := godotenv.Load(path); err != nil {
+		slog.Warn("can't load the environment from DOTENV_PATH", "path", path, "error", err)
+		return
+	}
+	slog.Info("loaded environment", "path", path)
+}
+
+// dotenvPath returns the path of the local environment file, DOTENV_PATH or else .env
+func dotenvPath() string {
+	if path := os.Getenv("DOTENV_PATH"); path != "" {
+		return path
+	}
+	return ".env"
+}
+
+// getEnvInt reads an integer environment variable, returning def when it is not set
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		fatal("invalid value for "+key, "value", value, "error", err)
+	}
+	return n
+}
+
+// getEnvBool reads a boolean environment variable, returning def when it is not set
+func getEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		fatal("invalid value for "+key, "value", value, "error", err)
+	}
+	return b
+}
+
+// getEnvList reads a comma separated environment variable, returning def when it is not set
+func getEnvList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getHelpTips reads the help tips from HELP_TIPS, separated by '|' since tips may contain commas
+func getHelpTips() []string {
+	value := os.Getenv("HELP_TIPS")
+	if value == "" {
+		return []string{
+			"try `/news stories world` to see what's happening around the globe",
+			"try `/news stories technology --headlines` for a quick look at the headlines",
+			"try `/news briefing` to get a threaded briefing of several sections",
+			"try `/news author \"paul krugman\"` to read the latest stories of an author",
+		}
+	}
+	var tips []string
+	for _, tip := range strings.Split(value, "|") {
+		if tip = strings.TrimSpace(tip); tip != "" {
+			tips = append(tips, tip)
+		}
 	}
+	return tips
 }
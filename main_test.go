@@ -0,0 +1,218 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		name              string
+		env               string
+		timeout, attempts string
+		wantTimeout       time.Duration
+		wantAttempts      int
+		wantLogJSON       bool
+	}{
+		{"local", "taina-local", "", "", 3 * time.Second, 1, false},
+		{"production", "", "", "", 8 * time.Second, 3, true},
+		{"unknown environment", "staging", "", "", 8 * time.Second, 3, true},
+		{"local overridden", "taina-local", "10", "2", 10 * time.Second, 2, false},
+		{"production overridden", "", "1", "1", time.Second, 1, true},
+		{"timeout overridden alone", "", "20", "", 20 * time.Second, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NYT_TIMEOUT_SECONDS", tt.timeout)
+			t.Setenv("NYT_ATTEMPTS", tt.attempts)
+			profile := loadProfile(tt.env)
+			if profile.nytTimeout != tt.wantTimeout || profile.nytAttempts != tt.wantAttempts {
+				t.Errorf("got timeout %s and %d attempts, want %s and %d",
+					profile.nytTimeout, profile.nytAttempts, tt.wantTimeout, tt.wantAttempts)
+			}
+			if profile.logJSON != tt.wantLogJSON {
+				t.Errorf("got JSON logs %t, want %t", profile.logJSON, tt.wantLogJSON)
+			}
+		})
+	}
+}
+
+func TestNYTProviderProfileSettings(t *testing.T) {
+	for _, env := range []string{"taina-local", ""} {
+		profile := loadProfile(env)
+		cfg := Config{nytAPIKey: "test-key", nytTimeout: profile.nytTimeout, nytAttempts: profile.nytAttempts}
+		source, err := newNYTProvider(cfg, nil, discardLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		nyt := source.(*NYTimes)
+		if nyt.httpClient.Timeout != profile.nytTimeout || nyt.attempts != profile.nytAttempts {
+			t.Errorf("%q: got timeout %s and %d attempts, want %s and %d",
+				env, nyt.httpClient.Timeout, nyt.attempts, profile.nytTimeout, profile.nytAttempts)
+		}
+	}
+}
+
+func TestNewServerTimeouts(t *testing.T) {
+	cfg := Config{port: 8080, httpReadTimeout: 10 * time.Second, httpWriteTimeout: 15 * time.Second, httpIdleTimeout: time.Minute}
+	server := newServer(cfg, http.NotFoundHandler())
+	if server.Addr != "0.0.0.0:8080" {
+		t.Errorf("got address %q", server.Addr)
+	}
+	if server.ReadTimeout != 10*time.Second || server.WriteTimeout != 15*time.Second || server.IdleTimeout != time.Minute {
+		t.Errorf("got read, write and idle timeouts %s, %s and %s, want 10s, 15s and 1m",
+			server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+}
+
+func TestNewServerDropsSlowClients(t *testing.T) {
+	server := newServer(Config{httpReadTimeout: 100 * time.Millisecond}, http.NotFoundHandler())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// send the start of a request and never finish it
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("the connection of the slow client wasn't closed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the slow client was dropped after %s, want about the read timeout", elapsed)
+	}
+}
+
+func TestOnlyMethod(t *testing.T) {
+	b, fake := newTestBot(t, &fakeNews{stories: map[string][]Article{"world": nil}}, nil)
+	handler := onlyMethod(http.MethodPost, b.HandleSlashCommand)
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/receive", nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: got status %d, want %d", method, w.Code, http.StatusMethodNotAllowed)
+		}
+		if got := w.Header().Get("Allow"); got != http.MethodPost {
+			t.Errorf("%s: got Allow %q, want %q", method, got, http.MethodPost)
+		}
+	}
+	waitTasks(t, b)
+	if calls := fake.received("chat.postMessage"); len(calls) > 0 {
+		t.Errorf("handled a rejected request, got %d messages", len(calls))
+	}
+
+	called := false
+	handler = onlyMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) { called = true })
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/receive", nil))
+	if !called || w.Code != http.StatusOK {
+		t.Errorf("got status %d and called %t, want the POST handled", w.Code, called)
+	}
+}
+
+func TestExitOnSignal(t *testing.T) {
+	quit := make(chan os.Signal, 1)
+	exited := make(chan int, 1)
+	go exitOnSignal(quit, discardLogger(), func(code int) { exited <- code })
+
+	select {
+	case code := <-exited:
+		t.Fatalf("exited with %d before a second signal", code)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	quit <- syscall.SIGTERM
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("exited with %d, want 1", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("didn't exit on the second signal")
+	}
+}
+
+func TestLoadDotenvCustomPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taina.env")
+	if err := os.WriteFile(path, []byte("TAINA_TEST_DOTENV=from the custom file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOTENV_PATH", path)
+	os.Unsetenv("TAINA_TEST_DOTENV")
+	t.Cleanup(func() { os.Unsetenv("TAINA_TEST_DOTENV") })
+
+	if got := dotenvPath(); got != path {
+		t.Errorf("got path %q, want DOTENV_PATH %q", got, path)
+	}
+	loadDotenv()
+	if got := os.Getenv("TAINA_TEST_DOTENV"); got != "from the custom file" {
+		t.Errorf("got %q, want the value of the custom file", got)
+	}
+
+	// a missing file is only warned about
+	t.Setenv("DOTENV_PATH", filepath.Join(t.TempDir(), "missing.env"))
+	loadDotenv()
+
+	t.Setenv("DOTENV_PATH", "")
+	if got := dotenvPath(); got != ".env" {
+		t.Errorf("got path %q without DOTENV_PATH, want .env", got)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want int
+		ok   bool
+	}{
+		{"default", "", nil, 8080, true},
+		{"PORT", "3000", nil, 3000, true},
+		{"flag overrides PORT", "3000", []string{"-port", "4000"}, 4000, true},
+		{"highest port", "65535", nil, 65535, true},
+		{"zero", "0", nil, 0, false},
+		{"above the range", "65536", nil, 0, false},
+		{"negative flag", "", []string{"-port", "-1"}, 0, false},
+		{"not a number", "http", nil, 0, false},
+		{"unknown flag", "", []string{"-bogus"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PORT", tt.env)
+			t.Setenv("NEWS_PROVIDER", "")
+			port, provider, err := parseFlags(tt.args)
+			if ok := err == nil; ok != tt.ok {
+				t.Fatalf("got error %v, want success %t", err, tt.ok)
+			}
+			if port != tt.want {
+				t.Errorf("got port %d, want %d", port, tt.want)
+			}
+			if tt.ok && provider != "nyt" {
+				t.Errorf("got provider %q, want the default nyt", provider)
+			}
+		})
+	}
+
+	t.Setenv("NEWS_PROVIDER", "guardian")
+	if _, provider, err := parseFlags([]string{"-provider", "nyt"}); err != nil || provider != "nyt" {
+		t.Errorf("got provider %q and error %v, want the flag to override NEWS_PROVIDER", provider, err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// defaultMaintenanceMessage is shown during maintenance when no message is configured
+const defaultMaintenanceMessage = "🚧 The news are taking a short break for maintenance. Try again in a few minutes!"
+
+// maintenanceMode makes the bot answer every command with a message instead of fetching the news,
+// e.g. during deploys or known NYT outages. It is safe for concurrent use.
+type maintenanceMode struct {
+	mu      sync.RWMutex
+	enabled bool
+	message string
+}
+
+func newMaintenanceMode(cfg Config) *maintenanceMode {
+	m := &maintenanceMode{}
+	m.set(cfg.maintenance, cfg.maintenanceMessage)
+	return m
+}
+
+// set enables or disables the maintenance mode, with the message shown to users
+func (m *maintenanceMode) set(enabled bool, message string) {
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = enabled
+	m.message = message
+}
+
+// active returns the maintenance message when the maintenance mode is enabled
+func (m *maintenanceMode) active() (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.message, m.enabled
+}
+
+// reload reads the maintenance settings again. The environment of a running process
+// can't change, so the env file is loaded again first, letting operators toggle the maintenance
+// mode by editing it and sending a SIGHUP.
+func (m *maintenanceMode) reload(envFile string) error {
+	if _, err := os.Stat(envFile); err == nil {
+		if err := godotenv.Overload(envFile); err != nil {
+			return err
+		}
+	}
+	// unlike at startup, an invalid value must not bring the bot down
+	enabled := false
+	if value := os.Getenv("MAINTENANCE"); value != "" {
+		var err error
+		if enabled, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid value %q for MAINTENANCE: %w", value, err)
+		}
+	}
+	m.set(enabled, os.Getenv("MAINTENANCE_MESSAGE"))
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMaintenanceShortCircuitsCommands(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"home": testArticles(3), "world": testArticles(3)}}
+	texts := append([]string{"", "help", "stories world --public", "unknown"}, commands...)
+	for _, text := range texts {
+		t.Run(text, func(t *testing.T) {
+			b, fake := newTestBot(t, news, func(cfg *Config) {
+				cfg.maintenance = true
+				cfg.maintenanceMessage = "Back soon"
+			})
+			responses := runCommand(t, b, fake, testCommandRequest(fake), text)
+			if requests := news.requested(); len(requests) > 0 {
+				t.Errorf("requested %v during maintenance", requests)
+			}
+			if len(responses) != 1 || responses[0].message["text"] != "Back soon" {
+				t.Fatalf("got responses %+v, want the maintenance message only", responses)
+			}
+			if got := responses[0].message["response_type"]; got != "ephemeral" {
+				t.Errorf("got response type %v, want the message shown only to the user", got)
+			}
+		})
+	}
+}
+
+func TestMaintenanceShortCircuitsQuickReplies(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.maintenance = true })
+	postInteraction(t, b, quickReplyInteraction(fake.responseURL, "world"))
+	waitTasks(t, b)
+
+	if requests := news.requested(); len(requests) > 0 {
+		t.Errorf("requested %v during maintenance", requests)
+	}
+	responses := fake.received("response")
+	if len(responses) != 1 || responses[0].message["text"] != defaultMaintenanceMessage {
+		t.Errorf("got responses %+v, want the default maintenance message", responses)
+	}
+}
+
+func TestMaintenanceReload(t *testing.T) {
+	t.Setenv("MAINTENANCE", "")
+	t.Setenv("MAINTENANCE_MESSAGE", "")
+	envFile := filepath.Join(t.TempDir(), ".env")
+	m := newMaintenanceMode(Config{})
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(envFile, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("MAINTENANCE=true\nMAINTENANCE_MESSAGE=Back soon\n")
+	if err := m.reload(envFile); err != nil {
+		t.Fatal(err)
+	}
+	if message, ok := m.active(); !ok || message != "Back soon" {
+		t.Errorf("got %q, %t, want the maintenance mode enabled with the new message", message, ok)
+	}
+
+	write("MAINTENANCE=false\n")
+	if err := m.reload(envFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.active(); ok {
+		t.Error("the maintenance mode is still enabled")
+	}
+
+	// an invalid value keeps the current settings
+	write("MAINTENANCE=maybe\n")
+	if err := m.reload(envFile); err == nil || !strings.Contains(err.Error(), "maybe") {
+		t.Errorf("got error %v, want the invalid value reported", err)
+	}
+	if _, ok := m.active(); ok {
+		t.Error("an invalid value enabled the maintenance mode")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// metrics keeps in-process counters about the requests handled by the bot.
+// It is safe for concurrent use.
+type metrics struct {
+	mu       sync.Mutex
+	requests map[string]int // requests per section
+	errors   map[string]int // failed requests per section
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests: map[string]int{},
+		errors:   map[string]int{},
+	}
+}
+
+// recordRequest counts a request for the given section, and an error if err is not nil
+func (m *metrics) recordRequest(section string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[section]++
+	if err != nil {
+		m.errors[section]++
+	}
+}
+
+// metricsSnapshot is the serializable view of the metrics at a point in time
+type metricsSnapshot struct {
+	Timestamp         time.Time      `json:"timestamp"`
+	TotalRequests     int            `json:"total_requests"`
+	TotalErrors       int            `json:"total_errors"`
+	ErrorRate         float64        `json:"error_rate"`
+	RequestsBySection map[string]int `json:"requests_by_section"`
+	ErrorsBySection   map[string]int `json:"errors_by_section"`
+}
+
+// snapshot copies the current counters into a metricsSnapshot
+func (m *metrics) snapshot(now time.Time) metricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := metricsSnapshot{
+		Timestamp:         now.UTC(),
+		RequestsBySection: make(map[string]int, len(m.requests)),
+		ErrorsBySection:   make(map[string]int, len(m.errors)),
+	}
+	for section, n := range m.requests {
+		s.RequestsBySection[section] = n
+		s.TotalRequests += n
+	}
+	for section, n := range m.errors {
+		s.ErrorsBySection[section] = n
+		s.TotalErrors += n
+	}
+	if s.TotalRequests > 0 {
+		s.ErrorRate = float64(s.TotalErrors) / float64(s.TotalRequests)
+	}
+	return s
+}
+
+// ----//----
+
+// metricsSnapshotter persists metrics snapshots so ephemeral containers retain some history.
+// Snapshots are appended as JSON lines to path, or logged when no path is configured.
+type metricsSnapshotter struct {
+	metrics *metrics
+	path    string
+	logger  *slog.Logger
+}
+
+func newMetricsSnapshotter(m *metrics, path string, logger *slog.Logger) *metricsSnapshotter {
+	return &metricsSnapshotter{
+		metrics: m,
+		path:    path,
+		logger:  logger,
+	}
+}
+
+// write serializes the current metrics and persists them
+func (s *metricsSnapshotter) write() error {
+	data, err := json.Marshal(s.metrics.snapshot(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if s.path == "" {
+		s.logger.Info("metrics snapshot", "snapshot", string(data))
+		return nil
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
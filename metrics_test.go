@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetricsSnapshotterWrite(t *testing.T) {
+	m := newMetrics()
+	m.recordRequest("world", nil)
+	m.recordRequest("world", errors.New("boom"))
+	m.recordRequest("science", nil)
+	m.recordRequest("science", nil)
+
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	s := newMetricsSnapshotter(m, path, discardLogger())
+	if err := s.write(); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	m.recordRequest("science", nil)
+	if err := s.write(); err != nil {
+		t.Fatalf("second write: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d snapshots, want 2 appended lines", len(lines))
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(lines[0]), &fields); err != nil {
+		t.Fatalf("snapshot isn't valid JSON: %v", err)
+	}
+	for _, field := range []string{"timestamp", "total_requests", "total_errors", "error_rate", "requests_by_section", "errors_by_section"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("snapshot is missing the %q field", field)
+		}
+	}
+
+	var snapshot metricsSnapshot
+	if err := json.Unmarshal([]byte(lines[0]), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.TotalRequests != 4 || snapshot.TotalErrors != 1 {
+		t.Errorf("got %d requests and %d errors, want 4 and 1", snapshot.TotalRequests, snapshot.TotalErrors)
+	}
+	if snapshot.ErrorRate != 0.25 {
+		t.Errorf("got error rate %v, want 0.25", snapshot.ErrorRate)
+	}
+	if snapshot.RequestsBySection["world"] != 2 || snapshot.RequestsBySection["science"] != 2 {
+		t.Errorf("got requests by section %v", snapshot.RequestsBySection)
+	}
+	if snapshot.ErrorsBySection["world"] != 1 {
+		t.Errorf("got errors by section %v", snapshot.ErrorsBySection)
+	}
+	if snapshot.Timestamp.IsZero() {
+		t.Error("snapshot has no timestamp")
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.TotalRequests != 5 {
+		t.Errorf("got %d requests in the second snapshot, want 5", snapshot.TotalRequests)
+	}
+}
+
+func TestMetricsSnapshotNoRequests(t *testing.T) {
+	snapshot := newMetrics().snapshot(testNow)
+	if snapshot.ErrorRate != 0 || snapshot.TotalRequests != 0 {
+		t.Errorf("got %+v, want an empty snapshot", snapshot)
+	}
+	if !snapshot.Timestamp.Equal(testNow) {
+		t.Errorf("got timestamp %s, want %s", snapshot.Timestamp, testNow)
+	}
+}
@@ -2,96 +2,1061 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/tainacleal/nyt-go/nyttop"
 )
 
 var (
 	ErrInvalidSection = errors.New("invalid section")
+	ErrRateLimited    = errors.New("rate limited")
+	// ErrLanguageUnavailable is returned when a source has no content in the requested language
+	ErrLanguageUnavailable = errors.New("language unavailable")
+	// ErrUpstreamUnavailable is returned when the source answers with something else than its API,
+	// e.g. the HTML page it shows during outages
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	// ErrProductNotEnabled is returned when the API key isn't allowed to use an endpoint of the source
+	ErrProductNotEnabled = errors.New("product not enabled for API key")
+	// ErrInvalidMetric and ErrInvalidPeriod are returned for popular stories requested with an
+	// unknown metric or period
+	ErrInvalidMetric = errors.New("invalid popularity metric")
+	ErrInvalidPeriod = errors.New("invalid popularity period")
+	// ErrUnsupported is returned for the requests a source can't serve, e.g. the Guardian has no
+	// popularity data
+	ErrUnsupported = errors.New("not supported by the source")
 )
 
+// errNotFound is returned when NYT doesn't know the requested resource, e.g. a deprecated section
+var errNotFound = errors.New("not found")
+
+// languageNames maps the supported language codes to their names
+var languageNames = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+}
+
+// languageName returns the name of a language code, or the code itself if it's unknown
+func languageName(lang string) string {
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	return fmt.Sprintf("%q", lang)
+}
+
 // Article holds the information we need to render a Slack Block response
 type Article struct {
-	Title       string
-	Abstract    string
-	URL         string
-	PublishedAt string
+	Title       string `json:"title"`
+	Abstract    string `json:"abstract"`
+	URL         string `json:"url"`
+	PublishedAt string `json:"published_at"`
+	// CanonicalURL identifies the article across the different endpoints of a source
+	CanonicalURL string `json:"canonical_url"`
+	// Breaking is set for articles covering breaking news
+	Breaking bool `json:"breaking"`
+	// ImageURL is the article thumbnail, empty when the article has none
+	ImageURL string `json:"image_url"`
+	// Section is the normalized section the source filed the story under, if known
+	Section string `json:"section"`
+	// MaterialType (e.g. 'Review', 'Op-Ed') and NewsDesk describe the kind of story, if known
+	MaterialType string `json:"material_type"`
+	NewsDesk     string `json:"news_desk"`
+	// PublishedTime and UpdatedTime are the zero time when the source doesn't provide them
+	PublishedTime time.Time `json:"published_time"`
+	UpdatedTime   time.Time `json:"updated_time"`
 }
 
 // NewsSource is an interface that should be implemented by types that can retrieve top news stories
 type NewsSource interface {
 	TopStories(ctx context.Context, section string, topN int) ([]Article, error)
+	// PopularStories returns the most popular stories for a metric (viewed, emailed or shared)
+	// over the last period days, ordered by popularity
+	PopularStories(ctx context.Context, metric string, period int) ([]Article, error)
+	// SearchByAuthor returns the most recent stories written by author
+	SearchByAuthor(ctx context.Context, author string, topN int) ([]Article, error)
+	// SearchArticles returns the most recent stories matching a keyword query
+	SearchArticles(ctx context.Context, query string, limit int) ([]Article, error)
+	// LocalizedStories returns the top stories of a section in the given language,
+	// or ErrLanguageUnavailable if the section has no content in that language
+	LocalizedStories(ctx context.Context, section string, lang string, topN int) ([]Article, error)
+	// ArchiveStories returns the stories published on the given day, which may be none
+	ArchiveStories(ctx context.Context, year int, month time.Month, day int) ([]Article, error)
 	SupportedSections() []string
 	UserFriendlySection(section string) string
+	// BrandColor is the hex color used to highlight the stories of the source
+	BrandColor() string
+	// MaxStories is the maximum number of top stories the source can return at once
+	MaxStories() int
+}
+
+// sectionAliases maps the spellings of a section found in the wild to its canonical name.
+// NYT expects 'us' in requests, but labels the same stories 'U.S.' in its responses, and users
+// type the names they are used to.
+var sectionAliases = map[string]string{
+	"u.s.":        "us",
+	"u.s":         "us",
+	"usa":         "us",
+	"tech":        "technology",
+	"sport":       "sports",
+	"world news":  "world",
+	"film":        "movies",
+	"films":       "movies",
+	"theatre":     "theater",
+	"real estate": "realestate",
+	"cars":        "automobile",
+	"autos":       "automobile",
+}
+
+// normalizeSection returns the canonical name of a section, lowercased and with aliases resolved
+func normalizeSection(section string) string {
+	section = strings.ToLower(strings.TrimSpace(section))
+	if canonical, ok := sectionAliases[section]; ok {
+		return canonical
+	}
+	return section
+}
+
+// unsupportedSection returns the first of the sections the news source doesn't support, if any,
+// e.g. to validate the sections of the settings
+func unsupportedSection(newsSource NewsSource, sections ...string) (string, bool) {
+	supported := map[string]bool{}
+	for _, section := range newsSource.SupportedSections() {
+		supported[section] = true
+	}
+	for _, section := range sections {
+		if !supported[section] {
+			return section, true
+		}
+	}
+	return "", false
+}
+
+// resolveSection matches a section typed by a user to one of the supported sections. Aliases are
+// resolved first, then typos are forgiven: the closest supported section is picked when it is
+// within a couple of edits, and no other section is as close.
+func resolveSection(input string, supported []string) (string, bool) {
+	section := normalizeSection(input)
+	for _, s := range supported {
+		if s == section {
+			return s, true
+		}
+	}
+
+	// short names are only a few edits apart from each other, only forgive them a single typo
+	maxDistance := 2
+	if len([]rune(section)) <= 4 {
+		maxDistance = 1
+	}
+	best, bestDistance, tie := "", maxDistance+1, false
+	for _, s := range supported {
+		distance := levenshtein(section, s)
+		switch {
+		case distance < bestDistance:
+			best, bestDistance, tie = s, distance, false
+		case distance == bestDistance:
+			tie = true
+		}
+	}
+	if best == "" || tie {
+		return "", false
+	}
+	return best, true
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// rankByPopularity reorders articles so the ones present in popular come first, following
+// their popularity rank. Articles that aren't popular keep their original relative order.
+func rankByPopularity(articles []Article, popular []Article) []Article {
+	rank := make(map[string]int, len(popular))
+	for i, p := range popular {
+		if _, ok := rank[p.CanonicalURL]; !ok {
+			rank[p.CanonicalURL] = i
+		}
+	}
+
+	ranked := make([]Article, len(articles))
+	copy(ranked, articles)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		ri, iok := rank[ranked[i].CanonicalURL]
+		rj, jok := rank[ranked[j].CanonicalURL]
+		if iok && jok {
+			return ri < rj
+		}
+		return iok && !jok
+	})
+	return ranked
 }
 
 // ----//----
 
+const nytBaseURL = "https://api.nytimes.com/svc"
+
 // NYTimes can communicate with The New York Times API. It implements the NewsSource interface.
 type NYTimes struct {
 	APIKey string
+
+	baseURL string
+	// httpClient is built once and shared by all the requests, so they reuse its pooled connections.
+	// It replaced the nyttop client built for each top stories request, and is safe for concurrent
+	// use.
+	httpClient *http.Client
+	// gate is shared by all the NYT endpoints, since the rate limit applies to the API key
+	gate *backoffGate
+
+	// sections lists the supported sections in display order, and sectionKeys maps each
+	// of them to the key NYT expects in requests
+	sections    []string
+	sectionKeys map[string]string
+	// health hides the sections NYT keeps rejecting, which may have been deprecated
+	health *sectionHealth
+	// archive caches the months fetched from the Archive API
+	archive *ttlCache[archiveMonth, map[int][]Article]
+
+	// attempts is how many times a request is attempted on transient errors, waiting retryDelay
+	// before the first retry. Each attempt gets a share of the time left before the deadline of
+	// the request, and at least minAttemptTimeout.
+	attempts          int
+	retryDelay        time.Duration
+	minAttemptTimeout time.Duration
+
+	// preferFullURLs links the stories to their full URL rather than their nyti.ms short URL
+	preferFullURLs bool
+
+	// observe is called after each request with the section it was for, if set
+	observe requestObserver
+	logger  *slog.Logger
+}
+
+// requestObserver observes a request to a news source, e.g. to export its latency. section is
+// the requested section, or the endpoint for the requests not tied to a section.
+type requestObserver func(section string, elapsed time.Duration, err error)
+
+// NYTimesOption configures a NYTimes client
+type NYTimesOption func(*nytConfig)
+
+type nytConfig struct {
+	httpClient        *http.Client
+	sectionOverrides  map[string]string
+	preferFullURLs    bool
+	timeout           time.Duration
+	attempts          int
+	retryDelay        time.Duration
+	minAttemptTimeout time.Duration
+	observe           requestObserver
+	logger            *slog.Logger
+}
+
+// WithSectionOverrides maps user facing sections to NYT section keys, taking precedence over the
+// built-in mapping. It lets operators fix a section NYT renamed or deprecated without a release.
+func WithSectionOverrides(overrides map[string]string) NYTimesOption {
+	return func(c *nytConfig) {
+		c.sectionOverrides = overrides
+	}
+}
+
+// WithFullURLs links the stories to their full URL instead of their short URL, which reads
+// better and unfurls with a preview in some clients
+func WithFullURLs(preferFullURLs bool) NYTimesOption {
+	return func(c *nytConfig) {
+		c.preferFullURLs = preferFullURLs
+	}
 }
 
-func NewNYTimes(apiKey string) *NYTimes {
-	return &NYTimes{
-		APIKey: apiKey,
+// WithHTTPClient sets the client sending the requests to NYT, e.g. to set up its transport.
+// The client timeout is replaced by the one of WithTimeout.
+func WithHTTPClient(client *http.Client) NYTimesOption {
+	return func(c *nytConfig) {
+		c.httpClient = client
 	}
 }
 
+// WithTimeout sets the timeout of each request to NYT
+func WithTimeout(timeout time.Duration) NYTimesOption {
+	return func(c *nytConfig) {
+		c.timeout = timeout
+	}
+}
+
+// WithAttempts sets how many times a request is attempted when it fails with a transient error
+func WithAttempts(attempts int) NYTimesOption {
+	return func(c *nytConfig) {
+		c.attempts = attempts
+	}
+}
+
+// WithRetryDelay sets the delay before retrying a request, which doubles with each retry
+func WithRetryDelay(delay time.Duration) NYTimesOption {
+	return func(c *nytConfig) {
+		c.retryDelay = delay
+	}
+}
+
+// WithMinAttemptTimeout sets the least time given to an attempt of a request with a deadline. The
+// time left is split between the attempts left, so a slow attempt doesn't starve the retries.
+func WithMinAttemptTimeout(timeout time.Duration) NYTimesOption {
+	return func(c *nytConfig) {
+		c.minAttemptTimeout = timeout
+	}
+}
+
+// WithRequestObserver sets a function observing every request to NYT, each retry observed as a
+// request of its own
+func WithRequestObserver(observe requestObserver) NYTimesOption {
+	return func(c *nytConfig) {
+		c.observe = observe
+	}
+}
+
+// WithLogger sets the logger of the retries, rate limits and outages, which defaults to slog's
+// default logger
+func WithLogger(logger *slog.Logger) NYTimesOption {
+	return func(c *nytConfig) {
+		c.logger = logger
+	}
+}
+
+// nytMaxIdleConns is the number of idle connections kept open to NYT. The default transport only
+// keeps two per host, which forces concurrent commands to open new connections.
+const nytMaxIdleConns = 16
+
+// newNYTTransport returns the transport of the default NYT client, keeping more connections open
+// than the default one
+func newNYTTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = nytMaxIdleConns
+	return transport
+}
+
+func init() {
+	registerNewsProvider("nyt", newNYTProvider)
+}
+
+// newNYTProvider is the news provider of NYT, configured by the NYT_* settings
+func newNYTProvider(cfg Config, observe requestObserver, logger *slog.Logger) (NewsSource, error) {
+	if cfg.nytAPIKey == "" {
+		return nil, errors.New("missing NYT_API_KEY")
+	}
+	return NewNYTimes(cfg.nytAPIKey,
+		WithSectionOverrides(cfg.nytSectionOverrides),
+		WithFullURLs(cfg.nytPreferFullURLs),
+		WithTimeout(cfg.nytTimeout),
+		WithAttempts(cfg.nytAttempts),
+		WithRetryDelay(cfg.nytRetryDelay),
+		WithMinAttemptTimeout(cfg.nytMinAttemptTimeout),
+		WithRequestObserver(observe),
+		WithLogger(logger),
+	)
+}
+
+// sectionKeyPattern matches the valid section names and NYT section keys
+var sectionKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
+
+func NewNYTimes(apiKey string, options ...NYTimesOption) (*NYTimes, error) {
+	cfg := &nytConfig{timeout: 30 * time.Second, attempts: 1, retryDelay: 500 * time.Millisecond, minAttemptTimeout: time.Second, logger: slog.Default()}
+	for _, opt := range options {
+		opt(cfg)
+	}
+	if cfg.timeout <= 0 || cfg.attempts < 1 || cfg.retryDelay < 0 || cfg.minAttemptTimeout < 0 {
+		return nil, fmt.Errorf("invalid NYT timeout %s, attempts %d, retry delay %s or min attempt timeout %s",
+			cfg.timeout, cfg.attempts, cfg.retryDelay, cfg.minAttemptTimeout)
+	}
+	// copy the client so setting the timeout doesn't change the caller's client
+	httpClient := &http.Client{Transport: newNYTTransport()}
+	if cfg.httpClient != nil {
+		*httpClient = *cfg.httpClient
+	}
+	httpClient.Timeout = cfg.timeout
+
+	nyt := &NYTimes{
+		APIKey:            apiKey,
+		baseURL:           nytBaseURL,
+		httpClient:        httpClient,
+		attempts:          cfg.attempts,
+		retryDelay:        cfg.retryDelay,
+		minAttemptTimeout: cfg.minAttemptTimeout,
+		gate:              newBackoffGate(),
+		sections:          append([]string(nil), defaultNYTSections...),
+		sectionKeys:       map[string]string{},
+		health:            newSectionHealth(cfg.logger),
+		archive:           newTTLCache[archiveMonth, map[int][]Article](nytArchiveTTL),
+		preferFullURLs:    cfg.preferFullURLs,
+		observe:           cfg.observe,
+		logger:            cfg.logger,
+	}
+	for _, section := range defaultNYTSections {
+		nyt.sectionKeys[section] = section
+	}
+
+	// sort the overrides so the sections they add are listed in a stable order
+	var overridden []string
+	for section := range cfg.sectionOverrides {
+		overridden = append(overridden, section)
+	}
+	sort.Strings(overridden)
+	for _, section := range overridden {
+		key := cfg.sectionOverrides[section]
+		if !sectionKeyPattern.MatchString(section) {
+			return nil, fmt.Errorf("invalid section name %q in section overrides", section)
+		}
+		if !sectionKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid NYT section key %q for section %q in section overrides", key, section)
+		}
+		if _, ok := nyt.sectionKeys[section]; !ok {
+			nyt.sections = append(nyt.sections, section)
+		}
+		nyt.sectionKeys[section] = key
+	}
+
+	return nyt, nil
+}
+
+// get sends a GET request to the given NYT API path and decodes the JSON response into v.
+// Once NYT rate limits us, every call fails fast with ErrRateLimited until the backoff expires.
+// Transient failures (timeouts, connection resets, 5xx responses) are retried up to the configured
+// attempts, with an exponential backoff. section labels each attempt for the observer.
+func (nyt *NYTimes) get(ctx context.Context, section string, path string, query url.Values, v interface{}) error {
+	var err error
+	for attempt := 1; attempt <= nyt.attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryDelay(nyt.retryDelay, attempt)):
+			}
+		}
+		attemptCtx, cancel := nyt.attemptContext(ctx, attempt)
+		start := time.Now()
+		err = nyt.getOnce(attemptCtx, path, query, v)
+		// the attempt ran out of its share of time, the retries may have enough left
+		if err != nil && attemptCtx.Err() != nil && ctx.Err() == nil {
+			err = transientError{err}
+		}
+		cancel()
+		if nyt.observe != nil {
+			nyt.observe(section, time.Since(start), err)
+		}
+		var transient transientError
+		if !errors.As(err, &transient) {
+			return err
+		}
+		nyt.logger.Warn("transient error requesting NYT", "path", path, "attempt", attempt, "attempts", nyt.attempts, "error", err)
+	}
+	return err
+}
+
+// attemptContext returns the context of an attempt of a request. When the request has a deadline,
+// the attempt gets its share of the time left, so a slow attempt doesn't starve the retries. The
+// attempt never outlives the deadline of the request.
+func (nyt *NYTimes) attemptContext(ctx context.Context, attempt int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || attempt == nyt.attempts {
+		return context.WithCancel(ctx)
+	}
+	timeout := attemptTimeout(time.Until(deadline), nyt.attempts-attempt+1, nyt.minAttemptTimeout)
+	return context.WithTimeout(ctx, timeout)
+}
+
+// attemptTimeout splits the time left evenly between the attempts left, giving each at least
+// minTimeout
+func attemptTimeout(remaining time.Duration, attemptsLeft int, minTimeout time.Duration) time.Duration {
+	timeout := remaining / time.Duration(attemptsLeft)
+	if timeout < minTimeout {
+		return minTimeout
+	}
+	return timeout
+}
+
+// retryDelay returns how long to wait before an attempt of a request: the base delay before the
+// second attempt, doubling with each attempt after it. A random jitter of up to half the delay is
+// added, so the clients failing together don't retry together.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	delay := base << (attempt - 2)
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// transientError marks the errors worth retrying
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string { return e.err.Error() }
+func (e transientError) Unwrap() error { return e.err }
+
+// getOnce sends a single GET request, see get
+func (nyt *NYTimes) getOnce(ctx context.Context, path string, query url.Values, v interface{}) error {
+	if !nyt.gate.open() {
+		return ErrRateLimited
+	}
+
+	values := url.Values{}
+	for key, value := range query {
+		values[key] = value
+	}
+	values.Set("api-key", nyt.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nyt.baseURL+path+"?"+values.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := nyt.httpClient.Do(req)
+	if err != nil {
+		var netErr net.Error
+		timeout := errors.As(err, &netErr) && netErr.Timeout()
+		if (timeout || errors.Is(err, syscall.ECONNRESET)) && ctx.Err() == nil {
+			return transientError{err}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		backoff := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		nyt.logger.Warn("rate limited by NYT, backing off", "backoff", backoff)
+		nyt.gate.backoff(backoff)
+		return ErrRateLimited
+	}
+	if isProductNotEnabled(resp) {
+		return fmt.Errorf("%w: %s", ErrProductNotEnabled, path)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return transientError{fmt.Errorf("request status: %d", resp.StatusCode)}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: request status: %d", errNotFound, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request status: %d", resp.StatusCode)
+	}
+	// during outages NYT may serve an HTML error page with a 200 status
+	if contentType := resp.Header.Get("Content-Type"); !isJSONContentType(contentType) {
+		nyt.logger.Warn("NYT answered with a response that isn't JSON, it is likely having an outage", "path", path, "content_type", contentType)
+		return ErrUpstreamUnavailable
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// isJSONContentType tells whether the content type is JSON, e.g. 'application/json; charset=utf-8'
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// isProductNotEnabled tells whether NYT refused the request because the API key isn't enabled for
+// the API product of the endpoint. Keys are enabled per product (Top Stories, Most Popular...), and
+// NYT either forbids the request or rejects the key "for given resource" when it isn't.
+func isProductNotEnabled(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusForbidden:
+		return true
+	case http.StatusUnauthorized:
+		var body struct {
+			Fault struct {
+				FaultString string `json:"faultstring"`
+			} `json:"fault"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err != nil {
+			return false
+		}
+		return strings.Contains(body.Fault.FaultString, "for given resource")
+	}
+	return false
+}
+
 // TopStories retrieves the top stories from The NY Times.
 func (nyt *NYTimes) TopStories(ctx context.Context, section string, topN int) ([]Article, error) {
-	client := nyttop.New(nyt.APIKey)
-	articles, err := client.TopNStories(ctx, nyttop.Section(section), topN)
-	if err != nil {
-		if err == nyttop.ErrInvalidSection {
-			return nil, ErrInvalidSection
+	section = normalizeSection(section)
+	key, ok := nyt.sectionKeys[section]
+	if !ok {
+		return nil, ErrInvalidSection
+	}
+
+	var resp nytTopStoriesResponse
+	if err := nyt.get(ctx, section, fmt.Sprintf("/topstories/v2/%s.json", key), nil, &resp); err != nil {
+		if errors.Is(err, errNotFound) {
+			nyt.health.fail(section)
 		}
 		return nil, err
 	}
+	nyt.health.succeed(section)
+
+	articles := resp.Results
+	if topN < len(articles) {
+		articles = articles[:topN]
+	}
 
 	result := []Article{}
 	for _, a := range articles {
 		// basic validation to make sure we have at least a title and a link
-		if a.Title == "" || a.ShortURL == "" {
+		link := nyt.articleURL(a)
+		if a.Title == "" || link == "" {
 			continue
 		}
+		canonicalURL := a.URL
+		if canonicalURL == "" {
+			canonicalURL = a.ShortURL
+		}
 		result = append(result, Article{
-			Title:       a.Title,
-			Abstract:    a.Abstract,
-			URL:         a.ShortURL,
-			PublishedAt: a.PublishedAt.Local().Format("January 02, 2006"),
+			Title:        a.Title,
+			Abstract:     a.Abstract,
+			URL:          link,
+			PublishedAt:  formatPublishedAt(a.PublishedAt),
+			CanonicalURL: canonicalURL,
+			Breaking:     a.isBreaking(),
+			ImageURL:     a.imageURL(),
+			Section:      normalizeSection(a.Section),
+			MaterialType: a.MaterialType,
+
+			PublishedTime: a.PublishedAt,
+			UpdatedTime:   a.UpdatedAt,
 		})
 	}
 
 	return result, nil
 }
 
+// formatPublishedAt formats the publication date of an article, leaving it empty when NYT didn't
+// send one
+func formatPublishedAt(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("January 02, 2006")
+}
+
+// articleURL picks the link of the article according to the URL preference, falling back to
+// whichever URL the article has
+func (nyt *NYTimes) articleURL(a nytArticle) string {
+	preferred, fallback := a.ShortURL, a.URL
+	if nyt.preferFullURLs {
+		preferred, fallback = fallback, preferred
+	}
+	if preferred != "" {
+		return preferred
+	}
+	return fallback
+}
+
+// nytTopStoriesResponse is the response of the NYT Top Stories API
+type nytTopStoriesResponse struct {
+	Results []nytArticle `json:"results"`
+}
+
+// nytArticle extends the nyttop article with the fields the library doesn't map
+type nytArticle struct {
+	nyttop.Article
+	ItemType     string            `json:"item_type"`
+	Kicker       string            `json:"kicker"`
+	MaterialType string            `json:"material_type_facet"`
+	Multimedia   nytMultimediaList `json:"multimedia"`
+}
+
+// UnmarshalJSON decodes the dates of the article leniently, see nytTime
+func (a *nytArticle) UnmarshalJSON(data []byte) error {
+	// the dates shadow the ones of the nyttop article, which would fail on empty strings
+	type article nytArticle
+	var decoded struct {
+		article
+		UpdatedAt   nytTime `json:"updated_date"`
+		CreatedAt   nytTime `json:"created_date"`
+		PublishedAt nytTime `json:"published_date"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*a = nytArticle(decoded.article)
+	a.UpdatedAt = time.Time(decoded.UpdatedAt)
+	a.CreatedAt = time.Time(decoded.CreatedAt)
+	a.PublishedAt = time.Time(decoded.PublishedAt)
+	return nil
+}
+
+// nytTime is a date of an article. NYT sends an empty string for the dates an article doesn't
+// have, and the dates are optional, so anything but an RFC 3339 date decodes as no date.
+type nytTime time.Time
+
+func (t *nytTime) UnmarshalJSON(data []byte) error {
+	*t = nytTime{}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		*t = nytTime(parsed)
+	}
+	return nil
+}
+
+// nytMultimedia is one of the renditions of the media attached to an article
+type nytMultimedia struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	Type   string `json:"type"`
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+}
+
+// nytMultimediaList is the media attached to an article. NYT sends null or an empty string
+// instead of an empty array for the articles without media, and the media is optional, so
+// anything but an array decodes as no media and the malformed entries are skipped.
+type nytMultimediaList []nytMultimedia
+
+func (l *nytMultimediaList) UnmarshalJSON(data []byte) error {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		*l = nil
+		return nil
+	}
+	media := nytMultimediaList{}
+	for _, entry := range entries {
+		var m nytMultimedia
+		if err := json.Unmarshal(entry, &m); err == nil {
+			media = append(media, m)
+		}
+	}
+	*l = media
+	return nil
+}
+
+// valid tells whether the rendition is an image with an absolute URL slack can fetch
+func (m nytMultimedia) valid() bool {
+	if m.Type != "" && m.Type != "image" {
+		return false
+	}
+	u, err := url.Parse(m.URL)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// nytImageFormats are the image renditions we prefer as thumbnails, in order of preference
+var nytImageFormats = []string{"threeByTwoSmallAt2X", "Large Thumbnail", "Standard Thumbnail"}
+
+// imageURL picks the best thumbnail of the article, or returns an empty string if it has none.
+// Articles with none of the preferred renditions fall back to their first valid one. Renditions
+// without a valid image URL are skipped, a broken image would make slack reject the whole message.
+func (a nytArticle) imageURL() string {
+	for _, format := range nytImageFormats {
+		for _, m := range a.Multimedia {
+			if m.Format == format && m.valid() {
+				return m.URL
+			}
+		}
+	}
+	for _, m := range a.Multimedia {
+		if m.valid() {
+			return m.URL
+		}
+	}
+	return ""
+}
+
+// isBreaking tells whether the article covers breaking news. NYT covers those with live
+// blogs, which are flagged by their item type, their kicker or their URL.
+func (a nytArticle) isBreaking() bool {
+	kicker := strings.ToLower(a.Kicker)
+	return strings.EqualFold(a.ItemType, "LiveBlog") ||
+		strings.Contains(kicker, "breaking") ||
+		strings.Contains(kicker, "live") ||
+		strings.Contains(a.URL, "/live/")
+}
+
+// nytPopularResponse is the response of the NYT Most Popular API
+type nytPopularResponse struct {
+	Results []struct {
+		Title         string `json:"title"`
+		Abstract      string `json:"abstract"`
+		URL           string `json:"url"`
+		PublishedDate string `json:"published_date"`
+	} `json:"results"`
+}
+
+// PopularStories retrieves the most popular stories from The NY Times.
+func (nyt *NYTimes) PopularStories(ctx context.Context, metric string, period int) ([]Article, error) {
+	switch metric {
+	case "viewed", "emailed", "shared":
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidMetric, metric)
+	}
+	switch period {
+	case 1, 7, 30:
+	default:
+		return nil, fmt.Errorf("%w %d", ErrInvalidPeriod, period)
+	}
+
+	var resp nytPopularResponse
+	if err := nyt.get(ctx, "popular", fmt.Sprintf("/mostpopular/v2/%s/%d.json", metric, period), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	result := []Article{}
+	for _, a := range resp.Results {
+		if a.Title == "" || a.URL == "" {
+			continue
+		}
+		article := Article{
+			Title:        a.Title,
+			Abstract:     a.Abstract,
+			URL:          a.URL,
+			CanonicalURL: a.URL,
+		}
+		if publishedAt, err := time.Parse("2006-01-02", a.PublishedDate); err == nil {
+			article.PublishedAt = publishedAt.Format("January 02, 2006")
+		}
+		result = append(result, article)
+	}
+
+	return result, nil
+}
+
+// nytSearchResponse is the response of the NYT Article Search API
+type nytSearchResponse struct {
+	Response struct {
+		Docs []nytSearchDoc `json:"docs"`
+	} `json:"response"`
+}
+
+// nytSearchDoc is an article as returned by the Article Search and Archive APIs
+type nytSearchDoc struct {
+	WebURL         string `json:"web_url"`
+	Abstract       string `json:"abstract"`
+	Snippet        string `json:"snippet"`
+	PubDate        string `json:"pub_date"`
+	TypeOfMaterial string `json:"type_of_material"`
+	NewsDesk       string `json:"news_desk"`
+	Headline       struct {
+		Main string `json:"main"`
+	} `json:"headline"`
+}
+
+// nytPubDateLayout is the layout of the publication dates of the search and archive docs
+const nytPubDateLayout = "2006-01-02T15:04:05-0700"
+
+// article maps the doc to an Article, it returns false for docs without a headline or URL
+func (d nytSearchDoc) article() (Article, bool) {
+	if d.Headline.Main == "" || d.WebURL == "" {
+		return Article{}, false
+	}
+	article := Article{
+		Title:        d.Headline.Main,
+		Abstract:     d.Abstract,
+		URL:          d.WebURL,
+		CanonicalURL: d.WebURL,
+		MaterialType: d.TypeOfMaterial,
+		NewsDesk:     d.NewsDesk,
+	}
+	if article.Abstract == "" {
+		article.Abstract = d.Snippet
+	}
+	if publishedAt, err := time.Parse(nytPubDateLayout, d.PubDate); err == nil {
+		article.PublishedAt = publishedAt.Local().Format("January 02, 2006")
+	}
+	return article, true
+}
+
+// search queries the NYT Article Search API, returning at most topN articles from the first page of results
+func (nyt *NYTimes) search(ctx context.Context, query url.Values, topN int) ([]Article, error) {
+	var resp nytSearchResponse
+	if err := nyt.get(ctx, "search", "/search/v2/articlesearch.json", query, &resp); err != nil {
+		return nil, err
+	}
+
+	result := []Article{}
+	for _, d := range resp.Response.Docs {
+		if len(result) == topN {
+			break
+		}
+		if article, ok := d.article(); ok {
+			result = append(result, article)
+		}
+	}
+
+	return result, nil
+}
+
+// SearchByAuthor retrieves the most recent NY Times articles written by author.
+func (nyt *NYTimes) SearchByAuthor(ctx context.Context, author string, topN int) ([]Article, error) {
+	query := url.Values{}
+	query.Set("fq", fmt.Sprintf("byline:(%q)", author))
+	query.Set("sort", "newest")
+	return nyt.search(ctx, query, topN)
+}
+
+// SearchArticles retrieves the most recent NY Times articles matching a keyword query.
+func (nyt *NYTimes) SearchArticles(ctx context.Context, q string, limit int) ([]Article, error) {
+	query := url.Values{}
+	query.Set("q", q)
+	query.Set("sort", "newest")
+	return nyt.search(ctx, query, limit)
+}
+
+// archiveMonth identifies a month of the NYT archive
+type archiveMonth struct {
+	year  int
+	month time.Month
+}
+
+// nytArchiveTTL is how long the stories of a month of the archive are cached. Past months don't
+// change, the TTL only bounds the memory held by the months fetched.
+const nytArchiveTTL = time.Hour
+
+// Close stops the background eviction of the caches, see cacheCloser
+func (nyt *NYTimes) Close() {
+	nyt.archive.Close()
+	nyt.health.close()
+}
+
+// ArchiveStories retrieves the NY Times articles published on a day. The Archive API only
+// serves whole months, which are large downloads, so the month is fetched once and its stories
+// are cached by day.
+func (nyt *NYTimes) ArchiveStories(ctx context.Context, year int, month time.Month, day int) ([]Article, error) {
+	days, err := nyt.archive.GetOrCompute(ctx, archiveMonth{year: year, month: month}, func() (map[int][]Article, error) {
+		return nyt.archiveDays(ctx, year, month)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]Article{}, days[day]...), nil
+}
+
+// archiveDays fetches a month of the archive, returning its stories by day of the month
+func (nyt *NYTimes) archiveDays(ctx context.Context, year int, month time.Month) (map[int][]Article, error) {
+	var resp nytSearchResponse
+	if err := nyt.get(ctx, "archive", fmt.Sprintf("/archive/v1/%d/%d.json", year, month), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	days := map[int][]Article{}
+	for _, d := range resp.Response.Docs {
+		publishedAt, err := time.Parse(nytPubDateLayout, d.PubDate)
+		if err != nil || publishedAt.Year() != year || publishedAt.Month() != month {
+			continue
+		}
+		if article, ok := d.article(); ok {
+			days[publishedAt.Day()] = append(days[publishedAt.Day()], article)
+		}
+	}
+	return days, nil
+}
+
+// nytSpanishSections lists the sections with Spanish content. NYT publishes it in its own
+// 'En español' section, which isn't split any further, so it only stands for the home section.
+// The other sections fall back to their English stories.
+var nytSpanishSections = map[string]bool{
+	"home": true,
+}
+
+// LocalizedStories retrieves stories in the given language from The NY Times.
+func (nyt *NYTimes) LocalizedStories(ctx context.Context, section string, lang string, topN int) ([]Article, error) {
+	section = normalizeSection(section)
+	if _, ok := nyt.sectionKeys[section]; !ok {
+		return nil, ErrInvalidSection
+	}
+	if lang == "en" {
+		return nyt.TopStories(ctx, section, topN)
+	}
+	if lang != "es" || !nytSpanishSections[section] {
+		return nil, ErrLanguageUnavailable
+	}
+
+	query := url.Values{}
+	query.Set("fq", `section_name:("En español")`)
+	query.Set("sort", "newest")
+	return nyt.search(ctx, query, topN)
+}
+
+// defaultNYTSections are the sections supported out of the box
+var defaultNYTSections = []string{
+	"home",
+	"arts",
+	"automobile",
+	"books",
+	"business",
+	"fashion",
+	"food",
+	"health",
+	"movies",
+	"politics",
+	"realestate",
+	"science",
+	"sports",
+	"technology",
+	"theater",
+	"travel",
+	"us",
+	"world",
+}
+
 // SupportedSections returns the names of the supported sections
 func (nyt *NYTimes) SupportedSections() []string {
-	return []string{
-		"home",
-		"arts",
-		"automobile",
-		"books",
-		"business",
-		"fashion",
-		"food",
-		"health",
-		"movies",
-		"politics",
-		"realestate",
-		"science",
-		"sports",
-		"technology",
-		"theater",
-		"travel",
-		"us",
-		"world",
-	}
+	return nyt.health.visible(nyt.sections)
+}
+
+// BrandColor returns the color used to highlight NY Times stories.
+func (nyt *NYTimes) BrandColor() string {
+	return "#326891"
+}
+
+// MaxStories returns the number of stories NYT guarantees in a top stories list. Most sections
+// return more, but the smaller ones may have only a couple dozen.
+func (nyt *NYTimes) MaxStories() int {
+	return 20
 }
 
 // UserFriendlySection receives a section name and returns the user readable name for it.
 func (nyt *NYTimes) UserFriendlySection(section string) string {
-	return nyttop.Sections[nyttop.Section(section)]
+	section = normalizeSection(section)
+	if name, ok := nyttop.Sections[nyttop.Section(section)]; ok {
+		return name
+	}
+	// sections added through the overrides have no friendly name
+	return titleCase(strings.ReplaceAll(section, "-", " "))
+}
+
+// titleCase capitalizes the first letter of every word of a section name
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(r)) + word[size:]
+	}
+	return strings.Join(words, " ")
 }
@@ -0,0 +1,720 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rankedArticles returns stories identified by their canonical URL
+func rankedArticles(urls ...string) []Article {
+	var articles []Article
+	for _, u := range urls {
+		articles = append(articles, Article{Title: u, CanonicalURL: u})
+	}
+	return articles
+}
+
+func canonicalURLs(articles []Article) []string {
+	var urls []string
+	for _, a := range articles {
+		urls = append(urls, a.CanonicalURL)
+	}
+	return urls
+}
+
+func TestRankByPopularity(t *testing.T) {
+	tests := []struct {
+		name     string
+		articles []string
+		popular  []string
+		want     []string
+	}{
+		{"popular first, by rank", []string{"a", "b", "c", "d"}, []string{"c", "a"}, []string{"c", "a", "b", "d"}},
+		{"others keep their order", []string{"a", "b", "c", "d", "e"}, []string{"d"}, []string{"d", "a", "b", "c", "e"}},
+		{"no popular stories", []string{"a", "b", "c"}, nil, []string{"a", "b", "c"}},
+		{"popular stories of other sections", []string{"a", "b"}, []string{"x", "y"}, []string{"a", "b"}},
+		{"duplicate popular stories keep their best rank", []string{"a", "b", "c"}, []string{"b", "c", "b"}, []string{"b", "c", "a"}},
+		{"no articles", nil, []string{"a"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			articles := rankedArticles(tt.articles...)
+			got := canonicalURLs(rankByPopularity(articles, rankedArticles(tt.popular...)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(canonicalURLs(articles), tt.articles) {
+				t.Errorf("the articles were reordered in place: %v", canonicalURLs(articles))
+			}
+		})
+	}
+}
+
+func TestNYTimesSectionOverrides(t *testing.T) {
+	var paths []string
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Story")))
+	}, WithSectionOverrides(map[string]string{"world": "world-news", "climate": "climate", "us": "us"}))
+
+	for _, section := range []string{"world", "climate", "science", "us"} {
+		if _, err := nyt.TopStories(context.Background(), section, 1); err != nil {
+			t.Fatalf("%s: %v", section, err)
+		}
+	}
+	want := []string{"/topstories/v2/world-news.json", "/topstories/v2/climate.json", "/topstories/v2/science.json", "/topstories/v2/us.json"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+
+	sections := nyt.SupportedSections()
+	if got := sections[len(sections)-1]; got != "climate" {
+		t.Errorf("got last section %q, want the added climate section", got)
+	}
+	if got := len(sections); got != len(defaultNYTSections)+1 {
+		t.Errorf("got %d sections, want the %d default ones and climate", got, len(defaultNYTSections))
+	}
+	if got := nyt.UserFriendlySection("climate"); got != "Climate" {
+		t.Errorf("got friendly name %q, want Climate", got)
+	}
+}
+
+func TestNYTimesInvalidSectionOverrides(t *testing.T) {
+	for _, overrides := range []map[string]string{
+		{"world": ""},
+		{"world": "../admin"},
+		{"world": "World News"},
+		{"": "world"},
+		{"Climate": "climate"},
+		{"clim ate": "climate"},
+	} {
+		if _, err := NewNYTimes("test-key", WithSectionOverrides(overrides)); err == nil {
+			t.Errorf("got no error for overrides %v", overrides)
+		}
+	}
+}
+
+func TestTitleCase(t *testing.T) {
+	tests := map[string]string{
+		"climate":        "Climate",
+		"real estate":    "Real Estate",
+		" t  magazine ":  "T Magazine",
+		"élan vital":     "Élan Vital",
+		"already Titled": "Already Titled",
+		"":               "",
+	}
+	for section, want := range tests {
+		if got := titleCase(section); got != want {
+			t.Errorf("titleCase(%q) = %q, want %q", section, got, want)
+		}
+	}
+}
+
+func TestNYTimesLocalizedStories(t *testing.T) {
+	var requests []string
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?"+r.URL.Query().Get("fq"))
+		if strings.HasPrefix(r.URL.Path, "/search/") {
+			writeJSONResponse(t, w, searchResponse(nytDoc("Noticia")))
+			return
+		}
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Story")))
+	})
+
+	tests := []struct {
+		section, lang string
+		title         string
+		err           error
+		request       string
+	}{
+		{"home", "es", "Noticia", nil, `/search/v2/articlesearch.json?section_name:("En español")`},
+		{"home", "en", "Story", nil, "/topstories/v2/home.json?"},
+		{"world", "es", "", ErrLanguageUnavailable, ""},
+		{"home", "fr", "", ErrLanguageUnavailable, ""},
+		{"unknown", "es", "", ErrInvalidSection, ""},
+	}
+	for _, tt := range tests {
+		requests = nil
+		articles, err := nyt.LocalizedStories(context.Background(), tt.section, tt.lang, 5)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s in %s: got error %v, want %v", tt.section, tt.lang, err, tt.err)
+			continue
+		}
+		if tt.err != nil {
+			if len(requests) > 0 {
+				t.Errorf("%s in %s: requested %v, want no request", tt.section, tt.lang, requests)
+			}
+			continue
+		}
+		if len(articles) != 1 || articles[0].Title != tt.title {
+			t.Errorf("%s in %s: got %+v, want %q", tt.section, tt.lang, articles, tt.title)
+		}
+		if len(requests) != 1 || requests[0] != tt.request {
+			t.Errorf("%s in %s: requested %v, want %s", tt.section, tt.lang, requests, tt.request)
+		}
+	}
+}
+
+func TestNYTimesBreakingFlag(t *testing.T) {
+	story := func(title string, field string, value string) map[string]interface{} {
+		s := nytStory(title)
+		if field != "" {
+			s[field] = value
+		}
+		return s
+	}
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, topStoriesResponse(
+			story("Live blog", "item_type", "LiveBlog"),
+			story("Breaking kicker", "kicker", "Breaking News"),
+			story("Live kicker", "kicker", "Live Updates"),
+			story("Live URL", "url", "https://www.nytimes.com/live/2024/03/14/world/election"),
+			story("Article", "item_type", "Article"),
+			story("Plain", "", ""),
+		))
+	})
+	articles, err := nyt.TopStories(context.Background(), "home", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"Live blog": true, "Breaking kicker": true, "Live kicker": true, "Live URL": true, "Article": false, "Plain": false}
+	if len(articles) != len(want) {
+		t.Fatalf("got %d stories, want %d", len(articles), len(want))
+	}
+	for _, a := range articles {
+		if a.Breaking != want[a.Title] {
+			t.Errorf("%s: got breaking %v, want %v", a.Title, a.Breaking, want[a.Title])
+		}
+	}
+}
+
+func TestNYTimesArticleURL(t *testing.T) {
+	const short, full = "https://nyti.ms/abc", "https://www.nytimes.com/2024/03/14/world/story.html"
+	tests := []struct {
+		name          string
+		shortURL, url string
+		preferFull    bool
+		want          string
+	}{
+		{"both, short preferred", short, full, false, short},
+		{"both, full preferred", short, full, true, full},
+		{"short only, full preferred", short, "", true, short},
+		{"full only, short preferred", "", full, false, full},
+		{"neither", "", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nyt, err := NewNYTimes("test-key", WithFullURLs(tt.preferFull))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var a nytArticle
+			a.ShortURL, a.URL = tt.shortURL, tt.url
+			if got := nyt.articleURL(a); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNYTimesTopStoriesURLs(t *testing.T) {
+	noShortURL := nytStory("No short URL")
+	noShortURL["short_url"] = ""
+	noURL := nytStory("No URL")
+	noURL["url"], noURL["short_url"] = "", ""
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Both"), noShortURL, noURL))
+	}
+
+	for _, preferFull := range []bool{false, true} {
+		nyt := newTestNYTimes(t, handler, WithFullURLs(preferFull))
+		articles, err := nyt.TopStories(context.Background(), "home", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(articles) != 2 {
+			t.Fatalf("got %d stories, want the story without links skipped", len(articles))
+		}
+		both, fallback := articles[0], articles[1]
+		if wantShort := !preferFull; strings.HasPrefix(both.URL, "https://nyti.ms/") != wantShort {
+			t.Errorf("preferFull=%v: got URL %q", preferFull, both.URL)
+		}
+		if both.CanonicalURL != "https://www.nytimes.com/2024/03/14/Both.html" {
+			t.Errorf("preferFull=%v: got canonical URL %q, want the full URL", preferFull, both.CanonicalURL)
+		}
+		if fallback.URL != "https://www.nytimes.com/2024/03/14/No%20short%20URL.html" {
+			t.Errorf("preferFull=%v: got URL %q, want the full URL fallback", preferFull, fallback.URL)
+		}
+	}
+}
+
+func TestNYTimesUpdatedTime(t *testing.T) {
+	updated := nytStory("Updated")
+	updated["updated_date"] = "2024-03-14T11:30:00-04:00"
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Published"), updated))
+	})
+	articles, err := nyt.TopStories(context.Background(), "home", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := time.Date(2024, time.March, 14, 12, 0, 0, 0, time.UTC)
+	if !articles[0].PublishedTime.Equal(published) || !articles[0].UpdatedTime.Equal(published) {
+		t.Errorf("got published %s and updated %s, want both %s", articles[0].PublishedTime, articles[0].UpdatedTime, published)
+	}
+	if want := published.Add(3*time.Hour + 30*time.Minute); !articles[1].UpdatedTime.Equal(want) {
+		t.Errorf("got updated %s, want %s", articles[1].UpdatedTime, want)
+	}
+}
+
+func TestNYTimesProductNotEnabled(t *testing.T) {
+	endpoints := map[string]func(nyt *NYTimes) error{
+		"top stories": func(nyt *NYTimes) error {
+			_, err := nyt.TopStories(context.Background(), "home", 5)
+			return err
+		},
+		"most popular": func(nyt *NYTimes) error {
+			_, err := nyt.PopularStories(context.Background(), "viewed", 7)
+			return err
+		},
+		"article search": func(nyt *NYTimes) error {
+			_, err := nyt.SearchArticles(context.Background(), "climate", 5)
+			return err
+		},
+		"author search": func(nyt *NYTimes) error {
+			_, err := nyt.SearchByAuthor(context.Background(), "Jane Doe", 5)
+			return err
+		},
+		"archive": func(nyt *NYTimes) error {
+			_, err := nyt.ArchiveStories(context.Background(), 1974, time.March, 14)
+			return err
+		},
+		"spanish": func(nyt *NYTimes) error {
+			_, err := nyt.LocalizedStories(context.Background(), "home", "es", 5)
+			return err
+		},
+	}
+	responses := []struct {
+		name       string
+		status     int
+		fault      string
+		notEnabled bool
+	}{
+		{"forbidden", http.StatusForbidden, "", true},
+		{"key not allowed for the resource", http.StatusUnauthorized, "Invalid ApiKey for given resource", true},
+		{"invalid key", http.StatusUnauthorized, "Invalid ApiKey", false},
+	}
+	for name, call := range endpoints {
+		for _, resp := range responses {
+			t.Run(name+"/"+resp.name, func(t *testing.T) {
+				nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(resp.status)
+					fmt.Fprintf(w, `{"fault":{"faultstring":%q}}`, resp.fault)
+				})
+				err := call(nyt)
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				if got := errors.Is(err, ErrProductNotEnabled); got != resp.notEnabled {
+					t.Errorf("got error %v, want ErrProductNotEnabled: %v", err, resp.notEnabled)
+				}
+			})
+		}
+	}
+}
+
+func TestProductNotEnabledMessage(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": nil}, err: fmt.Errorf("%w: /mostpopular", ErrProductNotEnabled)}
+	b, fake := newTestBot(t, news, nil)
+	for _, text := range []string{"stories world", "popular viewed 7"} {
+		before := len(fake.received("response"))
+		responses := runCommand(t, b, fake, testCommandRequest(fake), text)
+		if len(responses) != before+1 || responses[before].message["text"] != productNotEnabledMessage {
+			t.Errorf("%s: got responses %+v, want %q", text, responses[before:], productNotEnabledMessage)
+		}
+	}
+}
+
+func TestUSSectionSpellings(t *testing.T) {
+	var paths []string
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		story := nytStory("Story")
+		story["section"] = "U.S."
+		writeJSONResponse(t, w, topStoriesResponse(story))
+	})
+
+	for _, section := range []string{"us", "u.s.", "U.S.", " U.S ", "USA"} {
+		t.Run(section, func(t *testing.T) {
+			paths = nil
+			if got := normalizeSection(section); got != "us" {
+				t.Errorf("normalizeSection(%q) = %q, want us", section, got)
+			}
+			if got, ok := resolveSection(section, defaultNYTSections); !ok || got != "us" {
+				t.Errorf("resolveSection(%q) = %q, %v, want us", section, got, ok)
+			}
+			if got := nyt.UserFriendlySection(section); got != "USA" {
+				t.Errorf("UserFriendlySection(%q) = %q, want USA", section, got)
+			}
+
+			articles, err := nyt.TopStories(context.Background(), section, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != 1 || paths[0] != "/topstories/v2/us.json" {
+				t.Errorf("requested %v, want the us section", paths)
+			}
+			// the 'U.S.' label of the responses matches the section requested
+			if len(articles) != 1 || articles[0].Section != "us" {
+				t.Errorf("got stories %+v, want them in the us section", articles)
+			}
+		})
+	}
+}
+
+func TestNYTimesMaterialTypes(t *testing.T) {
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/search/") {
+			doc := nytDoc("Doc")
+			doc["type_of_material"] = "News Analysis"
+			doc["news_desk"] = "Foreign"
+			writeJSONResponse(t, w, searchResponse(doc))
+			return
+		}
+		story := nytStory("Story")
+		story["material_type_facet"] = "Review"
+		writeJSONResponse(t, w, topStoriesResponse(story))
+	})
+
+	articles, err := nyt.TopStories(context.Background(), "arts", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 1 || articles[0].MaterialType != "Review" {
+		t.Errorf("got top stories %+v, want the Review material type", articles)
+	}
+	articles, err = nyt.SearchArticles(context.Background(), "query", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 1 || articles[0].MaterialType != "News Analysis" || articles[0].NewsDesk != "Foreign" {
+		t.Errorf("got search results %+v, want the News Analysis material type of the Foreign desk", articles)
+	}
+}
+
+func TestNYTimesHTMLResponse(t *testing.T) {
+	requests := 0
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body><h1>We'll be right back</h1></body></html>")
+	}, WithAttempts(3))
+
+	_, err := nyt.TopStories(context.Background(), "world", 5)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("got error %v, want ErrUpstreamUnavailable", err)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want the HTML page not to be retried", requests)
+	}
+	if _, err := nyt.SearchArticles(context.Background(), "query", 5); !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("got search error %v, want ErrUpstreamUnavailable", err)
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	tests := map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"Application/JSON":                true,
+		"application/problem+json":        true,
+		"text/html":                       false,
+		"text/html; charset=utf-8":        false,
+		"text/plain":                      false,
+		"":                                false,
+		"application/json;;":              false,
+	}
+	for contentType, want := range tests {
+		if got := isJSONContentType(contentType); got != want {
+			t.Errorf("isJSONContentType(%q) = %t, want %t", contentType, got, want)
+		}
+	}
+}
+
+func TestNYTimesReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Story")))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+	nyt, err := NewNYTimes("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nyt.baseURL = server.URL
+
+	// sequential requests share a single connection
+	for i := 0; i < 5; i++ {
+		if _, err := nyt.TopStories(context.Background(), "world", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	if conns != 1 {
+		t.Errorf("opened %d connections for sequential requests, want 1", conns)
+	}
+	conns = 0
+	mu.Unlock()
+
+	// the connections of concurrent requests stay open for the next ones, up to the pool size
+	const concurrent = 8
+	for round := 0; round < 3; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < concurrent; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := nyt.TopStories(context.Background(), "world", 1); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if conns > concurrent {
+		t.Errorf("opened %d connections for %d concurrent requests at a time, want the idle ones reused", conns, concurrent)
+	}
+}
+
+func TestNYTimesClientOptions(t *testing.T) {
+	nyt, err := NewNYTimes("test-key", WithTimeout(3*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, ok := nyt.httpClient.Transport.(*http.Transport)
+	if !ok || transport.MaxIdleConnsPerHost != nytMaxIdleConns {
+		t.Errorf("got transport %T, want one keeping %d idle connections", nyt.httpClient.Transport, nytMaxIdleConns)
+	}
+
+	// the client given is copied, not changed
+	client := &http.Client{Timeout: time.Minute}
+	nyt, err = NewNYTimes("test-key", WithHTTPClient(client), WithTimeout(3*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Timeout != time.Minute || nyt.httpClient == client || nyt.httpClient.Timeout != 3*time.Second {
+		t.Errorf("got client timeout %s and NYT timeout %s, want the client left as is", client.Timeout, nyt.httpClient.Timeout)
+	}
+}
+
+func TestNYTimesMultimedia(t *testing.T) {
+	image := func(format, url string) map[string]interface{} {
+		return map[string]interface{}{"url": url, "format": format, "type": "image", "height": 400, "width": 600}
+	}
+	tests := []struct {
+		name       string
+		multimedia interface{}
+		want       string
+	}{
+		{"null", nil, ""},
+		{"empty string", "", ""},
+		{"empty array", []interface{}{}, ""},
+		{"object", map[string]interface{}{"url": "https://static01.nyt.com/a.jpg"}, ""},
+		{"preferred format", []interface{}{
+			image("Standard Thumbnail", "https://static01.nyt.com/thumb.jpg"),
+			image("threeByTwoSmallAt2X", "https://static01.nyt.com/large.jpg"),
+		}, "https://static01.nyt.com/large.jpg"},
+		{"partial", []interface{}{
+			"not an object",
+			map[string]interface{}{"url": 42, "format": "threeByTwoSmallAt2X"},
+			image("threeByTwoSmallAt2X", "images/2024/relative.jpg"),
+			map[string]interface{}{"url": "https://static01.nyt.com/video.mp4", "format": "Large Thumbnail", "type": "video"},
+			image("Standard Thumbnail", "https://static01.nyt.com/thumb.jpg"),
+		}, "https://static01.nyt.com/thumb.jpg"},
+		{"other format", []interface{}{image("superJumbo", "https://static01.nyt.com/jumbo.jpg")}, "https://static01.nyt.com/jumbo.jpg"},
+		{"no valid rendition", []interface{}{image("threeByTwoSmallAt2X", ""), image("Large Thumbnail", "ftp://static01.nyt.com/a.jpg")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			story := nytStory("Story")
+			story["multimedia"] = tt.multimedia
+			nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSONResponse(t, w, topStoriesResponse(story))
+			})
+			articles, err := nyt.TopStories(context.Background(), "world", 1)
+			if err != nil {
+				t.Fatalf("got error %v, want the story without its broken media", err)
+			}
+			if len(articles) != 1 {
+				t.Fatalf("got %d stories, want 1", len(articles))
+			}
+			if articles[0].ImageURL != tt.want {
+				t.Errorf("got image %q, want %q", articles[0].ImageURL, tt.want)
+			}
+		})
+	}
+}
+
+func TestNYTimesSearchArticles(t *testing.T) {
+	var query url.Values
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search/v2/articlesearch.json" {
+			t.Errorf("got path %s, want the article search", r.URL.Path)
+		}
+		query = r.URL.Query()
+		untitled := nytDoc("")
+		writeJSONResponse(t, w, searchResponse(nytDoc("First"), untitled, nytDoc("Second"), nytDoc("Third"), nytDoc("Fourth")))
+	})
+
+	articles, err := nyt.SearchArticles(context.Background(), "climate policy", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("q") != "climate policy" || query.Get("sort") != "newest" || query.Get("api-key") != "test-key" {
+		t.Errorf("got query %v, want the newest articles about climate policy", query)
+	}
+	if query.Get("fq") != "" {
+		t.Errorf("got filter %q, want a keyword search", query.Get("fq"))
+	}
+	var titles []string
+	for _, a := range articles {
+		titles = append(titles, a.Title)
+	}
+	if got := strings.Join(titles, ", "); got != "First, Second, Third" {
+		t.Errorf("got stories %q, want the first 3 with a headline", got)
+	}
+	if articles[0].URL != "https://www.nytimes.com/2024/03/14/First.html" || articles[0].Abstract != "The abstract of First" {
+		t.Errorf("got story %+v", articles[0])
+	}
+}
+
+func TestResolveSection(t *testing.T) {
+	nyt, err := NewNYTimes("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	supported := nyt.SupportedSections()
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"technology", "technology", true},
+		{"tech", "technology", true},
+		{"Tech", "technology", true},
+		{"sport", "sports", true},
+		{"SPORT", "sports", true},
+		{"sprots", "sports", true},
+		{"tecnology", "technology", true},
+		{"scince", "science", true},
+		{"U.S.", "us", true},
+		{"real estate", "realestate", true},
+		{"atlantis", "", false},
+		{"xyz", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := resolveSection(tt.input, supported)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("resolveSection(%q) = %q, %t, want %q, %t", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// a typo as close to two sections is ambiguous
+	if got, ok := resolveSection("books", []string{"book", "boots"}); ok {
+		t.Errorf("got %q for an ambiguous typo, want no match", got)
+	}
+	// short names only get a single typo
+	if got, ok := resolveSection("abc", []string{"arts"}); ok {
+		t.Errorf("got %q two edits away from a short name", got)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"sports", "sports", 0},
+		{"", "arts", 4},
+		{"sprots", "sports", 2},
+		{"scince", "science", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNYTimesMissingPublishedDate(t *testing.T) {
+	story := nytStory("Story")
+	delete(story, "published_date")
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, topStoriesResponse(story, nytStory("Dated")))
+	})
+	articles, err := nyt.TopStories(context.Background(), "world", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("got %d stories, want 2", len(articles))
+	}
+	if articles[0].PublishedAt != "" || !articles[0].PublishedTime.IsZero() {
+		t.Errorf("got date %q (%s), want none for a story without a publication date", articles[0].PublishedAt, articles[0].PublishedTime)
+	}
+	if want := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC).Local().Format("January 02, 2006"); articles[1].PublishedAt != want {
+		t.Errorf("got date %q, want %q", articles[1].PublishedAt, want)
+	}
+	if got := formatPublishedAt(time.Time{}); got != "" {
+		t.Errorf("got %q for a zero time, want an empty date", got)
+	}
+}
+
+func TestNYTimesEmptyDates(t *testing.T) {
+	story := nytStory("Story")
+	story["published_date"] = ""
+	story["updated_date"] = ""
+	story["created_date"] = "not a date"
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(t, w, topStoriesResponse(story, nytStory("Dated")))
+	})
+	articles, err := nyt.TopStories(context.Background(), "world", 2)
+	if err != nil {
+		t.Fatalf("got %v, want the stories despite their empty dates", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("got %d stories, want 2", len(articles))
+	}
+	if articles[0].PublishedAt != "" || !articles[0].PublishedTime.IsZero() || !articles[0].UpdatedTime.IsZero() {
+		t.Errorf("got dates %q, %s and %s, want none", articles[0].PublishedAt, articles[0].PublishedTime, articles[0].UpdatedTime)
+	}
+	if want := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC); !articles[1].PublishedTime.Equal(want) || articles[1].Title != "Dated" {
+		t.Errorf("got %q published at %s, want the dated story at %s", articles[1].Title, articles[1].PublishedTime, want)
+	}
+}
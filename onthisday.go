@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// onThisDayMaxYears is how far back in time we look for a story
+	onThisDayMaxYears = 100
+	// onThisDayAttempts is how many years we try before giving up, since some days have no
+	// archived stories
+	onThisDayAttempts = 3
+)
+
+// onThisDayYear picks a random past year in which the calendar day of now exists, so a Feb 29 is
+// only looked for in the leap years
+func (b *Bot) onThisDayYear(now time.Time) int {
+	var years []int
+	for year := now.Year() - onThisDayMaxYears; year < now.Year(); year++ {
+		if time.Date(year, now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Month() == now.Month() {
+			years = append(years, year)
+		}
+	}
+	return years[b.randomInt(len(years))]
+}
+
+// handleOnThisDayRequest posts a story published on the same calendar day as now, in a random past year
+func (b *Bot) handleOnThisDayRequest(ctx context.Context, req commandRequest, now time.Time) {
+	for attempt := 0; attempt < onThisDayAttempts; attempt++ {
+		year := b.onThisDayYear(now)
+		articles, err := b.newsSource.ArchiveStories(ctx, year, now.Month(), now.Day())
+		b.metrics.recordRequest("archive", err)
+		if err != nil {
+			b.logger.Error("error requesting the archive", "correlation_id", req.id, "channel_id", req.channelID, "year", year, "month", int(now.Month()), "error", err)
+			b.postNotice(ctx, req, newsErrorStatus(err), newsErrorMessage(err))
+			return
+		}
+		if len(articles) == 0 {
+			continue
+		}
+
+		opts := b.renderDefaults
+		opts.Header = fmt.Sprintf("🕰️ On this day in %d", year)
+		article := articles[b.randomInt(len(articles))]
+		b.postResponse(ctx, req, b.render(ctx, []Article{article}, opts))
+		return
+	}
+
+	b.postNotice(ctx, req, statusEmpty, "🕰️ We couldn't find a story from this day in the archive, try again later!")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnThisDayYear(t *testing.T) {
+	b := &Bot{rng: rand.New(rand.NewSource(1))}
+	leapDay := time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 200; i++ {
+		year := b.onThisDayYear(leapDay)
+		if year < 2024-onThisDayMaxYears || year >= 2024 {
+			t.Fatalf("got year %d, want one of the last %d years", year, onThisDayMaxYears)
+		}
+		if time.Date(year, time.February, 29, 0, 0, 0, 0, time.UTC).Month() != time.February {
+			t.Fatalf("got year %d without a Feb 29", year)
+		}
+	}
+
+	years := map[int]bool{}
+	for i := 0; i < 200; i++ {
+		years[b.onThisDayYear(testNow)] = true
+	}
+	if len(years) < 50 {
+		t.Errorf("got %d different years in 200 draws, want them to vary", len(years))
+	}
+}
+
+func TestNYTimesArchiveStories(t *testing.T) {
+	doc := func(headline string, pubDate string) map[string]interface{} {
+		d := nytDoc(headline)
+		d["pub_date"] = pubDate
+		return d
+	}
+	var paths []string
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeJSONResponse(t, w, searchResponse(
+			doc("Ides of March", "1974-03-15T04:00:00+0000"),
+			doc("Pi day", "1974-03-14T09:00:00+0000"),
+			doc("Another pi day", "1974-03-14T20:00:00+0000"),
+			doc("Other month", "1974-04-14T09:00:00+0000"),
+		))
+	})
+
+	articles, err := nyt.ArchiveStories(context.Background(), 1974, time.March, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 2 || articles[0].Title != "Pi day" || articles[1].Title != "Another pi day" {
+		t.Errorf("got %+v, want the stories of March 14", articles)
+	}
+	// the month is cached and the stories of the other days come from it
+	articles[0].Title = "changed"
+	if articles, _ := nyt.ArchiveStories(context.Background(), 1974, time.March, 14); articles[0].Title != "Pi day" {
+		t.Errorf("changing the stories changed the cache")
+	}
+	if articles, _ := nyt.ArchiveStories(context.Background(), 1974, time.March, 15); len(articles) != 1 || articles[0].Title != "Ides of March" {
+		t.Errorf("got %+v, want the stories of March 15", articles)
+	}
+	if articles, _ := nyt.ArchiveStories(context.Background(), 1974, time.March, 1); len(articles) != 0 {
+		t.Errorf("got %+v, want no stories on March 1", articles)
+	}
+	if len(paths) != 1 || paths[0] != "/archive/v1/1974/3.json" {
+		t.Errorf("requested %v, want the month once", paths)
+	}
+}
+
+func TestOnThisDay(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []Article
+		want    string
+	}{
+		{"a story", []Article{testArticle("Archived story")}, "Archived story"},
+		{"no stories", nil, "🕰️ We couldn't find a story from this day in the archive, try again later!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			news := &fakeNews{archive: tt.archive}
+			b, fake := newTestBot(t, news, nil)
+			responses := runCommand(t, b, fake, testCommandRequest(fake), "onthisday")
+			if len(responses) != 1 || !strings.Contains(responses[0].text(), tt.want) {
+				t.Fatalf("got responses %+v, want %q", responses, tt.want)
+			}
+			requests := news.requested()
+			for _, request := range requests {
+				if !strings.HasPrefix(request, "archive ") || !strings.HasSuffix(request, "-03-14") {
+					t.Errorf("got request %q, want the archive of March 14", request)
+				}
+			}
+			if want := map[bool]int{true: 1, false: onThisDayAttempts}[tt.archive != nil]; len(requests) != want {
+				t.Errorf("got %d requests, want %d", len(requests), want)
+			}
+		})
+	}
+}
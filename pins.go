@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// digestPins keeps the latest digest pinned in each subscribed channel. It is safe for concurrent use.
+type digestPins struct {
+	mu sync.Mutex
+	// pinned is the timestamp of the digest currently pinned by the bot in each channel
+	pinned map[string]string
+}
+
+// newDigestPins returns the pins of the digests, or nil when pinning is disabled
+func newDigestPins(cfg Config) *digestPins {
+	if !cfg.pinDigests {
+		return nil
+	}
+	return &digestPins{pinned: make(map[string]string)}
+}
+
+func (p *digestPins) previous(channelID string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pinned[channelID]
+}
+
+func (p *digestPins) set(channelID string, ts string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ts == "" {
+		delete(p.pinned, channelID)
+		return
+	}
+	p.pinned[channelID] = ts
+}
+
+// pinDigest pins the digest just posted to a channel and unpins the previous one. When the channel
+// reached its pin limit, the previous digest is unpinned first to make room. Pinning is best
+// effort, errors are only logged.
+func (b *Bot) pinDigest(teamID string, channelID string, ts string) {
+	if b.digestPins == nil {
+		return
+	}
+
+	client, err := b.slackClients.get(teamID)
+	if err != nil {
+		b.logger.Error("error pinning digest", "channel_id", channelID, "error", err)
+		return
+	}
+
+	previous := b.digestPins.previous(channelID)
+	err = client.AddPin(channelID, slack.NewRefToMessage(channelID, ts))
+	if isSlackError(err, "too_many_pins") && previous != "" {
+		b.logger.Info("channel reached its pin limit, unpinning the previous digest first", "channel_id", channelID)
+		b.unpinDigest(client, channelID, previous)
+		previous = ""
+		b.digestPins.set(channelID, "")
+		err = client.AddPin(channelID, slack.NewRefToMessage(channelID, ts))
+	}
+	if isSlackError(err, "too_many_pins") {
+		b.logger.Warn("channel reached its pin limit, the digest isn't pinned", "channel_id", channelID)
+		return
+	}
+	if err != nil && !isSlackError(err, "already_pinned") {
+		b.logger.Error("error pinning digest", "channel_id", channelID, "error", err)
+		return
+	}
+
+	if previous != "" && previous != ts {
+		b.unpinDigest(client, channelID, previous)
+	}
+	b.digestPins.set(channelID, ts)
+}
+
+// unpinDigest unpins a digest, ignoring the ones already unpinned by the users
+func (b *Bot) unpinDigest(client *slack.Client, channelID string, ts string) {
+	err := client.RemovePin(channelID, slack.NewRefToMessage(channelID, ts))
+	if err != nil && !isSlackError(err, "no_pin", "message_not_found") {
+		b.logger.Error("error unpinning previous digest", "channel_id", channelID, "error", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// pinCalls lists the pins.add and pins.remove calls received, e.g. 'add 1.0'
+func pinCalls(fake *fakeSlack) []string {
+	var calls []string
+	for _, call := range fake.received("pins.add", "pins.remove") {
+		if call.values.Get("channel") != "C0TESTCHANNEL" {
+			continue
+		}
+		action := "add"
+		if call.method == "pins.remove" {
+			action = "remove"
+		}
+		calls = append(calls, action+" "+call.values.Get("timestamp"))
+	}
+	return calls
+}
+
+func TestPinDigest(t *testing.T) {
+	tests := []struct {
+		name string
+		// pinned is the digest pinned before, if any
+		pinned string
+		errors map[string][]string
+		calls  []string
+		// want is the digest pinned after
+		want string
+	}{
+		{"first digest", "", nil, []string{"add 2.0"}, "2.0"},
+		{"new digest", "1.0", nil, []string{"add 2.0", "remove 1.0"}, "2.0"},
+		{"same digest", "2.0", map[string][]string{"pins.add": {"already_pinned"}}, []string{"add 2.0"}, "2.0"},
+		{"previous digest unpinned by a user", "1.0", map[string][]string{"pins.remove": {"no_pin"}}, []string{"add 2.0", "remove 1.0"}, "2.0"},
+		{
+			"pin limit",
+			"1.0",
+			map[string][]string{"pins.add": {"too_many_pins"}},
+			[]string{"add 2.0", "remove 1.0", "add 2.0"},
+			"2.0",
+		},
+		{"pin limit without a previous digest", "", map[string][]string{"pins.add": {"too_many_pins"}}, []string{"add 2.0"}, ""},
+		{
+			"pin limit after unpinning",
+			"1.0",
+			map[string][]string{"pins.add": {"too_many_pins", "too_many_pins"}},
+			[]string{"add 2.0", "remove 1.0", "add 2.0"},
+			"",
+		},
+		{"pin error", "1.0", map[string][]string{"pins.add": {"channel_not_found"}}, []string{"add 2.0"}, "1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, &fakeNews{}, func(cfg *Config) { cfg.pinDigests = true })
+			b.digestPins.set("C0TESTCHANNEL", tt.pinned)
+			for method, codes := range tt.errors {
+				fake.failNext(method, codes...)
+			}
+
+			b.pinDigest("T0TEST", "C0TESTCHANNEL", "2.0")
+			if calls := pinCalls(fake); !reflect.DeepEqual(calls, tt.calls) {
+				t.Errorf("got calls %v, want %v", calls, tt.calls)
+			}
+			if got := b.digestPins.previous("C0TESTCHANNEL"); got != tt.want {
+				t.Errorf("got the digest %q pinned, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinDigestSequence(t *testing.T) {
+	b, fake := newTestBot(t, &fakeNews{}, func(cfg *Config) { cfg.pinDigests = true })
+	for _, ts := range []string{"1.0", "2.0", "3.0"} {
+		b.pinDigest("T0TEST", "C0TESTCHANNEL", ts)
+	}
+	want := []string{"add 1.0", "add 2.0", "remove 1.0", "add 3.0", "remove 2.0"}
+	if calls := pinCalls(fake); !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+
+	// the pins of another channel are tracked on their own
+	b.pinDigest("T0TEST", "C0OTHER", "4.0")
+	if got := b.digestPins.previous("C0TESTCHANNEL"); got != "3.0" {
+		t.Errorf("got the digest %q pinned, want 3.0", got)
+	}
+}
+
+func TestPostDigestsPins(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+		b, fake := newTestBot(t, news, func(cfg *Config) {
+			cfg.subscriptions = []subscription{{ChannelID: "C0TESTCHANNEL", Section: "world"}}
+			cfg.pinDigests = enabled
+		})
+		b.postDigests(context.Background())
+
+		var want []string
+		if enabled {
+			// the fake slack gives every message the same timestamp
+			want = []string{"add 1710417600.000100"}
+		}
+		if calls := pinCalls(fake); !reflect.DeepEqual(calls, want) {
+			t.Errorf("pinning enabled %t: got calls %v, want %v", enabled, calls, want)
+		}
+	}
+}
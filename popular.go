@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// popularMetricNames are the names of the popularity metrics in the headers
+var popularMetricNames = map[string]string{
+	"viewed":  "Most viewed",
+	"emailed": "Most emailed",
+	"shared":  "Most shared",
+}
+
+// popularPeriodNames are the names of the popularity periods in the headers
+var popularPeriodNames = map[int]string{
+	1:  "today",
+	7:  "this week",
+	30: "this month",
+}
+
+// parsePopularParams parses the metric and the period of days of a popular command, e.g.
+// 'emailed 7'. They default to the most viewed stories of the day.
+func parsePopularParams(params string) (metric string, period int, err error) {
+	metric, period = "viewed", 1
+	fields := strings.Fields(params)
+	if len(fields) > 2 {
+		return "", 0, fmt.Errorf("%w %q", ErrInvalidMetric, params)
+	}
+	if len(fields) > 0 {
+		metric = fields[0]
+	}
+	if len(fields) > 1 {
+		period, err = strconv.Atoi(fields[1])
+		if err != nil {
+			return "", 0, fmt.Errorf("%w %q", ErrInvalidPeriod, fields[1])
+		}
+	}
+	if _, ok := popularMetricNames[metric]; !ok {
+		return "", 0, fmt.Errorf("%w %q", ErrInvalidMetric, metric)
+	}
+	if _, ok := popularPeriodNames[period]; !ok {
+		return "", 0, fmt.Errorf("%w %d", ErrInvalidPeriod, period)
+	}
+	return metric, period, nil
+}
+
+// handlePopularRequest posts the most popular stories for a metric over a period, e.g.
+// '/news popular shared 30'
+func (b *Bot) handlePopularRequest(ctx context.Context, req commandRequest, params string) {
+	metric, period, err := parsePopularParams(params)
+	if err != nil {
+		b.postNotice(ctx, req, newsErrorStatus(err), newsErrorMessage(err))
+		return
+	}
+
+	articles, err := b.newsSource.PopularStories(ctx, metric, period)
+	b.metrics.recordRequest("popular", err)
+	if err != nil {
+		b.logger.Error("error requesting popular stories", "correlation_id", req.id, "channel_id", req.channelID, "metric", metric, "period", period, "error", err)
+		b.postNotice(ctx, req, newsErrorStatus(err), newsErrorMessage(err))
+		return
+	}
+	if len(articles) == 0 {
+		b.postNotice(ctx, req, statusEmpty, "No popular stories right now, try again later!")
+		return
+	}
+	if topN, _ := b.storyCount(0); len(articles) > topN {
+		articles = articles[:topN]
+	}
+
+	opts := b.renderDefaults
+	opts.Header = fmt.Sprintf("🔥 %s %s", popularMetricNames[metric], popularPeriodNames[period])
+	b.postResponse(ctx, req, b.render(ctx, articles, opts))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// the outcomes of the top stories commands
+const (
+	outcomeSuccess        = "success"
+	outcomeInvalidSection = "invalid_section"
+	outcomeError          = "error"
+)
+
+// promMetrics exports the command and news source metrics to Prometheus. Its methods do nothing on a nil
+// promMetrics, so the instrumented code doesn't need to check it is enabled.
+type promMetrics struct {
+	registry *prometheus.Registry
+
+	commands        *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
+	storiesRequests *prometheus.CounterVec
+	sourceErrors    *prometheus.CounterVec
+	sourceDuration  *prometheus.HistogramVec
+}
+
+func newPromMetrics() *promMetrics {
+	m := &promMetrics{
+		registry: prometheus.NewRegistry(),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "taina_commands_total",
+			Help: "Commands received, by subcommand.",
+		}, []string{"command"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "taina_command_duration_seconds",
+			Help:    "Time to process a command end to end, by subcommand.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"command"}),
+		storiesRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "taina_stories_requests_total",
+			Help: "Top stories commands, by section and outcome.",
+		}, []string{"section", "outcome"}),
+		sourceErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "taina_source_errors_total",
+			Help: "Failed news source requests, by section.",
+		}, []string{"section"}),
+		sourceDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "taina_source_request_duration_seconds",
+			Help:    "Latency of the news source requests retries included, by section and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"section", "outcome"}),
+	}
+	m.registry.MustRegister(m.commands, m.commandDuration, m.storiesRequests, m.sourceErrors, m.sourceDuration)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus format
+func (m *promMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observeCommand counts a command and its processing time
+func (m *promMetrics) observeCommand(command string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.commands.WithLabelValues(command).Inc()
+	m.commandDuration.WithLabelValues(command).Observe(elapsed.Seconds())
+}
+
+// observeStories counts a top stories command. The section is only a label when it is supported,
+// so user input can't grow the number of series.
+func (m *promMetrics) observeStories(section string, outcome string) {
+	if m == nil {
+		return
+	}
+	if outcome == outcomeInvalidSection {
+		section = "unknown"
+	}
+	m.storiesRequests.WithLabelValues(section, outcome).Inc()
+}
+
+// observeSourceRequest is the requestObserver of the news source
+func (m *promMetrics) observeSourceRequest(section string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeError
+		m.sourceErrors.WithLabelValues(section).Inc()
+	}
+	m.sourceDuration.WithLabelValues(section, outcome).Observe(elapsed.Seconds())
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the metrics served by m, as Prometheus scrapes them
+func scrape(t *testing.T, m *promMetrics) string {
+	t.Helper()
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestPromMetricsCommands(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+	b, fake := newTestBot(t, news, nil)
+	for _, text := range []string{"stories world", "stories world", "stories Atlantis", "help", ""} {
+		runCommand(t, b, fake, testCommandRequest(fake), text)
+	}
+	news.mu.Lock()
+	news.err = errors.New("boom")
+	news.mu.Unlock()
+	runCommand(t, b, fake, testCommandRequest(fake), "stories world")
+
+	metrics := scrape(t, b.prom)
+	for _, line := range []string{
+		`taina_commands_total{command="stories"} 4`,
+		`taina_commands_total{command="help"} 2`,
+		`taina_command_duration_seconds_count{command="stories"} 4`,
+		`taina_stories_requests_total{outcome="success",section="world"} 2`,
+		`taina_stories_requests_total{outcome="error",section="world"} 1`,
+		`taina_stories_requests_total{outcome="invalid_section",section="unknown"} 1`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("the metrics don't have %s:\n%s", line, metrics)
+		}
+	}
+	// the sections typed by the users aren't labels
+	if strings.Contains(strings.ToLower(metrics), "atlantis") {
+		t.Errorf("got a series for an unsupported section:\n%s", metrics)
+	}
+}
+
+func TestPromMetricsSourceRequests(t *testing.T) {
+	m := newPromMetrics()
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "science") {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Story")))
+	}, WithRequestObserver(m.observeSourceRequest))
+	ctx := context.Background()
+	nyt.TopStories(ctx, "world", 1)
+	nyt.TopStories(ctx, "world", 1)
+	nyt.TopStories(ctx, "science", 1)
+
+	metrics := scrape(t, m)
+	for _, line := range []string{
+		`taina_source_request_duration_seconds_count{outcome="success",section="world"} 2`,
+		`taina_source_request_duration_seconds_count{outcome="error",section="science"} 1`,
+		`taina_source_errors_total{section="science"} 1`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("the metrics don't have %s:\n%s", line, metrics)
+		}
+	}
+	if strings.Contains(metrics, `taina_source_errors_total{section="world"}`) {
+		t.Errorf("got errors for the successful requests:\n%s", metrics)
+	}
+}
+
+func TestPromMetricsNil(t *testing.T) {
+	var m *promMetrics
+	m.observeCommand("stories", time.Second)
+	m.observeStories("world", outcomeSuccess)
+	m.observeSourceRequest("world", time.Second, nil)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// newsProvider builds a news source from the config. It fails when the credentials of the source
+// are missing, so a misconfigured bot doesn't start.
+type newsProvider func(cfg Config, observe requestObserver, logger *slog.Logger) (NewsSource, error)
+
+// newsProviders maps the names accepted by NEWS_PROVIDER to their news provider
+var newsProviders = map[string]newsProvider{}
+
+// registerNewsProvider makes a news provider available under name. Registering a name twice is a
+// programming error, so it panics.
+func registerNewsProvider(name string, provider newsProvider) {
+	if _, ok := newsProviders[name]; ok {
+		panic(fmt.Sprintf("news provider %q is already registered", name))
+	}
+	newsProviders[name] = provider
+}
+
+// newNewsSource builds the news source of the named provider
+func newNewsSource(name string, cfg Config, observe requestObserver, logger *slog.Logger) (NewsSource, error) {
+	provider, ok := newsProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown news provider %q, expected one of %s", name, strings.Join(newsProviderNames(), ", "))
+	}
+	return provider(cfg, observe, logger)
+}
+
+// newsProviderNames returns the names of the registered news providers, sorted
+func newsProviderNames() []string {
+	var names []string
+	for name := range newsProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterNewsProvider(t *testing.T) {
+	source := &fakeNews{}
+	var observed requestObserver
+	registerNewsProvider("test", func(cfg Config, observe requestObserver, logger *slog.Logger) (NewsSource, error) {
+		observed = observe
+		if cfg.nytAPIKey == "" {
+			return nil, errors.New("missing key")
+		}
+		return source, nil
+	})
+	t.Cleanup(func() { delete(newsProviders, "test") })
+
+	got, err := newNewsSource("test", Config{nytAPIKey: "key"}, func(string, time.Duration, error) {}, discardLogger())
+	if err != nil || got != source {
+		t.Fatalf("got %v, %v, want the source of the provider", got, err)
+	}
+	if observed == nil {
+		t.Error("the provider didn't get the request observer")
+	}
+	if _, err := newNewsSource("test", Config{}, nil, discardLogger()); err == nil || err.Error() != "missing key" {
+		t.Errorf("got error %v, want the error of the provider", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a provider twice didn't panic")
+		}
+	}()
+	registerNewsProvider("test", nil)
+}
+
+func TestNewNewsSourceUnknown(t *testing.T) {
+	_, err := newNewsSource("bbc", Config{}, nil, discardLogger())
+	if err == nil {
+		t.Fatal("got no error for an unknown provider")
+	}
+	for _, name := range []string{"bbc", "guardian", "nyt"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("the error %q doesn't mention %s", err, name)
+		}
+	}
+}
+
+func TestNewsProviders(t *testing.T) {
+	if got, want := newsProviderNames(), []string{"guardian", "nyt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got providers %v, want %v", got, want)
+	}
+	if _, err := newNewsSource("nyt", Config{}, nil, discardLogger()); err == nil || !strings.Contains(err.Error(), "NYT_API_KEY") {
+		t.Errorf("got error %v, want the missing NYT key", err)
+	}
+	if _, err := newNewsSource("guardian", Config{}, nil, discardLogger()); err == nil || !strings.Contains(err.Error(), "GUARDIAN_API_KEY") {
+		t.Errorf("got error %v, want the missing Guardian key", err)
+	}
+
+	nyt, err := newNewsSource("nyt", Config{nytAPIKey: "key", nytTimeout: time.Second, nytAttempts: 1}, nil, discardLogger())
+	if _, ok := nyt.(*NYTimes); err != nil || !ok {
+		t.Errorf("got %T, %v, want the NYT source", nyt, err)
+	}
+	guardian, err := newNewsSource("guardian", Config{guardianAPIKey: "key"}, nil, discardLogger())
+	if _, ok := guardian.(*Guardian); err != nil || !ok {
+		t.Errorf("got %T, %v, want the Guardian source", guardian, err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// quickRepliesBlockID identifies the actions block of the quick reply buttons in interactions
+const quickRepliesBlockID = "quick_replies"
+
+// parseRelatedSections parses the sections offered as quick replies after each section, formatted
+// as 'section=related,related' and separated by '|', e.g. 'world=us,politics,business'
+func parseRelatedSections(value string) (map[string][]string, error) {
+	related := map[string][]string{}
+	for _, entry := range strings.Split(value, "|") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid related sections %q, expected 'section=section,section'", entry)
+		}
+		section := normalizeSection(strings.ToLower(strings.TrimSpace(parts[0])))
+		sections, _ := parseSections(strings.ToLower(parts[1]))
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("section %q has no related sections", section)
+		}
+		related[section] = sections
+	}
+	return related, nil
+}
+
+// validateRelatedSections checks every section with related sections, and every related section,
+// is supported by the news source
+func validateRelatedSections(related map[string][]string, newsSource NewsSource) error {
+	for section, sections := range related {
+		if _, ok := unsupportedSection(newsSource, section); ok {
+			return fmt.Errorf("related sections of unsupported section %q", section)
+		}
+		if s, ok := unsupportedSection(newsSource, sections...); ok {
+			return fmt.Errorf("section %q has unsupported related section %q", section, s)
+		}
+	}
+	return nil
+}
+
+// quickReplies returns the buttons offering the sections related to a section, if any
+func (b *Bot) quickReplies(section string) []QuickReply {
+	var replies []QuickReply
+	for _, related := range b.relatedSections[section] {
+		replies = append(replies, QuickReply{
+			Label:   b.newsSource.UserFriendlySection(related),
+			Section: related,
+		})
+	}
+	return replies
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRelatedSections(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string][]string
+	}{
+		{"", map[string][]string{}},
+		{"world=us,politics,business", map[string][]string{"world": {"us", "politics", "business"}}},
+		{"world=us | science=health,climate", map[string][]string{"world": {"us"}, "science": {"health", "climate"}}},
+		{"World=U.S.,Tech|", map[string][]string{"world": {"us", "technology"}}},
+	}
+	for _, tt := range tests {
+		got, err := parseRelatedSections(tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRelatedSections(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"world", "=us", "world=", "world= , "} {
+		if _, err := parseRelatedSections(value); err == nil {
+			t.Errorf("parseRelatedSections(%q) didn't fail", value)
+		}
+	}
+}
+
+func TestValidateRelatedSections(t *testing.T) {
+	news := &fakeNews{sections: []string{"world", "us", "politics"}}
+	if err := validateRelatedSections(map[string][]string{"world": {"us", "politics"}}, news); err != nil {
+		t.Errorf("got error %v for supported sections", err)
+	}
+	for _, related := range []map[string][]string{{"bogus": {"us"}}, {"world": {"us", "bogus"}}} {
+		if err := validateRelatedSections(related, news); err == nil {
+			t.Errorf("validateRelatedSections(%v) didn't fail", related)
+		}
+	}
+}
+
+// quickReplyButtons returns the action ID, value and label of the quick reply buttons of the last
+// block, if it's the quick replies
+func quickReplyButtons(t *testing.T, call slackCall) [][3]string {
+	t.Helper()
+	blocks, _ := call.message["blocks"].([]interface{})
+	if len(blocks) == 0 {
+		return nil
+	}
+	last, _ := blocks[len(blocks)-1].(map[string]interface{})
+	if last["type"] != "actions" || last["block_id"] != quickRepliesBlockID {
+		return nil
+	}
+	var buttons [][3]string
+	for _, element := range last["elements"].([]interface{}) {
+		button := element.(map[string]interface{})
+		label := button["text"].(map[string]interface{})["text"].(string)
+		buttons = append(buttons, [3]string{button["action_id"].(string), button["value"].(string), label})
+	}
+	return buttons
+}
+
+func TestQuickReplyButtons(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3), "science": testArticles(3)}}
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.relatedSections = map[string][]string{"world": {"us", "politics"}}
+	})
+
+	responses := runCommand(t, b, fake, testCommandRequest(fake), "stories world")
+	want := [][3]string{{"quick_reply_us", "us", "Us"}, {"quick_reply_politics", "politics", "Politics"}}
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	if got := quickReplyButtons(t, responses[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("got buttons %v, want %v", got, want)
+	}
+
+	// sections without related sections get no buttons
+	responses = runCommand(t, b, fake, testCommandRequest(fake), "stories science")
+	if got := quickReplyButtons(t, responses[len(responses)-1]); got != nil {
+		t.Errorf("got buttons %v, want none", got)
+	}
+}
+
+func TestQuickReplyClick(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3), "politics": {testArticle("Politics story")}}}
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.relatedSections = map[string][]string{"politics": {"world"}}
+	})
+	postInteraction(t, b, quickReplyInteraction(fake.responseURL, "politics"))
+	waitTasks(t, b)
+
+	if requests := news.requested(); !reflect.DeepEqual(requests, []string{"top politics"}) {
+		t.Errorf("requested %v, want the politics stories", requests)
+	}
+	responses := fake.received("response")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	if !strings.Contains(responses[0].text(), "Politics story") {
+		t.Errorf("got %s, want the politics stories", responses[0].text())
+	}
+	// the stories with the buttons stay in the channel
+	if responses[0].message["replace_original"] == true {
+		t.Error("the quick reply replaced the stories it was clicked under")
+	}
+	if got := quickReplyButtons(t, responses[0]); len(got) != 1 || got[0][1] != "world" {
+		t.Errorf("got buttons %v, want the related sections of politics", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// dailyQuota limits the number of requests of each user per day. The days start at midnight in
+// the configured location. It is safe for concurrent use. A non-positive limit disables it.
+type dailyQuota struct {
+	limit    int
+	location *time.Location
+	now      func() time.Time
+
+	mu sync.Mutex
+	// counts holds the requests of each user on a day, the old days expire on their own
+	counts *ttlCache[quotaKey, int]
+}
+
+type quotaKey struct {
+	userID string
+	day    string
+}
+
+func newDailyQuota(limit int, location *time.Location) *dailyQuota {
+	q := &dailyQuota{limit: limit, location: location, now: time.Now}
+	if limit > 0 {
+		q.counts = newTTLCache[quotaKey, int](24 * time.Hour)
+		q.counts.now = q.nowFunc
+	}
+	return q
+}
+
+// nowFunc lets the cache follow the clock of the quota, even when it is replaced
+func (q *dailyQuota) nowFunc() time.Time {
+	return q.now()
+}
+
+// try records a request of the user, unless the user already reached the limit of the day
+func (q *dailyQuota) try(userID string) bool {
+	if q.limit <= 0 {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := quotaKey{userID: userID, day: q.now().In(q.location).Format("2006-01-02")}
+	count, _ := q.counts.Get(key)
+	if count >= q.limit {
+		return false
+	}
+	q.counts.Set(key, count+1)
+	return true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyQuota(t *testing.T) {
+	// the days start at midnight in New York, 5 hours after midnight UTC in winter
+	newYork := time.FixedZone("EST", -5*60*60)
+	clock := &fakeClock{now: time.Date(2024, 3, 14, 20, 0, 0, 0, newYork)}
+	q := newDailyQuota(2, newYork)
+	defer q.counts.Close()
+	q.now = clock.Now
+
+	for i := 0; i < 2; i++ {
+		if !q.try("U0TEST") {
+			t.Fatalf("request %d was refused under the limit", i+1)
+		}
+	}
+	if q.try("U0TEST") {
+		t.Error("the request over the limit was accepted")
+	}
+	if !q.try("U0OTHER") {
+		t.Error("the limit of a user was applied to another one")
+	}
+
+	// past midnight UTC, still the same day in New York
+	clock.advance(3*time.Hour + 59*time.Minute)
+	if q.try("U0TEST") {
+		t.Error("the quota was reset before the local midnight")
+	}
+	clock.advance(time.Minute)
+	if !q.try("U0TEST") {
+		t.Error("the quota wasn't reset at the local midnight")
+	}
+}
+
+func TestDailyQuotaDisabled(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		q := newDailyQuota(limit, time.UTC)
+		for i := 0; i < 100; i++ {
+			if !q.try("U0TEST") {
+				t.Fatalf("limit %d: request %d was refused", limit, i+1)
+			}
+		}
+	}
+}
+
+func TestCommandQuota(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+	b, fake := newTestBot(t, news, func(cfg *Config) { cfg.dailyQuota = 1 })
+	clock := newFakeClock()
+	b.quota.now = clock.Now
+	req := testCommandRequest(fake)
+
+	runCommand(t, b, fake, req, "stories world")
+	// the help and the sections are free
+	runCommand(t, b, fake, req, "help")
+	runCommand(t, b, fake, req, "sections")
+	responses := runCommand(t, b, fake, req, "stories world")
+	if requests := news.requested(); len(requests) != 1 {
+		t.Errorf("requested %v, want the request over the quota refused", requests)
+	}
+	last := responses[len(responses)-1]
+	if text, _ := last.message["text"].(string); !strings.Contains(text, "daily limit of 1 requests") || last.message["response_type"] != "ephemeral" {
+		t.Errorf("got %+v, want the quota notice only shown to the user", last.message)
+	}
+
+	clock.advance(12 * time.Hour)
+	runCommand(t, b, fake, req, "stories world")
+	if requests := news.requested(); len(requests) != 2 {
+		t.Errorf("requested %v, want the quota reset the next day", requests)
+	}
+}
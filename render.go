@@ -0,0 +1,528 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// RenderOptions controls how a list of stories is rendered as Slack blocks.
+// The zero value renders the default layout.
+type RenderOptions struct {
+	// HeadlinesOnly renders the titles as links, omitting abstracts and dates
+	HeadlinesOnly bool
+	// HighlightLead renders the first story in full under a header of its own, and the others as
+	// a compact list of links below it
+	HighlightLead bool
+	// OmitDates hides the publication date of each story
+	OmitDates bool
+	// Header replaces the default header of the message
+	Header string
+	// CompactHeader shows the header and the time of the message on a single context line instead
+	// of a header block, e.g. for the digests posted often
+	CompactHeader bool
+	// Notes are shown right below the header, e.g. to explain a fallback
+	Notes []string
+	// Attachments wraps each story in an attachment with a Color bar on its left
+	Attachments bool
+	Color       string
+	// Images shows the thumbnail of each story, placed according to ImageStyle
+	Images     bool
+	ImageStyle string
+	// AbstractPlaceholder is shown in place of empty abstracts, which are omitted when it's empty
+	AbstractPlaceholder string
+	// Badges maps the lowercased material types and news desks of the stories to the badge
+	// shown next to them, e.g. 'news analysis' to 'Analysis'
+	Badges map[string]string
+	// Links posts the bare links of the stories so slack unfurls them with a preview
+	Links bool
+	// Now returns the current time, used to render relative times. It defaults to time.Now.
+	Now func() time.Time
+	// QuickReplies are buttons shown below the stories to show another section in one click
+	QuickReplies []QuickReply
+	// ArticleTemplate formats the text of each story from its Article, replacing the default
+	// linked title followed by the abstract
+	ArticleTemplate *template.Template
+	// Freshness prefixes the titles of the recent stories with a badge
+	Freshness FreshnessThresholds
+	// Logger reports the stories the article template fails to format, if set
+	Logger *slog.Logger
+}
+
+// the image styles, placing the thumbnail of a story next to its text, in a full width block
+// above it, or hiding it
+const (
+	imageStyleAccessory = "accessory"
+	imageStyleBlock     = "block"
+	imageStyleNone      = "none"
+)
+
+// imageStyles are the supported values of ImageStyle, an empty style is an accessory
+var imageStyles = map[string]bool{imageStyleAccessory: true, imageStyleBlock: true, imageStyleNone: true}
+
+// FreshnessThresholds are the story ages under which the titles get a freshness badge. A zero
+// threshold disables its badge.
+type FreshnessThresholds struct {
+	New    time.Duration
+	Recent time.Duration
+}
+
+// defaultFreshness flags the stories of the last hour as new, and the ones of the last 6 hours
+// as recent
+var defaultFreshness = FreshnessThresholds{New: time.Hour, Recent: 6 * time.Hour}
+
+// QuickReply is a button showing the top stories of a section when clicked
+type QuickReply struct {
+	Label   string
+	Section string
+}
+
+// renderFlags maps the command flags to the rendering option they enable
+var renderFlags = map[string]func(*RenderOptions){
+	"--headlines": func(o *RenderOptions) { o.HeadlinesOnly = true },
+	"--no-dates":  func(o *RenderOptions) { o.OmitDates = true },
+	"--images":    func(o *RenderOptions) { o.Images = true },
+	"--links":     func(o *RenderOptions) { o.Links = true },
+	"--lead":      func(o *RenderOptions) { o.HighlightLead = true },
+}
+
+// parseRenderOptions extracts the rendering flags from the command params, applying them over defaults.
+// It returns the resulting options and the params with the flags removed.
+func parseRenderOptions(params string, defaults RenderOptions) (RenderOptions, string) {
+	opts := defaults
+	var words []string
+	for _, w := range strings.Fields(params) {
+		if apply, ok := renderFlags[w]; ok {
+			apply(&opts)
+			continue
+		}
+		words = append(words, w)
+	}
+	return opts, strings.Join(words, " ")
+}
+
+// maxAttachments is the maximum number of attachments slack accepts in a message
+const maxAttachments = 20
+
+// maxBlocks is the maximum number of blocks slack accepts in a message
+const maxBlocks = 50
+
+// truncateBlocks caps the stories blocks at max blocks, since slack rejects the whole message
+// above its limit. The trailing stories are dropped whole, each story ending with a divider, and
+// a note tells how many of the stories are shown.
+func truncateBlocks(blocks []slack.Block, max int) []slack.Block {
+	if len(blocks) <= max {
+		return blocks
+	}
+
+	kept := blocks[:max-1]
+	for i := len(kept) - 1; i >= 0; i-- {
+		if kept[i].BlockType() == slack.MBTDivider {
+			kept = kept[:i+1]
+			break
+		}
+	}
+	note := fmt.Sprintf("Showing %d of %d stories", countStories(kept), countStories(blocks))
+	return append(kept[:len(kept):len(kept)], slack.NewContextBlock("", slack.TextBlockObject{
+		Type: "mrkdwn",
+		Text: note,
+	}))
+}
+
+// countStories counts the stories in blocks, from the dividers following each of them
+func countStories(blocks []slack.Block) int {
+	count := 0
+	for _, block := range blocks {
+		if block.BlockType() == slack.MBTDivider {
+			count++
+		}
+	}
+	return count
+}
+
+// renderMessage renders the stories as a message, either as top-level blocks, with each story
+// wrapped in a colored attachment, or as bare links. Link previews are only enabled for the bare
+// links, since the other layouts already show the stories and previews would clutter them.
+func renderMessage(articles []Article, opts RenderOptions) slack.MsgOption {
+	if opts.Links {
+		return slack.MsgOptionCompose(renderLinks(articles, opts), slack.MsgOptionEnableLinkUnfurl())
+	}
+	return slack.MsgOptionCompose(renderLayout(articles, opts), slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl())
+}
+
+// renderLinks renders the header, the notes and the link of each story as plain text
+func renderLinks(articles []Article, opts RenderOptions) slack.MsgOption {
+	return slack.MsgOptionText(renderLinksText(articles, opts), false)
+}
+
+// renderLinksText returns the plain text of renderLinks
+func renderLinksText(articles []Article, opts RenderOptions) string {
+	header := opts.Header
+	if header == "" {
+		header = defaultHeader
+	}
+	lines := append([]string{fmt.Sprintf("*%s*", header)}, opts.Notes...)
+	for _, a := range articles {
+		lines = append(lines, a.URL)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderLayout renders the stories as blocks, or as attachments when enabled. The quick replies
+// follow the stories, in an attachment of their own with the attachments layout.
+func renderLayout(articles []Article, opts RenderOptions) slack.MsgOption {
+	if !opts.Attachments || opts.HeadlinesOnly || opts.HighlightLead {
+		blocks := renderStories(articles, opts)
+		if len(opts.QuickReplies) == 0 {
+			return slack.MsgOptionBlocks(truncateBlocks(blocks, maxBlocks)...)
+		}
+		blocks = append(truncateBlocks(blocks, maxBlocks-1), renderQuickReplies(opts.QuickReplies))
+		return slack.MsgOptionBlocks(blocks...)
+	}
+
+	max := maxAttachments
+	if len(opts.QuickReplies) > 0 {
+		max--
+	}
+	if len(articles) > max {
+		opts.Notes = append(opts.Notes, fmt.Sprintf("Showing %d of %d stories", max, len(articles)))
+		articles = articles[:max]
+	}
+
+	var attachments []slack.Attachment
+	for _, a := range articles {
+		attachments = append(attachments, slack.Attachment{
+			Color:  opts.Color,
+			Blocks: slack.Blocks{BlockSet: renderArticle(a, opts)},
+		})
+	}
+	if len(opts.QuickReplies) > 0 {
+		attachments = append(attachments, slack.Attachment{
+			Blocks: slack.Blocks{BlockSet: []slack.Block{renderQuickReplies(opts.QuickReplies)}},
+		})
+	}
+	return slack.MsgOptionCompose(
+		slack.MsgOptionBlocks(renderHeader(opts)...),
+		slack.MsgOptionAttachments(attachments...),
+	)
+}
+
+// renderStories builds the Block Kit message for a list of articles
+func renderStories(articles []Article, opts RenderOptions) []slack.Block {
+	blocks := renderHeader(opts)
+
+	if opts.HeadlinesOnly {
+		return append(blocks, renderHeadlines(articles, opts))
+	}
+	if opts.HighlightLead && len(articles) > 0 {
+		return append(blocks, renderLead(articles, opts)...)
+	}
+
+	for _, a := range articles {
+		blocks = append(blocks, renderArticle(a, opts)...)
+		blocks = append(blocks, slack.NewDividerBlock())
+	}
+	return blocks
+}
+
+// renderHeadlines builds a section block listing the linked titles of the stories
+func renderHeadlines(articles []Article, opts RenderOptions) slack.Block {
+	var lines []string
+	for _, a := range articles {
+		lines = append(lines, fmt.Sprintf("• <%s|%s>", a.URL, freshTitle(a, opts)))
+	}
+	return slack.NewSectionBlock(&slack.TextBlockObject{
+		Type: "mrkdwn",
+		Text: strings.Join(lines, "\n"),
+	}, nil, nil)
+}
+
+// renderLead builds the blocks of the first story under a header of its own, followed by the
+// headlines of the other stories, if any
+func renderLead(articles []Article, opts RenderOptions) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(&slack.TextBlockObject{
+			Type: "plain_text",
+			Text: "⭐ Top story",
+		}),
+	}
+	blocks = append(blocks, renderArticle(articles[0], opts)...)
+	blocks = append(blocks, slack.NewDividerBlock())
+	if len(articles) > 1 {
+		blocks = append(blocks, renderHeadlines(articles[1:], opts), slack.NewDividerBlock())
+	}
+	return blocks
+}
+
+// defaultHeader is the header of the messages that don't set one
+const defaultHeader = "📢 Here are the top stories 📢"
+
+// renderHeader builds the header of the message and the notes below it
+func renderHeader(opts RenderOptions) []slack.Block {
+	header := opts.Header
+	if header == "" {
+		header = defaultHeader
+	}
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(&slack.TextBlockObject{
+			Type: "plain_text",
+			Text: header,
+		}),
+	}
+	if opts.CompactHeader {
+		// slack shows the date in the timezone of each reader, the fallback is in UTC
+		now := opts.now()
+		date := fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", now.Unix(), now.UTC().Format("Jan 2 at 15:04 UTC"))
+		blocks = []slack.Block{
+			slack.NewContextBlock("", slack.TextBlockObject{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("*%s* · %s", header, date),
+			}),
+		}
+	}
+	for _, note := range opts.Notes {
+		blocks = append(blocks, slack.NewContextBlock("", slack.TextBlockObject{
+			Type: "mrkdwn",
+			Text: note,
+		}))
+	}
+	return blocks
+}
+
+// renderQuickReplies builds the actions block of the quick reply buttons, each holding its section
+// as value
+func renderQuickReplies(replies []QuickReply) slack.Block {
+	var buttons []slack.BlockElement
+	for _, reply := range replies {
+		buttons = append(buttons, slack.NewButtonBlockElement(
+			"quick_reply_"+reply.Section,
+			reply.Section,
+			&slack.TextBlockObject{Type: "plain_text", Text: reply.Label},
+		))
+	}
+	return slack.NewActionBlock(quickRepliesBlockID, buttons...)
+}
+
+// readMoreActionID identifies the buttons linking to the stories in interactions
+const readMoreActionID = "read_more"
+
+// readMoreButton builds the button opening a story. Slack opens the URL itself, the interaction
+// it still sends for the click is only acknowledged.
+func readMoreButton(url string) *slack.ButtonBlockElement {
+	button := slack.NewButtonBlockElement(readMoreActionID, "", &slack.TextBlockObject{
+		Type: "plain_text",
+		Text: "Read more →",
+	})
+	button.URL = url
+	return button
+}
+
+// maxSectionTextLength is the most characters slack accepts in the text of a section block
+const maxSectionTextLength = 3000
+
+// truncateText cuts text to at most max characters, ending it with an ellipsis when cut
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	if max < 1 {
+		return ""
+	}
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+// articleText formats the text of a story, with the article template if one is set
+func articleText(a Article, opts RenderOptions) string {
+	if strings.TrimSpace(a.Abstract) == "" {
+		a.Abstract = opts.AbstractPlaceholder
+	}
+	a.Title = freshTitle(a, opts)
+
+	if opts.ArticleTemplate != nil {
+		var text strings.Builder
+		err := opts.ArticleTemplate.Execute(&text, a)
+		if err == nil {
+			return truncateText(text.String(), maxSectionTextLength)
+		}
+		if opts.Logger != nil {
+			opts.Logger.Warn("error executing article template, using the default format", "url", a.URL, "error", err)
+		}
+	}
+
+	text := fmt.Sprintf("*<%s|%s>*", a.URL, a.Title)
+	if a.Abstract != "" {
+		// slack rejects the whole message when a text is too long, cut the abstract to keep the link
+		text += "\n" + truncateText(a.Abstract, maxSectionTextLength-len([]rune(text))-1)
+	}
+	return text
+}
+
+// parseArticleTemplate parses an article template, and checks it formats a story. An empty
+// template keeps the default format, and nil is returned.
+func parseArticleTemplate(value string) (*template.Template, error) {
+	if value == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("article").Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid article template: %w", err)
+	}
+	sample := Article{Title: "Title", Abstract: "Abstract", URL: "https://example.com", PublishedAt: "Jan 2, 2006"}
+	if err := tmpl.Execute(&strings.Builder{}, sample); err != nil {
+		return nil, fmt.Errorf("invalid article template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderArticle builds the blocks of a single story
+func renderArticle(a Article, opts RenderOptions) []slack.Block {
+	var blocks []slack.Block
+	var accessory *slack.Accessory
+	if opts.showImages() && a.ImageURL != "" {
+		if opts.ImageStyle == imageStyleBlock {
+			blocks = append(blocks, slack.NewImageBlock(a.ImageURL, a.Title, "", nil))
+		} else {
+			accessory = slack.NewAccessory(slack.NewImageBlockElement(a.ImageURL, a.Title))
+		}
+	}
+	// the button takes the accessory of the section unless the thumbnail has it, then it goes below
+	var readMore slack.Block
+	if a.URL != "" {
+		if accessory == nil {
+			accessory = slack.NewAccessory(readMoreButton(a.URL))
+		} else {
+			readMore = slack.NewActionBlock("", readMoreButton(a.URL))
+		}
+	}
+	text := articleText(a, opts)
+	blocks = append(blocks, slack.NewSectionBlock(&slack.TextBlockObject{
+		Type: "mrkdwn",
+		Text: text,
+	}, nil, accessory))
+	if readMore != nil {
+		blocks = append(blocks, readMore)
+	}
+	var details []string
+	if badge := articleBadge(a, opts.Badges); badge != "" {
+		details = append(details, "🏷 "+badge)
+	}
+	if !opts.OmitDates && a.PublishedAt != "" {
+		date := "📅 " + a.PublishedAt
+		if a.UpdatedTime.Sub(a.PublishedTime) >= minUpdateDelay && !a.PublishedTime.IsZero() {
+			date += " · Updated " + relativeTime(opts.now().Sub(a.UpdatedTime))
+		}
+		details = append(details, date)
+	}
+	if len(details) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("", slack.TextBlockObject{
+			Type: "plain_text",
+			Text: strings.Join(details, " · "),
+		}))
+	}
+	return blocks
+}
+
+// defaultBadges are the badges shown when none are configured. Plain news stories get no badge.
+var defaultBadges = map[string]string{
+	"news analysis": "Analysis",
+	"review":        "Review",
+	"op-ed":         "Opinion",
+	"editorial":     "Opinion",
+	"interactive":   "Interactive",
+	"obituary":      "Obituary",
+}
+
+// parseBadges parses badges in the 'material type=badge' format, falling back to the default
+// badges when there are none
+func parseBadges(entries []string) (map[string]string, error) {
+	if len(entries) == 0 {
+		return defaultBadges, nil
+	}
+	badges := map[string]string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid badge %q, expected 'material type=badge'", entry)
+		}
+		badges[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return badges, nil
+}
+
+// articleBadge picks the badge of the story from its material type, or else its news desk.
+// It returns an empty string when neither is in the badges.
+func articleBadge(a Article, badges map[string]string) string {
+	for _, kind := range []string{a.MaterialType, a.NewsDesk} {
+		if badge, ok := badges[strings.ToLower(strings.TrimSpace(kind))]; ok && kind != "" {
+			return badge
+		}
+	}
+	return ""
+}
+
+// showImages tells whether the thumbnails of the stories are rendered
+func (o RenderOptions) showImages() bool {
+	return o.Images && o.ImageStyle != imageStyleNone
+}
+
+// now returns the current time from Now, or time.Now when it isn't set
+func (o RenderOptions) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// freshnessBadge returns the badge of a story published under the freshness thresholds, or an
+// empty string for older stories and the ones without a publication time
+func freshnessBadge(a Article, opts RenderOptions) string {
+	if a.PublishedTime.IsZero() {
+		return ""
+	}
+	age := opts.now().Sub(a.PublishedTime)
+	switch {
+	case opts.Freshness.New > 0 && age < opts.Freshness.New:
+		return "🆕"
+	case opts.Freshness.Recent > 0 && age < opts.Freshness.Recent:
+		return "🕐"
+	default:
+		return ""
+	}
+}
+
+// freshTitle prefixes the title of a story with its freshness badge, if any
+func freshTitle(a Article, opts RenderOptions) string {
+	if badge := freshnessBadge(a, opts); badge != "" {
+		return badge + " " + a.Title
+	}
+	return a.Title
+}
+
+// minUpdateDelay is how long after its publication an update is worth showing, since stories
+// are often touched up right after being published
+const minUpdateDelay = 30 * time.Minute
+
+// relativeTime describes how long ago something happened, e.g. '3 hours ago'
+func relativeTime(ago time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case ago < time.Minute:
+		return "just now"
+	case ago < time.Hour:
+		return plural(int(ago/time.Minute), "minute")
+	case ago < 24*time.Hour:
+		return plural(int(ago/time.Hour), "hour")
+	default:
+		return plural(int(ago/(24*time.Hour)), "day")
+	}
+}
@@ -0,0 +1,788 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+func TestRenderStoriesCombinations(t *testing.T) {
+	a := testArticle("Title")
+	for _, headlines := range []bool{false, true} {
+		for _, omitDates := range []bool{false, true} {
+			for _, images := range []bool{false, true} {
+				opts := RenderOptions{HeadlinesOnly: headlines, OmitDates: omitDates, Images: images, Now: func() time.Time { return testNow }}
+				name := fmt.Sprintf("headlines=%t,omitDates=%t,images=%t", headlines, omitDates, images)
+				t.Run(name, func(t *testing.T) {
+					blocks := jsonBlocks(t, renderStories([]Article{a}, opts))
+					text := jsonString(t, blocks)
+
+					if !strings.Contains(text, a.URL) || !strings.Contains(text, a.Title) {
+						t.Errorf("the linked title is missing from %s", text)
+					}
+					wantAbstract := !headlines
+					wantDate := !headlines && !omitDates
+					wantImage := !headlines && images
+					if got := strings.Contains(text, a.Abstract); got != wantAbstract {
+						t.Errorf("abstract shown: %t, want %t", got, wantAbstract)
+					}
+					if got := strings.Contains(text, a.PublishedAt); got != wantDate {
+						t.Errorf("date shown: %t, want %t", got, wantDate)
+					}
+					if got := strings.Contains(text, a.ImageURL); got != wantImage {
+						t.Errorf("image shown: %t, want %t", got, wantImage)
+					}
+					if headlines {
+						want := []string{"header", "section"}
+						if got := blockTypes(blocks); strings.Join(got, ",") != strings.Join(want, ",") {
+							t.Errorf("got blocks %v, want %v", got, want)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestParseRenderOptions(t *testing.T) {
+	defaults := RenderOptions{Header: "Default header", Images: false}
+	tests := []struct {
+		params     string
+		want       RenderOptions
+		wantParams string
+	}{
+		{"world", defaults, "world"},
+		{"", defaults, ""},
+		{"world --headlines", RenderOptions{Header: "Default header", HeadlinesOnly: true}, "world"},
+		{"--no-dates science 5", RenderOptions{Header: "Default header", OmitDates: true}, "science 5"},
+		{"--images --links us", RenderOptions{Header: "Default header", Images: true, Links: true}, "us"},
+		{"world --lead --headlines --no-dates", RenderOptions{Header: "Default header", HighlightLead: true, HeadlinesOnly: true, OmitDates: true}, "world"},
+		// unknown flags are kept, so they are reported as part of the section
+		{"world --bogus", defaults, "world --bogus"},
+		{"  world   --images  ", RenderOptions{Header: "Default header", Images: true}, "world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.params, func(t *testing.T) {
+			got, params := parseRenderOptions(tt.params, defaults)
+			if params != tt.wantParams {
+				t.Errorf("got params %q, want %q", params, tt.wantParams)
+			}
+			if got.HeadlinesOnly != tt.want.HeadlinesOnly || got.OmitDates != tt.want.OmitDates || got.Images != tt.want.Images ||
+				got.Links != tt.want.Links || got.HighlightLead != tt.want.HighlightLead || got.Header != tt.want.Header {
+				t.Errorf("got options %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRenderOptionsKeepsDefaults(t *testing.T) {
+	defaults := RenderOptions{Images: true, OmitDates: true}
+	got, _ := parseRenderOptions("world --headlines", defaults)
+	if !got.Images || !got.OmitDates || !got.HeadlinesOnly {
+		t.Errorf("got %+v, want the flags on top of the defaults", got)
+	}
+	if defaults.HeadlinesOnly {
+		t.Error("parsing changed the defaults")
+	}
+}
+
+// messageAttachments decodes the attachments of a message
+func messageAttachments(t *testing.T, options ...slack.MsgOption) []map[string]interface{} {
+	t.Helper()
+	var attachments []map[string]interface{}
+	if data := messageValues(t, options...).Get("attachments"); data != "" {
+		if err := json.Unmarshal([]byte(data), &attachments); err != nil {
+			t.Fatalf("decoding message attachments: %v", err)
+		}
+	}
+	return attachments
+}
+
+func TestRenderAttachmentsColor(t *testing.T) {
+	opts := RenderOptions{Attachments: true, Color: "#567b95", Now: func() time.Time { return testNow }}
+	message := renderMessage(testArticles(3), opts)
+
+	attachments := messageAttachments(t, message)
+	if len(attachments) != 3 {
+		t.Fatalf("got %d attachments, want one per story", len(attachments))
+	}
+	for i, attachment := range attachments {
+		if attachment["color"] != "#567b95" {
+			t.Errorf("attachment %d has color %v, want #567b95", i, attachment["color"])
+		}
+		if !strings.Contains(jsonString(t, attachment), fmt.Sprintf("Story %d", i+1)) {
+			t.Errorf("attachment %d doesn't hold story %d", i, i+1)
+		}
+	}
+	if got := blockTypes(messageBlocks(t, message)); strings.Join(got, ",") != "header" {
+		t.Errorf("got top-level blocks %v, want only the header", got)
+	}
+}
+
+func TestRenderAttachmentsLimit(t *testing.T) {
+	opts := RenderOptions{Attachments: true, Color: "#567b95"}
+	tests := []struct {
+		name         string
+		quickReplies []QuickReply
+		stories      int
+	}{
+		{"stories", nil, maxAttachments},
+		{"stories and quick replies", []QuickReply{{Label: "World", Section: "world"}}, maxAttachments - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := opts
+			opts.QuickReplies = tt.quickReplies
+			message := renderMessage(testArticles(25), opts)
+			attachments := messageAttachments(t, message)
+			if len(attachments) > maxAttachments {
+				t.Fatalf("got %d attachments, slack accepts %d", len(attachments), maxAttachments)
+			}
+			colored := 0
+			for _, attachment := range attachments {
+				if attachment["color"] == "#567b95" {
+					colored++
+				}
+			}
+			if colored != tt.stories {
+				t.Errorf("got %d stories, want %d", colored, tt.stories)
+			}
+			note := fmt.Sprintf("Showing %d of 25 stories", tt.stories)
+			if !strings.Contains(jsonString(t, messageBlocks(t, message)), note) {
+				t.Errorf("the header doesn't note %q", note)
+			}
+		})
+	}
+}
+
+func TestRenderWithoutAttachments(t *testing.T) {
+	for _, opts := range []RenderOptions{
+		{Color: "#567b95"},
+		{Attachments: true, HeadlinesOnly: true, Color: "#567b95"},
+		{Attachments: true, HighlightLead: true, Color: "#567b95"},
+	} {
+		message := renderMessage(testArticles(3), opts)
+		if attachments := messageAttachments(t, message); len(attachments) > 0 {
+			t.Errorf("got %d attachments with %+v, want blocks only", len(attachments), opts)
+		}
+		if !strings.Contains(jsonString(t, messageBlocks(t, message)), "Story 3") {
+			t.Errorf("the blocks don't hold the stories with %+v", opts)
+		}
+	}
+}
+
+func TestRenderDefaultsColor(t *testing.T) {
+	news := &fakeNews{}
+	if got := renderDefaults(news, Config{}, nil).Color; got != news.BrandColor() {
+		t.Errorf("got color %q, want the brand color %q", got, news.BrandColor())
+	}
+	if got := renderDefaults(news, Config{attachmentColor: "#ff0000"}, nil).Color; got != "#ff0000" {
+		t.Errorf("got color %q, want the configured #ff0000", got)
+	}
+}
+
+func TestArticleTextEmptyAbstract(t *testing.T) {
+	a := testArticle("Title")
+	tests := []struct {
+		name        string
+		abstract    string
+		placeholder string
+		want        string
+	}{
+		{"abstract", "The abstract", "", "*<https://nyti.ms/Title|Title>*\nThe abstract"},
+		{"empty abstract", "", "", "*<https://nyti.ms/Title|Title>*"},
+		{"blank abstract", " \n ", "", "*<https://nyti.ms/Title|Title>*"},
+		{"empty abstract with a placeholder", "", "_No summary available_", "*<https://nyti.ms/Title|Title>*\n_No summary available_"},
+		{"abstract with a placeholder", "The abstract", "_No summary available_", "*<https://nyti.ms/Title|Title>*\nThe abstract"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := a
+			a.Abstract = tt.abstract
+			if got := articleText(a, RenderOptions{AbstractPlaceholder: tt.placeholder}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderArticleEmptyAbstract(t *testing.T) {
+	a := testArticle("Title")
+	a.Abstract = ""
+	blocks := jsonBlocks(t, renderArticle(a, RenderOptions{OmitDates: true}))
+	text := blocks[0]["text"].(map[string]interface{})["text"].(string)
+	if strings.HasSuffix(text, "\n") || strings.Contains(text, "\n\n") {
+		t.Errorf("got text %q, want no blank line for the empty abstract", text)
+	}
+}
+
+func TestRenderArticleUpdatedTime(t *testing.T) {
+	published := testNow.Add(-5 * time.Hour)
+	tests := []struct {
+		name    string
+		updated time.Time
+		noDate  bool
+		want    string
+	}{
+		{"never updated", time.Time{}, false, "📅 March 13, 2024"},
+		{"updated with the publication", published, false, "📅 March 13, 2024"},
+		{"touched up right after", published.Add(10 * time.Minute), false, "📅 March 13, 2024"},
+		{"updated later", testNow.Add(-2 * time.Hour), false, "📅 March 13, 2024 · Updated 2 hours ago"},
+		{"updated just now", testNow.Add(-30 * time.Second), false, "📅 March 13, 2024 · Updated just now"},
+		{"updated without a publication time", testNow.Add(-2 * time.Hour), true, "📅 March 13, 2024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testArticle("Title")
+			a.PublishedTime = published
+			if tt.noDate {
+				a.PublishedTime = time.Time{}
+			}
+			a.UpdatedTime = tt.updated
+			blocks := jsonBlocks(t, renderArticle(a, RenderOptions{Now: func() time.Time { return testNow }}))
+			details := blocks[len(blocks)-1]
+			if details["type"] != "context" {
+				t.Fatalf("got last block %v, want the date context", details)
+			}
+			if got := details["elements"].([]interface{})[0].(map[string]interface{})["text"]; got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	tests := map[time.Duration]string{
+		10 * time.Second: "just now",
+		time.Minute:      "1 minute ago",
+		45 * time.Minute: "45 minutes ago",
+		time.Hour:        "1 hour ago",
+		23 * time.Hour:   "23 hours ago",
+		49 * time.Hour:   "2 days ago",
+	}
+	for ago, want := range tests {
+		if got := relativeTime(ago); got != want {
+			t.Errorf("relativeTime(%s) = %q, want %q", ago, got, want)
+		}
+	}
+}
+
+func TestRenderMessageUnfurl(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   RenderOptions
+		unfurl string
+	}{
+		{"blocks", RenderOptions{}, "false"},
+		{"attachments", RenderOptions{Attachments: true}, "false"},
+		{"headlines", RenderOptions{HeadlinesOnly: true}, "false"},
+		{"links", RenderOptions{Links: true}, "true"},
+		{"links over attachments", RenderOptions{Links: true, Attachments: true}, "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Now = func() time.Time { return testNow }
+			values := messageValues(t, renderMessage(testArticles(2), tt.opts))
+			if got := values.Get("unfurl_links"); got != tt.unfurl {
+				t.Errorf("got unfurl_links %q, want %q", got, tt.unfurl)
+			}
+			if tt.opts.Links && values.Get("blocks") != "" {
+				t.Error("the links are posted with blocks, which slack doesn't unfurl")
+			}
+		})
+	}
+}
+
+func TestArticleBadge(t *testing.T) {
+	badges := map[string]string{"news analysis": "Analysis", "review": "Review", "metro": "Local"}
+	tests := []struct {
+		name                   string
+		materialType, newsDesk string
+		want                   string
+	}{
+		{"material type", "News Analysis", "", "Analysis"},
+		{"material type with spaces", " Review ", "", "Review"},
+		{"news desk", "", "Metro", "Local"},
+		{"material type over news desk", "Review", "Metro", "Review"},
+		{"unknown material type falls back to the news desk", "News", "Metro", "Local"},
+		{"unknown", "News", "Foreign", ""},
+		{"none", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Article{MaterialType: tt.materialType, NewsDesk: tt.newsDesk}
+			if got := articleBadge(a, badges); got != tt.want {
+				t.Errorf("got badge %q, want %q", got, tt.want)
+			}
+		})
+	}
+	// a badge configured for an empty kind isn't given to the stories of an unknown kind
+	if got := articleBadge(Article{}, map[string]string{"": "Story"}); got != "" {
+		t.Errorf("got badge %q for a story of an unknown kind", got)
+	}
+}
+
+func TestParseBadges(t *testing.T) {
+	badges, err := parseBadges(nil)
+	if err != nil || !reflect.DeepEqual(badges, defaultBadges) {
+		t.Errorf("got %v, %v, want the default badges", badges, err)
+	}
+	badges, err = parseBadges([]string{"News Analysis = Analysis", "review=Critique"})
+	if want := map[string]string{"news analysis": "Analysis", "review": "Critique"}; err != nil || !reflect.DeepEqual(badges, want) {
+		t.Errorf("got %v, %v, want %v", badges, err, want)
+	}
+	for _, entry := range []string{"review", "=Review", "review=", " = "} {
+		if _, err := parseBadges([]string{entry}); err == nil {
+			t.Errorf("parseBadges(%q) didn't fail", entry)
+		}
+	}
+}
+
+func TestRenderArticleBadge(t *testing.T) {
+	a := testArticle("Title")
+	a.MaterialType = "Review"
+	text := jsonString(t, renderArticle(a, RenderOptions{Badges: defaultBadges, Now: func() time.Time { return testNow }}))
+	if !strings.Contains(text, "🏷 Review · 📅 "+a.PublishedAt) {
+		t.Errorf("the badge is missing next to the date in %s", text)
+	}
+
+	a.MaterialType = "News"
+	text = jsonString(t, renderArticle(a, RenderOptions{Badges: defaultBadges, Now: func() time.Time { return testNow }}))
+	if strings.Contains(text, "🏷") {
+		t.Errorf("got a badge for a plain news story in %s", text)
+	}
+}
+
+func TestArticleTextLongAbstract(t *testing.T) {
+	a := testArticle("Title")
+	link := fmt.Sprintf("*<%s|%s>*", a.URL, a.Title)
+	fits := maxSectionTextLength - utf8.RuneCountInString(link) - 1
+	tests := []struct {
+		name      string
+		abstract  string
+		truncated bool
+	}{
+		{"short", "A short abstract.", false},
+		{"at the limit", strings.Repeat("a", fits), false},
+		{"over the limit", strings.Repeat("a", fits+1), true},
+		{"far over the limit", strings.Repeat("word ", 2000), true},
+		{"multibyte", strings.Repeat("é", 4000), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.Abstract = tt.abstract
+			text := articleText(a, RenderOptions{})
+			if n := utf8.RuneCountInString(text); n > maxSectionTextLength {
+				t.Errorf("got a text of %d characters, want at most %d", n, maxSectionTextLength)
+			}
+			if !strings.HasPrefix(text, link+"\n") {
+				t.Errorf("the link was cut from %.80q", text)
+			}
+			if got := strings.HasSuffix(text, "…"); got != tt.truncated {
+				t.Errorf("truncated: %t, want %t", got, tt.truncated)
+			}
+			if !tt.truncated && text != link+"\n"+tt.abstract {
+				t.Errorf("got %q, want the whole abstract", text)
+			}
+		})
+	}
+}
+
+func TestArticleTextLongTemplate(t *testing.T) {
+	tmpl, err := parseArticleTemplate("{{.Title}}: {{.Abstract}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testArticle("Title")
+	a.Abstract = strings.Repeat("a", 5000)
+	text := articleText(a, RenderOptions{ArticleTemplate: tmpl})
+	if n := utf8.RuneCountInString(text); n != maxSectionTextLength || !strings.HasSuffix(text, "…") {
+		t.Errorf("got a text of %d characters, want it cut to %d", n, maxSectionTextLength)
+	}
+}
+
+func TestArticleTextTemplate(t *testing.T) {
+	tmpl, err := parseArticleTemplate("<{{.URL}}|{{.Title}}> ({{.PublishedAt}})\n{{.Abstract}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testArticle("Title")
+	want := "<https://nyti.ms/Title|Title> (March 13, 2024)\nThe abstract of Title"
+	if text := articleText(a, RenderOptions{ArticleTemplate: tmpl}); text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+
+	a.Abstract = ""
+	text := articleText(a, RenderOptions{ArticleTemplate: tmpl, AbstractPlaceholder: "No summary"})
+	if !strings.HasSuffix(text, "\nNo summary") {
+		t.Errorf("got %q, want the placeholder of the missing abstract", text)
+	}
+}
+
+func TestArticleTextTemplateError(t *testing.T) {
+	// the sample story has no image, so the template only fails on real stories
+	tmpl, err := parseArticleTemplate("{{if .ImageURL}}{{.ImageURL.Missing}}{{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testArticle("Title")
+	if text := articleText(a, RenderOptions{ArticleTemplate: tmpl}); text != articleText(a, RenderOptions{}) {
+		t.Errorf("got %q, want the default format when the template fails", text)
+	}
+}
+
+func TestParseArticleTemplate(t *testing.T) {
+	tmpl, err := parseArticleTemplate("")
+	if tmpl != nil || err != nil {
+		t.Errorf("got %v, %v for an empty template, want the default format", tmpl, err)
+	}
+	for _, value := range []string{"{{.Title", "{{.Missing}}", "{{template \"nope\"}}"} {
+		if _, err := parseArticleTemplate(value); err == nil {
+			t.Errorf("got no error for the template %q", value)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"hello", 5, "hello"},
+		{"hello world", 6, "hello…"},
+		{"héllo wörld", 8, "héllo w…"},
+		{"hello", 0, ""},
+		{"hello", -3, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.text, tt.max); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+// storyBlocks builds a header followed by n stories of a section, a context and a divider each
+func storyBlocks(n int) []slack.Block {
+	blocks := []slack.Block{slack.NewHeaderBlock(slack.NewTextBlockObject("plain_text", "Header", false, false))}
+	for i := 0; i < n; i++ {
+		blocks = append(blocks,
+			slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Story %d", i+1), false, false), nil, nil),
+			slack.NewContextBlock("", slack.NewTextBlockObject("plain_text", "date", false, false)),
+			slack.NewDividerBlock(),
+		)
+	}
+	return blocks
+}
+
+func TestTruncateBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		stories int
+		max     int
+		// kept is the number of stories kept, all of them when the blocks fit
+		kept int
+	}{
+		{"under the limit", 3, 50, 3},
+		{"at the limit", 3, 10, 3},
+		{"one over the limit", 4, 12, 3},
+		{"far over the limit", 30, 50, 16},
+		{"limit in the middle of a story", 10, 15, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := storyBlocks(tt.stories)
+			original := append([]slack.Block{}, blocks...)
+			got := truncateBlocks(blocks, tt.max)
+			if len(got) > tt.max {
+				t.Errorf("got %d blocks, want at most %d", len(got), tt.max)
+			}
+			if !reflect.DeepEqual(blocks, original) {
+				t.Error("the blocks given were changed")
+			}
+			if tt.kept == tt.stories {
+				if !reflect.DeepEqual(got, blocks) {
+					t.Error("the blocks under the limit were changed")
+				}
+				return
+			}
+
+			if n := countStories(got); n != tt.kept {
+				t.Errorf("kept %d stories, want %d", n, tt.kept)
+			}
+			last := jsonBlocks(t, got[len(got)-1:])
+			if want := fmt.Sprintf("Showing %d of %d stories", tt.kept, tt.stories); !strings.Contains(jsonString(t, last), want) {
+				t.Errorf("got last block %s, want the note %q", jsonString(t, last), want)
+			}
+			if got[len(got)-2].BlockType() != slack.MBTDivider {
+				t.Error("a story was cut in the middle")
+			}
+		})
+	}
+}
+
+func TestRenderLayoutBlockLimit(t *testing.T) {
+	opts := RenderOptions{Now: func() time.Time { return testNow }}
+	replies := RenderOptions{Now: opts.Now, QuickReplies: []QuickReply{{Label: "World", Section: "world"}}}
+	for _, opts := range []RenderOptions{opts, replies} {
+		blocks := messageBlocks(t, renderLayout(testArticles(30), opts))
+		if len(blocks) > maxBlocks {
+			t.Errorf("got %d blocks, over the limit of %d", len(blocks), maxBlocks)
+		}
+		if !strings.Contains(jsonString(t, blocks), "of 30 stories") {
+			t.Error("the note about the stories shown is missing")
+		}
+		if len(opts.QuickReplies) > 0 && blocks[len(blocks)-1]["block_id"] != quickRepliesBlockID {
+			t.Errorf("got last block %v, want the quick replies kept", blocks[len(blocks)-1])
+		}
+	}
+}
+
+func TestFreshnessBadge(t *testing.T) {
+	tests := []struct {
+		name       string
+		age        time.Duration
+		thresholds FreshnessThresholds
+		want       string
+	}{
+		{"new", 10 * time.Minute, defaultFreshness, "🆕"},
+		{"recent", 2 * time.Hour, defaultFreshness, "🕐"},
+		{"new threshold", time.Hour, defaultFreshness, "🕐"},
+		{"recent threshold", 6 * time.Hour, defaultFreshness, ""},
+		{"old", 24 * time.Hour, defaultFreshness, ""},
+		{"new disabled", 10 * time.Minute, FreshnessThresholds{Recent: 6 * time.Hour}, "🕐"},
+		{"recent disabled", 2 * time.Hour, FreshnessThresholds{New: time.Hour}, ""},
+		{"disabled", 10 * time.Minute, FreshnessThresholds{}, ""},
+		{"published ahead of the clock", -time.Minute, defaultFreshness, "🆕"},
+		{"published ahead of the clock, disabled", -time.Minute, FreshnessThresholds{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testArticle("Title")
+			a.PublishedTime = testNow.Add(-tt.age)
+			opts := RenderOptions{Freshness: tt.thresholds, Now: func() time.Time { return testNow }}
+			if got := freshnessBadge(a, opts); got != tt.want {
+				t.Errorf("got badge %q, want %q", got, tt.want)
+			}
+			want := "Title"
+			if tt.want != "" {
+				want = tt.want + " Title"
+			}
+			if got := freshTitle(a, opts); got != want {
+				t.Errorf("got title %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFreshnessBadgeMissingTime(t *testing.T) {
+	a := testArticle("Title")
+	a.PublishedTime = time.Time{}
+	opts := RenderOptions{Freshness: defaultFreshness, Now: func() time.Time { return testNow }}
+	if got := freshnessBadge(a, opts); got != "" {
+		t.Errorf("got badge %q for a story without a publication time", got)
+	}
+	if text := articleText(a, opts); !strings.HasPrefix(text, "*<https://nyti.ms/Title|Title>*") {
+		t.Errorf("got %q, want the title without a badge", text)
+	}
+
+	a.PublishedTime = testNow.Add(-time.Minute)
+	if text := articleText(a, opts); !strings.HasPrefix(text, "*<https://nyti.ms/Title|🆕 Title>*") {
+		t.Errorf("got %q, want the badge in the link of the title", text)
+	}
+}
+
+func TestRenderArticleImageStyles(t *testing.T) {
+	tests := []struct {
+		style     string
+		images    bool
+		blocks    []string
+		accessory string
+	}{
+		{imageStyleAccessory, true, []string{"section", "actions", "context"}, "image"},
+		{"", true, []string{"section", "actions", "context"}, "image"},
+		{imageStyleBlock, true, []string{"image", "section", "context"}, "button"},
+		{imageStyleNone, true, []string{"section", "context"}, "button"},
+		{imageStyleBlock, false, []string{"section", "context"}, "button"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q images %t", tt.style, tt.images), func(t *testing.T) {
+			a := testArticle("Title")
+			blocks := jsonBlocks(t, renderArticle(a, RenderOptions{Images: tt.images, ImageStyle: tt.style}))
+			if got := blockTypes(blocks); !reflect.DeepEqual(got, tt.blocks) {
+				t.Fatalf("got blocks %v, want %v", got, tt.blocks)
+			}
+			for _, block := range blocks {
+				switch block["type"] {
+				case "image":
+					if block["image_url"] != a.ImageURL || block["alt_text"] != a.Title {
+						t.Errorf("got image block %v, want the thumbnail of the story", block)
+					}
+				case "section":
+					accessory := block["accessory"].(map[string]interface{})
+					if accessory["type"] != tt.accessory {
+						t.Errorf("got a %v accessory, want %s", accessory["type"], tt.accessory)
+					}
+				case "actions":
+					button := block["elements"].([]interface{})[0].(map[string]interface{})
+					if button["url"] != a.URL {
+						t.Errorf("got button %v, want the read more button below the thumbnail", button)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRenderHeaderCompact(t *testing.T) {
+	opts := RenderOptions{Header: "World", Notes: []string{"A note"}, Now: func() time.Time { return testNow }}
+	full := jsonBlocks(t, renderHeader(opts))
+	if got, want := blockTypes(full), []string{"header", "context"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got blocks %v, want %v", got, want)
+	}
+	if text := full[0]["text"].(map[string]interface{})["text"]; text != "World" {
+		t.Errorf("got header %v, want World", text)
+	}
+
+	opts.CompactHeader = true
+	compact := jsonBlocks(t, renderHeader(opts))
+	if got, want := blockTypes(compact), []string{"context", "context"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got blocks %v, want %v", got, want)
+	}
+	line := compact[0]["elements"].([]interface{})[0].(map[string]interface{})
+	want := fmt.Sprintf("*World* · <!date^%d^{date_short_pretty} at {time}|Mar 14 at 12:00 UTC>", testNow.Unix())
+	if line["type"] != "mrkdwn" || line["text"] != want {
+		t.Errorf("got header line %v, want %q", line, want)
+	}
+	if note := compact[1]["elements"].([]interface{})[0].(map[string]interface{})["text"]; note != "A note" {
+		t.Errorf("got note %v, want the notes kept below the header", note)
+	}
+
+	opts.Header = ""
+	compact = jsonBlocks(t, renderHeader(opts))
+	if text := compact[0]["elements"].([]interface{})[0].(map[string]interface{})["text"].(string); !strings.HasPrefix(text, "*"+defaultHeader+"* · ") {
+		t.Errorf("got header line %q, want the default header", text)
+	}
+}
+
+func TestRenderLead(t *testing.T) {
+	tests := []struct {
+		articles int
+		blocks   []string
+	}{
+		{0, []string{"header"}},
+		{1, []string{"header", "header", "section", "context", "divider"}},
+		{2, []string{"header", "header", "section", "context", "divider", "section", "divider"}},
+		{5, []string{"header", "header", "section", "context", "divider", "section", "divider"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d stories", tt.articles), func(t *testing.T) {
+			articles := testArticles(tt.articles)
+			blocks := jsonBlocks(t, renderStories(articles, RenderOptions{HighlightLead: true}))
+			if got := blockTypes(blocks); !reflect.DeepEqual(got, tt.blocks) {
+				t.Fatalf("got blocks %v, want %v", got, tt.blocks)
+			}
+			if tt.articles == 0 {
+				return
+			}
+			if text := blocks[1]["text"].(map[string]interface{})["text"]; text != "⭐ Top story" {
+				t.Errorf("got lead header %v", text)
+			}
+			lead := blocks[2]["text"].(map[string]interface{})["text"].(string)
+			if !strings.Contains(lead, "Story 1") || !strings.Contains(lead, "The abstract of Story 1") {
+				t.Errorf("got lead %q, want the first story in full", lead)
+			}
+			if tt.articles == 1 {
+				return
+			}
+			headlines := strings.Split(blocks[5]["text"].(map[string]interface{})["text"].(string), "\n")
+			if len(headlines) != tt.articles-1 {
+				t.Fatalf("got headlines %q, want the %d other stories", headlines, tt.articles-1)
+			}
+			for i, line := range headlines {
+				if want := fmt.Sprintf("• <https://nyti.ms/Story%%20%d|Story %d>", i+2, i+2); line != want {
+					t.Errorf("got headline %q, want %q", line, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderLayoutLeadWithAttachments(t *testing.T) {
+	values := messageValues(t, renderLayout(testArticles(3), RenderOptions{HighlightLead: true, Attachments: true}))
+	if values.Get("attachments") != "" {
+		t.Errorf("got attachments %s, want the highlighted layout in blocks", values.Get("attachments"))
+	}
+	if !strings.Contains(values.Get("blocks"), "⭐ Top story") {
+		t.Errorf("got blocks %s, want the lead story", values.Get("blocks"))
+	}
+}
+
+func TestRenderArticleDate(t *testing.T) {
+	now := func() time.Time { return testNow }
+	undated := testArticle("Title")
+	undated.PublishedAt = ""
+	undated.PublishedTime = time.Time{}
+	updated := testArticle("Title")
+	updated.UpdatedTime = testNow.Add(-2 * time.Hour)
+	review := undated
+	review.MaterialType = "Review"
+	tests := []struct {
+		name    string
+		article Article
+		opts    RenderOptions
+		context string
+	}{
+		{"date", testArticle("Title"), RenderOptions{Now: now}, "📅 March 13, 2024"},
+		{"updated", updated, RenderOptions{Now: now}, "📅 March 13, 2024 · Updated 2 hours ago"},
+		{"no date", undated, RenderOptions{Now: now}, ""},
+		{"dates omitted", testArticle("Title"), RenderOptions{Now: now, OmitDates: true}, ""},
+		{"badge without a date", review, RenderOptions{Now: now, Badges: defaultBadges}, "🏷 Review"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := jsonBlocks(t, renderArticle(tt.article, tt.opts))
+			want := []string{"section"}
+			if tt.context != "" {
+				want = append(want, "context")
+			}
+			if got := blockTypes(blocks); !reflect.DeepEqual(got, want) {
+				t.Fatalf("got blocks %v, want %v", got, want)
+			}
+			if tt.context == "" {
+				return
+			}
+			if text := blocks[1]["elements"].([]interface{})[0].(map[string]interface{})["text"]; text != tt.context {
+				t.Errorf("got context %q, want %q", text, tt.context)
+			}
+		})
+	}
+}
+
+func TestReadMoreButton(t *testing.T) {
+	a := testArticle("Title")
+	a.URL = "https://www.nytimes.com/2024/03/13/world/title.html?smid=url-share"
+	blocks := jsonBlocks(t, renderArticle(a, RenderOptions{}))
+	button := blocks[0]["accessory"].(map[string]interface{})
+	if button["type"] != "button" || button["action_id"] != readMoreActionID || button["url"] != a.URL {
+		t.Errorf("got accessory %v, want the read more button opening %s", button, a.URL)
+	}
+	if text := button["text"].(map[string]interface{})["text"]; text != "Read more →" {
+		t.Errorf("got button label %v", text)
+	}
+
+	a.URL = ""
+	blocks = jsonBlocks(t, renderArticle(a, RenderOptions{}))
+	if _, ok := blocks[0]["accessory"]; ok {
+		t.Errorf("got accessory %v, want no button without a URL", blocks[0]["accessory"])
+	}
+	if strings.Contains(jsonString(t, blocks), readMoreActionID) {
+		t.Errorf("got a read more button without a URL in %v", blocks)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError is a network error timing out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// hang is a failure of flakyTransport answering only once the request is cancelled
+type hang struct{}
+
+// flakyTransport answers each request with the next failure, an error or a status, then with
+// the top stories once the failures run out
+type flakyTransport struct {
+	t  *testing.T
+	mu sync.Mutex
+	// failures are either errors, HTTP statuses or hang
+	failures []interface{}
+	calls    int
+	// deadlines are the deadlines of the requests, zero for the requests without one
+	deadlines []time.Time
+}
+
+func (f *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.calls++
+	deadline, _ := r.Context().Deadline()
+	f.deadlines = append(f.deadlines, deadline)
+	var failure interface{}
+	if len(f.failures) > 0 {
+		failure, f.failures = f.failures[0], f.failures[1:]
+	}
+	f.mu.Unlock()
+
+	status := http.StatusOK
+	switch failure := failure.(type) {
+	case error:
+		return nil, failure
+	case hang:
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	case int:
+		status = failure
+	}
+	body, err := json.Marshal(topStoriesResponse(nytStory("Story")))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func (f *flakyTransport) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func TestNYTimesRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []interface{}
+		ok       bool
+		calls    int
+	}{
+		{"5xx twice", []interface{}{http.StatusServiceUnavailable, http.StatusBadGateway}, true, 3},
+		{"connection resets", []interface{}{syscall.ECONNRESET, syscall.ECONNRESET}, true, 3},
+		{"timeouts", []interface{}{timeoutError{}, timeoutError{}}, true, 3},
+		{"mixed", []interface{}{http.StatusInternalServerError, timeoutError{}}, true, 3},
+		{"every attempt fails", []interface{}{500, 500, 500, 500}, false, 3},
+		{"not found", []interface{}{http.StatusNotFound}, false, 1},
+		{"bad request", []interface{}{http.StatusBadRequest}, false, 1},
+		{"other network error", []interface{}{errors.New("no such host")}, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &flakyTransport{t: t, failures: tt.failures}
+			nyt, err := NewNYTimes("test-key", WithHTTPClient(&http.Client{Transport: transport}), WithAttempts(3), WithRetryDelay(time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			articles, err := nyt.TopStories(context.Background(), "world", 1)
+			if ok := err == nil; ok != tt.ok {
+				t.Errorf("got error %v, want success %t", err, tt.ok)
+			}
+			if tt.ok && len(articles) != 1 {
+				t.Errorf("got %d stories, want 1", len(articles))
+			}
+			if n := transport.requests(); n != tt.calls {
+				t.Errorf("sent %d requests, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestNYTimesRetryCancelled(t *testing.T) {
+	transport := &flakyTransport{t: t, failures: []interface{}{500, 500}}
+	nyt, err := NewNYTimes("test-key", WithHTTPClient(&http.Client{Transport: transport}), WithAttempts(3), WithRetryDelay(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := nyt.TopStories(ctx, "world", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the deadline of the request", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the backoff went on for %s after the request was cancelled", elapsed)
+	}
+	if n := transport.requests(); n != 1 {
+		t.Errorf("sent %d requests, want no retry after the cancellation", n)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt, want := range map[int]time.Duration{2: base, 3: 2 * base, 4: 4 * base} {
+		for i := 0; i < 100; i++ {
+			// the jitter adds up to half the delay
+			if got := retryDelay(base, attempt); got < want || got > want+want/2 {
+				t.Fatalf("retryDelay(%s, %d) = %s, want between %s and %s", base, attempt, got, want, want+want/2)
+			}
+		}
+	}
+	if got := retryDelay(0, 3); got != 0 {
+		t.Errorf("got a delay of %s without a base delay", got)
+	}
+}
+
+func TestNYTimesRetryOptions(t *testing.T) {
+	for _, options := range [][]NYTimesOption{{WithAttempts(0)}, {WithRetryDelay(-time.Second)}, {WithTimeout(0)}} {
+		if _, err := NewNYTimes("test-key", options...); err == nil {
+			t.Error("got no error for invalid retry options")
+		}
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	tests := []struct {
+		remaining    time.Duration
+		attemptsLeft int
+		minTimeout   time.Duration
+		want         time.Duration
+	}{
+		{9 * time.Second, 3, time.Second, 3 * time.Second},
+		{9 * time.Second, 1, time.Second, 9 * time.Second},
+		{2 * time.Second, 4, time.Second, time.Second},
+		{0, 2, 100 * time.Millisecond, 100 * time.Millisecond},
+		{3 * time.Second, 3, 0, time.Second},
+	}
+	for _, tt := range tests {
+		if got := attemptTimeout(tt.remaining, tt.attemptsLeft, tt.minTimeout); got != tt.want {
+			t.Errorf("attemptTimeout(%s, %d, %s) = %s, want %s", tt.remaining, tt.attemptsLeft, tt.minTimeout, got, tt.want)
+		}
+	}
+}
+
+func TestNYTimesAttemptDeadlines(t *testing.T) {
+	transport := &flakyTransport{t: t, failures: []interface{}{500, 500, 500}}
+	nyt, err := NewNYTimes("test-key", WithHTTPClient(&http.Client{Transport: transport}), WithAttempts(3), WithRetryDelay(0), WithMinAttemptTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	start := time.Now()
+	nyt.TopStories(ctx, "world", 1)
+
+	if len(transport.deadlines) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(transport.deadlines))
+	}
+	// the first attempt gets a third of the time left, the last one all of it
+	if first := transport.deadlines[0].Sub(start); first < 900*time.Millisecond || first > 1100*time.Millisecond {
+		t.Errorf("the first attempt got %s, want about a third of 3s", first)
+	}
+	if !transport.deadlines[1].Before(deadline) {
+		t.Errorf("the second attempt got the whole deadline of the request")
+	}
+	if !transport.deadlines[2].Equal(deadline) {
+		t.Errorf("the last attempt has deadline %s, want the deadline of the request %s", transport.deadlines[2], deadline)
+	}
+}
+
+func TestNYTimesSlowAttemptRetried(t *testing.T) {
+	transport := &flakyTransport{t: t, failures: []interface{}{hang{}}}
+	nyt, err := NewNYTimes("test-key", WithHTTPClient(&http.Client{Transport: transport}), WithAttempts(2), WithRetryDelay(0), WithMinAttemptTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	articles, err := nyt.TopStories(ctx, "world", 1)
+	if err != nil || len(articles) != 1 {
+		t.Fatalf("got %d stories, %v, want the retry to succeed after the slow attempt", len(articles), err)
+	}
+	if n := transport.requests(); n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+}
+
+func TestNYTimesNoDeadline(t *testing.T) {
+	transport := &flakyTransport{t: t, failures: []interface{}{500}}
+	nyt, err := NewNYTimes("test-key", WithHTTPClient(&http.Client{Transport: transport}), WithAttempts(2), WithRetryDelay(0), WithTimeout(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := nyt.TopStories(context.Background(), "world", 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.deadlines) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(transport.deadlines))
+	}
+	// without a deadline on the request, every attempt gets the whole timeout of the client
+	for i, deadline := range transport.deadlines {
+		if got := deadline.Sub(start); got < 2*time.Second || got > 2*time.Second+200*time.Millisecond {
+			t.Errorf("attempt %d got %s, want the 2s timeout", i+1, got)
+		}
+	}
+}
+
+func TestNYTimesObservesEachAttempt(t *testing.T) {
+	transport := &flakyTransport{t: t, failures: []interface{}{http.StatusServiceUnavailable, timeoutError{}}}
+	var mu sync.Mutex
+	var observed []error
+	observe := func(section string, _ time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if section != "world" {
+			t.Errorf("observed section %q, want world", section)
+		}
+		observed = append(observed, err)
+	}
+	nyt, err := NewNYTimes("test-key", WithHTTPClient(&http.Client{Transport: transport}), WithAttempts(3), WithRetryDelay(time.Millisecond), WithRequestObserver(observe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nyt.TopStories(context.Background(), "world", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(observed) != 3 {
+		t.Fatalf("observed %d requests, want the 3 attempts", len(observed))
+	}
+	if observed[0] == nil || observed[1] == nil || observed[2] != nil {
+		t.Errorf("observed errors %v, want the 2 failed attempts then the success", observed)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sectionRotation picks a different section to post every day. Sections are either assigned to
+// weekdays, or listed and rotated through one per day, wrapping around at the end of the list.
+type sectionRotation struct {
+	byWeekday map[time.Weekday]string
+	list      []string
+	// fallback is used on the weekdays without a section, none is posted when it's empty
+	fallback string
+}
+
+// weekdays maps the weekday names to their value
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		weekdays[strings.ToLower(day.String())] = day
+	}
+}
+
+// parseSectionRotation parses either 'weekday=section' entries (e.g. 'monday=world') or a plain
+// list of sections to rotate through
+func parseSectionRotation(entries []string, fallback string) (*sectionRotation, error) {
+	r := &sectionRotation{byWeekday: map[time.Weekday]string{}, fallback: strings.ToLower(fallback)}
+	for _, entry := range entries {
+		entry = strings.ToLower(entry)
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 1 {
+			r.list = append(r.list, entry)
+			continue
+		}
+		day, ok := weekdays[strings.TrimSpace(parts[0])]
+		if !ok || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid section rotation entry %q, expected 'weekday=section'", entry)
+		}
+		r.byWeekday[day] = strings.TrimSpace(parts[1])
+	}
+	if len(r.list) > 0 && len(r.byWeekday) > 0 {
+		return nil, fmt.Errorf("invalid section rotation, use either 'weekday=section' entries or a list of sections")
+	}
+	return r, nil
+}
+
+// sectionForDay returns the section of the day of t, or an empty string if there is none
+func (r *sectionRotation) sectionForDay(t time.Time) string {
+	if len(r.list) > 0 {
+		// count the days in t's location so the section changes at its midnight
+		days := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / int64(24*time.Hour/time.Second)
+		return r.list[days%int64(len(r.list))]
+	}
+	if section, ok := r.byWeekday[t.Weekday()]; ok {
+		return section
+	}
+	return r.fallback
+}
+
+// sections lists every section of the rotation
+func (r *sectionRotation) sections() []string {
+	sections := append([]string(nil), r.list...)
+	for _, section := range r.byWeekday {
+		sections = append(sections, section)
+	}
+	if r.fallback != "" {
+		sections = append(sections, r.fallback)
+	}
+	return sections
+}
+
+// validateSectionRotation checks every section of the rotation is supported by the news source
+func validateSectionRotation(r *sectionRotation, newsSource NewsSource) error {
+	if section, ok := unsupportedSection(newsSource, r.sections()...); ok {
+		return fmt.Errorf("section rotation has unsupported section %q", section)
+	}
+	return nil
+}
+
+// ----//----
+
+// rotationPoster posts the top stories of the section of the day to the rotation channels once a
+// day, at the configured hour
+type rotationPoster struct {
+	rotation *sectionRotation
+	channels []string
+	hour     int
+
+	mu sync.Mutex
+	// lastPost is the date of the last post, so a day is only posted once
+	lastPost string
+}
+
+func newRotationPoster(cfg Config) *rotationPoster {
+	if cfg.sectionRotation == nil || len(cfg.sectionRotation.sections()) == 0 || len(cfg.rotationChannels) == 0 {
+		return nil
+	}
+	return &rotationPoster{rotation: cfg.sectionRotation, channels: cfg.rotationChannels, hour: cfg.rotationHour}
+}
+
+// postSectionOfTheDay posts the section of the day if it's time to and it wasn't posted yet.
+// It is meant to be run by the scheduler at least once an hour.
+func (b *Bot) postSectionOfTheDay(ctx context.Context) {
+	p := b.rotationPoster
+	now := b.now()
+	today := now.Format("2006-01-02")
+
+	p.mu.Lock()
+	due := now.Hour() == p.hour && p.lastPost != today
+	if due {
+		p.lastPost = today
+	}
+	p.mu.Unlock()
+	if !due {
+		return
+	}
+
+	if _, ok := b.maintenance.active(); ok {
+		b.logger.Info("skipping the section of the day during maintenance")
+		return
+	}
+	section := p.rotation.sectionForDay(now)
+	if section == "" {
+		return
+	}
+	articles, err := b.newsSource.TopStories(ctx, section, defaultStoryCount)
+	b.metrics.recordRequest(section, err)
+	if err != nil {
+		b.logger.Error("error requesting top stories for the section of the day", "section", section, "error", err)
+		return
+	}
+	if len(articles) == 0 {
+		return
+	}
+
+	opts := b.renderDefaults
+	opts.Header = fmt.Sprintf("📅 Section of the day: %s", b.newsSource.UserFriendlySection(section))
+	for _, channelID := range p.channels {
+		if _, _, err := b.postMessage(ctx, newCorrelationID(), "", channelID, b.render(ctx, articles, opts)); err != nil {
+			b.logger.Error("error posting the section of the day", "channel_id", channelID, "section", section, "error", err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSectionForDayList(t *testing.T) {
+	r, err := parseSectionRotation([]string{"world", "Technology", "science"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := r.sectionForDay(testNow)
+	index := -1
+	for i, section := range r.list {
+		if section == start {
+			index = i
+		}
+	}
+	if index < 0 {
+		t.Fatalf("got section %q, want one of %v", start, r.list)
+	}
+	// a section per day, wrapping around at the end of the list, including across months and years
+	for day := 0; day < 400; day++ {
+		want := r.list[(index+day)%len(r.list)]
+		if got := r.sectionForDay(testNow.AddDate(0, 0, day)); got != want {
+			t.Fatalf("day %d: got %q, want %q", day, got, want)
+		}
+	}
+}
+
+func TestSectionForDayChangesAtLocalMidnight(t *testing.T) {
+	r, _ := parseSectionRotation([]string{"world", "technology"}, "")
+	tokyo := time.FixedZone("JST", 9*60*60)
+	before := time.Date(2024, time.March, 14, 23, 59, 0, 0, tokyo)
+	after := before.Add(2 * time.Minute)
+	if r.sectionForDay(before) == r.sectionForDay(after) {
+		t.Errorf("got %q on both sides of midnight in Tokyo", r.sectionForDay(before))
+	}
+	if r.sectionForDay(before) != r.sectionForDay(before.Add(-23*time.Hour)) {
+		t.Error("the section changed during the day in Tokyo")
+	}
+}
+
+func TestSectionForDayWeekdays(t *testing.T) {
+	monday := time.Date(2024, time.March, 11, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		fallback string
+		want     []string
+	}{
+		{"with a fallback", "home", []string{"world", "technology", "home", "home", "arts", "home", "home"}},
+		{"without a fallback", "", []string{"world", "technology", "", "", "arts", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := parseSectionRotation([]string{"Monday=world", "tuesday = technology", "friday=arts"}, tt.fallback)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for day, want := range tt.want {
+				date := monday.AddDate(0, 0, day)
+				if got := r.sectionForDay(date); got != want {
+					t.Errorf("%s: got %q, want %q", date.Weekday(), got, want)
+				}
+				// the week wraps around
+				if got := r.sectionForDay(date.AddDate(0, 0, 7)); got != want {
+					t.Errorf("next %s: got %q, want %q", date.Weekday(), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSectionRotationErrors(t *testing.T) {
+	for _, entries := range [][]string{
+		{"someday=world"},
+		{"monday="},
+		{"monday=world", "technology"},
+	} {
+		if _, err := parseSectionRotation(entries, ""); err == nil {
+			t.Errorf("parseSectionRotation(%v): got no error", entries)
+		}
+	}
+}
+
+func TestPostSectionOfTheDay(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": {testArticle("World story")}}}
+	rotation, _ := parseSectionRotation([]string{"thursday=world"}, "")
+	clock := newFakeClock()
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.sectionRotation = rotation
+		cfg.rotationChannels = []string{"C0ROTATION1", "C0ROTATION2"}
+		cfg.rotationHour = 13
+	})
+	b.now = clock.Now
+
+	// testNow is a Thursday at noon, the section is posted at 13:00 only once
+	b.postSectionOfTheDay(context.Background())
+	if posts := fake.received("chat.postMessage"); len(posts) != 0 {
+		t.Fatalf("got %d posts before the hour", len(posts))
+	}
+	clock.advance(time.Hour)
+	b.postSectionOfTheDay(context.Background())
+	clock.advance(30 * time.Minute)
+	b.postSectionOfTheDay(context.Background())
+
+	posts := fake.received("chat.postMessage")
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts, want one per channel", len(posts))
+	}
+	for i, channel := range []string{"C0ROTATION1", "C0ROTATION2"} {
+		if posts[i].values.Get("channel") != channel || !strings.Contains(posts[i].text(), "📅 Section of the day: World") {
+			t.Errorf("got post %d to %q: %s", i, posts[i].values.Get("channel"), posts[i].text())
+		}
+	}
+
+	// friday has no section
+	clock.advance(24 * time.Hour)
+	b.postSectionOfTheDay(context.Background())
+	if posts := fake.received("chat.postMessage"); len(posts) != 2 {
+		t.Errorf("got %d posts, want none on a day without a section", len(posts)-2)
+	}
+}
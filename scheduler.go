@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// scheduler runs background jobs periodically until their context is cancelled
+type scheduler struct {
+	wg sync.WaitGroup
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{}
+}
+
+// every runs fn every interval in its own goroutine until ctx is done.
+// A non-positive interval disables the job.
+func (s *scheduler) every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	if interval <= 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	}()
+}
+
+// now runs fn once in its own goroutine, so the job is waited for like the periodic ones
+func (s *scheduler) now(ctx context.Context, fn func(ctx context.Context)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn(ctx)
+	}()
+}
+
+// wait blocks until every scheduled job has returned
+func (s *scheduler) wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// sectionFailureThreshold is the number of consecutive failures after which a section is
+	// considered deprecated by the source
+	sectionFailureThreshold = 3
+	// sectionHideDuration is how long a deprecated section is hidden before being offered again
+	sectionHideDuration = time.Hour
+)
+
+// sectionHealth tracks the sections the source keeps rejecting, e.g. because they were deprecated,
+// to stop offering them for a while. It is safe for concurrent use.
+type sectionHealth struct {
+	logger *slog.Logger
+	// mu makes counting a failure atomic
+	mu sync.Mutex
+	// failures holds the consecutive failures of each section, which are forgotten once the
+	// section didn't fail for as long as it would be hidden
+	failures *ttlCache[string, int]
+	// hidden holds the sections currently hidden, which are offered again once they expire
+	hidden *ttlCache[string, struct{}]
+}
+
+func newSectionHealth(logger *slog.Logger) *sectionHealth {
+	return &sectionHealth{
+		logger:   logger,
+		failures: newTTLCache[string, int](sectionHideDuration),
+		hidden:   newTTLCache[string, struct{}](sectionHideDuration),
+	}
+}
+
+// fail records a rejected request for a section, hiding it once it failed too many times in a row.
+// The failures start over once it is hidden, so it gets as many chances when it is offered again.
+func (h *sectionHealth) fail(section string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	failures, _ := h.failures.Get(section)
+	failures++
+	if failures < sectionFailureThreshold {
+		h.failures.Set(section, failures)
+		return
+	}
+	h.logger.Warn("section failed too many times in a row and may be deprecated, hiding it",
+		"section", section, "failures", failures, "hidden_for", sectionHideDuration)
+	h.failures.Delete(section)
+	h.hidden.Set(section, struct{}{})
+}
+
+// succeed records a successful request for a section, offering it again if it was hidden
+func (h *sectionHealth) succeed(section string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures.Delete(section)
+	h.hidden.Delete(section)
+}
+
+// close stops the background eviction of the caches
+func (h *sectionHealth) close() {
+	h.failures.Close()
+	h.hidden.Close()
+}
+
+// visible filters out the sections currently hidden, keeping the order of the others
+func (h *sectionHealth) visible(sections []string) []string {
+	var result []string
+	for _, section := range sections {
+		if _, hidden := h.hidden.Get(section); !hidden {
+			result = append(result, section)
+		}
+	}
+	return result
+}
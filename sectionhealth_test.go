@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSectionHealth(t *testing.T) {
+	h := newSectionHealth(discardLogger())
+	defer h.close()
+	clock := newFakeClock()
+	h.hidden.now = clock.Now
+	h.failures.now = clock.Now
+	sections := []string{"arts", "world", "science"}
+
+	for i := 1; i < sectionFailureThreshold; i++ {
+		h.fail("world")
+	}
+	if got := h.visible(sections); !reflect.DeepEqual(got, sections) {
+		t.Errorf("got %v, want the section kept under the threshold", got)
+	}
+	h.fail("world")
+	if got, want := h.visible(sections), []string{"arts", "science"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v after repeated failures", got, want)
+	}
+
+	// restored after the cooldown, with as many chances as before
+	clock.advance(sectionHideDuration)
+	if got := h.visible(sections); !reflect.DeepEqual(got, sections) {
+		t.Errorf("got %v, want the section restored after the cooldown", got)
+	}
+	h.fail("world")
+	if got := h.visible(sections); !reflect.DeepEqual(got, sections) {
+		t.Errorf("got %v, want the failures counted again from zero", got)
+	}
+}
+
+func TestSectionHealthFailuresExpire(t *testing.T) {
+	h := newSectionHealth(discardLogger())
+	defer h.close()
+	clock := newFakeClock()
+	h.failures.now = clock.Now
+	sections := []string{"world"}
+
+	// failures an hour apart aren't a streak
+	for i := 1; i < sectionFailureThreshold; i++ {
+		h.fail("world")
+	}
+	clock.advance(sectionHideDuration)
+	h.fail("world")
+	if got := h.visible(sections); len(got) != 1 {
+		t.Errorf("got %v, want the old failures forgotten", got)
+	}
+}
+
+func TestSectionHealthSuccess(t *testing.T) {
+	h := newSectionHealth(discardLogger())
+	defer h.close()
+	sections := []string{"world"}
+
+	// a success breaks the streak of failures
+	for i := 1; i < sectionFailureThreshold; i++ {
+		h.fail("world")
+	}
+	h.succeed("world")
+	h.fail("world")
+	if got := h.visible(sections); len(got) != 1 {
+		t.Errorf("got %v, want the failures before the success forgotten", got)
+	}
+
+	// and restores a hidden section right away
+	for i := 0; i < sectionFailureThreshold; i++ {
+		h.fail("world")
+	}
+	h.succeed("world")
+	if got := h.visible(sections); len(got) != 1 {
+		t.Errorf("got %v, want the section restored by a success", got)
+	}
+}
+
+func TestNYTimesHidesDeprecatedSections(t *testing.T) {
+	nyt := newTestNYTimes(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/automobile.json") {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/arts.json") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeJSONResponse(t, w, topStoriesResponse(nytStory("Story")))
+	})
+	clock := newFakeClock()
+	nyt.health.hidden.now = clock.Now
+
+	for i := 0; i < sectionFailureThreshold; i++ {
+		if _, err := nyt.TopStories(context.Background(), "automobile", 1); err == nil {
+			t.Fatal("got no error for the deprecated section")
+		}
+		// other errors don't tell the section is gone
+		nyt.TopStories(context.Background(), "arts", 1)
+	}
+	sections := nyt.SupportedSections()
+	if contains(sections, "automobile") {
+		t.Errorf("got sections %v, want automobile hidden", sections)
+	}
+	if !contains(sections, "arts") || len(sections) != len(defaultNYTSections)-1 {
+		t.Errorf("got sections %v, want only automobile hidden", sections)
+	}
+
+	clock.advance(sectionHideDuration)
+	if sections := nyt.SupportedSections(); !contains(sections, "automobile") {
+		t.Errorf("got sections %v, want automobile restored after the cooldown", sections)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/slack-go/slack"
+)
+
+// verifySignature checks the signature slack computes over the raw body of a request with the
+// signing secret (https://api.slack.com/authentication/verifying-requests-from-slack). The body is
+// buffered, so the handler can still parse it afterwards. Requests older than five minutes are
+// rejected, to prevent replays.
+// Without a signing secret nothing is checked and verified is false: the handler then falls back
+// to the deprecated verification token.
+func (b *Bot) verifySignature(r *http.Request) (verified bool, err error) {
+	if b.slackSigningSecret == "" {
+		return false, nil
+	}
+
+	verifier, err := slack.NewSecretsVerifier(r.Header, b.slackSigningSecret)
+	if err != nil {
+		return false, err
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false, fmt.Errorf("error reading request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if _, err := verifier.Write(body); err != nil {
+		return false, err
+	}
+	if err := verifier.Ensure(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
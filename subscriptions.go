@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscription is a channel that periodically receives a digest of a section's top stories
+type subscription struct {
+	TeamID    string `json:"team_id,omitempty"`
+	ChannelID string `json:"channel_id"`
+	Section   string `json:"section"`
+}
+
+// parseSubscriptions parses subscriptions in the 'channelID:section' format
+func parseSubscriptions(entries []string) ([]subscription, error) {
+	var subs []subscription
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid subscription %q, expected 'channelID:section'", entry)
+		}
+		subs = append(subs, subscription{ChannelID: parts[0], Section: normalizeSection(parts[1])})
+	}
+	return subs, nil
+}
+
+// subscriptionStore keeps the channel subscriptions. It is safe for concurrent use.
+type subscriptionStore struct {
+	mu            sync.Mutex
+	subscriptions []subscription
+}
+
+func newSubscriptionStore(subs []subscription) *subscriptionStore {
+	return &subscriptionStore{subscriptions: subs}
+}
+
+// List returns a copy of the current subscriptions
+func (s *subscriptionStore) List() []subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := make([]subscription, len(s.subscriptions))
+	copy(subs, s.subscriptions)
+	return subs
+}
+
+// key identifies the subscription
+func (s subscription) key() string {
+	return s.TeamID + "/" + s.ChannelID + "/" + s.Section
+}
+
+// digestHistoryTTL is how long the stories of a digest are remembered, so the removed
+// subscriptions are forgotten and an unchanged digest is still posted once a day
+const digestHistoryTTL = 24 * time.Hour
+
+// digestHistory remembers the stories of the last digest posted for each subscription, to skip
+// the digests with no new stories. It is safe for concurrent use.
+type digestHistory struct {
+	posted *ttlCache[string, map[string]bool]
+}
+
+// newDigestHistory returns the history of the digests, or nil when deduplication is disabled.
+// The history outlives a couple of digests, however long their interval.
+func newDigestHistory(cfg Config) *digestHistory {
+	if !cfg.deduplicateDigests {
+		return nil
+	}
+	return &digestHistory{posted: newTTLCache[string, map[string]bool](max(digestHistoryTTL, 2*cfg.digestInterval))}
+}
+
+// hasNewStories reports whether any of the stories wasn't in the last digest of the subscription
+func (h *digestHistory) hasNewStories(sub subscription, articles []Article) bool {
+	posted, _ := h.posted.Get(sub.key())
+	for _, a := range articles {
+		if !posted[a.URL] {
+			return true
+		}
+	}
+	return false
+}
+
+// record remembers the stories of the digest just posted for the subscription
+func (h *digestHistory) record(sub subscription, articles []Article) {
+	urls := make(map[string]bool, len(articles))
+	for _, a := range articles {
+		urls[a.URL] = true
+	}
+	h.posted.Set(sub.key(), urls)
+}
+
+// ----//----
+
+// postDigests posts the top stories digest to every subscribed channel. The digests are posted
+// concurrently, within the limit of concurrent posts of the bot.
+func (b *Bot) postDigests(ctx context.Context) {
+	if _, ok := b.maintenance.active(); ok {
+		b.logger.Info("skipping digests during maintenance")
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range b.subscriptions.List() {
+		wg.Add(1)
+		go func(sub subscription) {
+			defer wg.Done()
+			b.postDigest(ctx, sub)
+		}(sub)
+	}
+	wg.Wait()
+}
+
+// postDigest posts the top stories digest of a subscription
+func (b *Bot) postDigest(ctx context.Context, sub subscription) {
+	articles, err := b.newsSource.TopStories(ctx, sub.Section, 3)
+	b.metrics.recordRequest(sub.Section, err)
+	if err != nil {
+		b.logger.Error("error requesting top stories for digest", "channel_id", sub.ChannelID, "section", sub.Section, "error", err)
+		return
+	}
+	if len(articles) == 0 {
+		return
+	}
+	if b.digestHistory != nil && !b.digestHistory.hasNewStories(sub, articles) {
+		b.logger.Info("no new stories for digest, skipping it", "channel_id", sub.ChannelID, "section", sub.Section)
+		return
+	}
+
+	opts := b.renderDefaults
+	if b.compactDigestHeaders {
+		opts.CompactHeader = true
+		opts.Header = b.newsSource.UserFriendlySection(sub.Section)
+	}
+	channelID, ts, err := b.postMessage(ctx, newCorrelationID(), sub.TeamID, sub.ChannelID,
+		b.render(ctx, articles, opts),
+	)
+	if err != nil {
+		b.logger.Error("error posting digest", "channel_id", sub.ChannelID, "section", sub.Section, "error", err)
+		return
+	}
+	if b.digestHistory != nil {
+		b.digestHistory.record(sub, articles)
+	}
+	b.pinDigest(sub.TeamID, channelID, ts)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostDigestsMaxConcurrentPosts(t *testing.T) {
+	for _, limit := range []int{1, 3} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			var subs []subscription
+			for i := 0; i < 10; i++ {
+				subs = append(subs, subscription{ChannelID: fmt.Sprintf("C0CHANNEL%02d", i), Section: "world"})
+			}
+			news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+			b, fake := newTestBot(t, news, func(cfg *Config) {
+				cfg.subscriptions = subs
+				cfg.maxConcurrentPosts = limit
+			})
+
+			var mu sync.Mutex
+			active, maxActive := 0, 0
+			fake.handling = func(method string) {
+				if method != "chat.postMessage" {
+					return
+				}
+				mu.Lock()
+				active++
+				maxActive = max(maxActive, active)
+				mu.Unlock()
+				// hold the post so the concurrent ones pile up
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				active--
+				mu.Unlock()
+			}
+
+			b.postDigests(context.Background())
+			if posts := fake.received("chat.postMessage"); len(posts) != len(subs) {
+				t.Errorf("got %d posts, want a digest for each of the %d subscriptions", len(posts), len(subs))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if maxActive != limit {
+				t.Errorf("got up to %d concurrent posts, want %d", maxActive, limit)
+			}
+		})
+	}
+}
+
+func TestDigestDeduplication(t *testing.T) {
+	first, second := testArticle("First"), testArticle("Second")
+	tests := []struct {
+		name        string
+		deduplicate bool
+		// runs are the stories of each digest run
+		runs [][]Article
+		// posts is the number of digests posted
+		posts int
+	}{
+		{"disabled", false, [][]Article{{first}, {first}}, 2},
+		{"no change", true, [][]Article{{first, second}, {first, second}}, 1},
+		{"reordered", true, [][]Article{{first, second}, {second, first}}, 1},
+		{"fewer stories", true, [][]Article{{first, second}, {first}}, 1},
+		{"new story", true, [][]Article{{first}, {first, second}}, 2},
+		{"stories replaced", true, [][]Article{{first}, {second}, {first}}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			news := &fakeNews{stories: map[string][]Article{}}
+			b, fake := newTestBot(t, news, func(cfg *Config) {
+				cfg.subscriptions = []subscription{{ChannelID: "C0TESTCHANNEL", Section: "world"}}
+				cfg.deduplicateDigests = tt.deduplicate
+			})
+			for _, stories := range tt.runs {
+				news.mu.Lock()
+				news.stories["world"] = stories
+				news.mu.Unlock()
+				b.postDigests(context.Background())
+			}
+			if posts := fake.received("chat.postMessage"); len(posts) != tt.posts {
+				t.Errorf("got %d digests, want %d", len(posts), tt.posts)
+			}
+		})
+	}
+}
+
+func TestDigestDeduplicationPerSubscription(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3), "science": testArticles(3)}}
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.subscriptions = []subscription{
+			{ChannelID: "C0TESTCHANNEL", Section: "world"},
+			{ChannelID: "C0OTHERCHANNEL", Section: "world"},
+			{ChannelID: "C0TESTCHANNEL", Section: "science"},
+		}
+		cfg.deduplicateDigests = true
+	})
+	b.postDigests(context.Background())
+	b.postDigests(context.Background())
+	if posts := fake.received("chat.postMessage"); len(posts) != 3 {
+		t.Errorf("got %d digests, want one for each subscription", len(posts))
+	}
+}
+
+func TestDigestDeduplicationRetriesFailedPosts(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.subscriptions = []subscription{{ChannelID: "C0TESTCHANNEL", Section: "world"}}
+		cfg.deduplicateDigests = true
+	})
+	fake.failNext("chat.postMessage", "ratelimited")
+	b.postDigests(context.Background())
+	b.postDigests(context.Background())
+	b.postDigests(context.Background())
+	if posts := fake.received("chat.postMessage"); len(posts) != 2 {
+		t.Errorf("got %d posts, want the failed digest posted again once", len(posts))
+	}
+}
+
+func TestDigestHistoryExpiry(t *testing.T) {
+	h := newDigestHistory(Config{deduplicateDigests: true})
+	defer h.posted.Close()
+	clock := newFakeClock()
+	h.posted.now = clock.Now
+
+	sub := subscription{ChannelID: "C0TESTCHANNEL", Section: "world"}
+	articles := testArticles(2)
+	h.record(sub, articles)
+	clock.advance(digestHistoryTTL - time.Minute)
+	if h.hasNewStories(sub, articles) {
+		t.Error("the digest was forgotten before the TTL")
+	}
+	// an unchanged digest is posted again once a day
+	clock.advance(time.Minute)
+	if !h.hasNewStories(sub, articles) {
+		t.Error("the digest is still remembered after the TTL")
+	}
+
+	if newDigestHistory(Config{}) != nil {
+		t.Error("got a history with the deduplication disabled")
+	}
+	long := newDigestHistory(Config{deduplicateDigests: true, digestInterval: 36 * time.Hour})
+	defer long.posted.Close()
+	if long.posted.ttl != 72*time.Hour {
+		t.Errorf("got a TTL of %s, want twice the interval of the digests", long.posted.ttl)
+	}
+}
+
+func TestPostDigestsCompactHeaders(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		news := &fakeNews{stories: map[string][]Article{"world": testArticles(3)}}
+		b, fake := newTestBot(t, news, func(cfg *Config) {
+			cfg.subscriptions = []subscription{{ChannelID: "C0TESTCHANNEL", Section: "world"}}
+			cfg.compactDigestHeaders = compact
+		})
+		b.postDigests(context.Background())
+
+		calls := fake.received("chat.postMessage")
+		if len(calls) != 1 {
+			t.Fatalf("compact %t: got %d digests, want 1", compact, len(calls))
+		}
+		var blocks []map[string]interface{}
+		if err := json.Unmarshal([]byte(calls[0].values.Get("blocks")), &blocks); err != nil {
+			t.Fatal(err)
+		}
+		first := blocks[0]
+		if !compact {
+			if first["type"] != "header" {
+				t.Errorf("got a %v block first, want the full header", first["type"])
+			}
+			continue
+		}
+		if first["type"] != "context" {
+			t.Fatalf("got a %v block first, want the compact header", first["type"])
+		}
+		text := first["elements"].([]interface{})[0].(map[string]interface{})["text"].(string)
+		if !strings.HasPrefix(text, "*World* · <!date^") {
+			t.Errorf("got header line %q, want the section and the date", text)
+		}
+		for _, block := range blocks {
+			if block["type"] == "header" {
+				t.Errorf("got a header block in a compact digest: %v", block)
+			}
+		}
+	}
+}
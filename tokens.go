@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// TokenStore provides the slack bot token for each workspace the app is installed in
+type TokenStore interface {
+	// Token returns the current bot token of a workspace
+	Token(teamID string) (string, error)
+	// MarkNeedsReauth flags a workspace whose token is no longer valid
+	MarkNeedsReauth(teamID string)
+}
+
+// staticTokenStore always returns the same token, regardless of the workspace
+type staticTokenStore struct {
+	token string
+}
+
+func (s staticTokenStore) Token(teamID string) (string, error) {
+	return s.token, nil
+}
+
+// MarkNeedsReauth does nothing, the bot already logs the token needs to be replaced
+func (s staticTokenStore) MarkNeedsReauth(teamID string) {}
+
+// fileTokenStore reads the token from a file every time it is requested, so a rotated
+// token (e.g. an updated kubernetes secret mounted as a file) is picked up without a restart
+type fileTokenStore struct {
+	path string
+}
+
+func (s fileTokenStore) Token(teamID string) (string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// MarkNeedsReauth does nothing, the bot already logs the token needs to be replaced, and the new
+// token is read from the file on the next request
+func (s fileTokenStore) MarkNeedsReauth(teamID string) {}
+
+// ----//----
+
+// responseURLTimeout bounds the posts to the response URLs
+const responseURLTimeout = 10 * time.Second
+
+// slackClients keeps one slack client per workspace, built from the tokens of a TokenStore.
+// It is safe for concurrent use.
+type slackClients struct {
+	mu      sync.Mutex
+	tokens  TokenStore
+	clients map[string]*slack.Client
+	// options are applied to every client, e.g. to send the requests to another API URL
+	options []slack.Option
+	// httpClient posts the responses to the response URLs, which need no token
+	httpClient *http.Client
+}
+
+func newSlackClients(tokens TokenStore) *slackClients {
+	return &slackClients{
+		tokens:     tokens,
+		clients:    map[string]*slack.Client{},
+		httpClient: &http.Client{Timeout: responseURLTimeout},
+	}
+}
+
+// get returns the client for a workspace, building it the first time it is requested
+func (c *slackClients) get(teamID string) (*slack.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[teamID]; ok {
+		return client, nil
+	}
+	return c.build(teamID)
+}
+
+// refresh re-reads the token of a workspace and rebuilds its client
+func (c *slackClients) refresh(teamID string) (*slack.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.build(teamID)
+}
+
+func (c *slackClients) build(teamID string) (*slack.Client, error) {
+	token, err := c.tokens.Token(teamID)
+	if err != nil {
+		return nil, err
+	}
+	client := slack.New(token, c.options...)
+	c.clients[teamID] = client
+	return client, nil
+}
+
+// isSlackError reports whether err is a slack API error with one of the given codes
+func isSlackError(err error, codes ...string) bool {
+	if err == nil {
+		return false
+	}
+	for _, code := range codes {
+		if err.Error() == code {
+			return true
+		}
+	}
+	return false
+}
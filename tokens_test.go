@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// rotatingTokenStore returns a new token every time it is read, recording the workspaces
+// marked as needing a reauth
+type rotatingTokenStore struct {
+	mu      sync.Mutex
+	reads   int
+	reauths []string
+}
+
+func (s *rotatingTokenStore) Token(teamID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	return fmt.Sprintf("xoxb-%d", s.reads), nil
+}
+
+func (s *rotatingTokenStore) MarkNeedsReauth(teamID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reauths = append(s.reauths, teamID)
+}
+
+func TestSendMessageRefreshesRevokedToken(t *testing.T) {
+	for _, code := range []string{"token_revoked", "invalid_auth"} {
+		t.Run(code, func(t *testing.T) {
+			tokens := &rotatingTokenStore{}
+			b, fake := newTestBot(t, &fakeNews{}, func(cfg *Config) { cfg.slackTokens = tokens })
+			fake.failNext("chat.postMessage", code)
+
+			_, ts, err := b.sendMessage(context.Background(), "test-request", "T0TEST", "C0TESTCHANNEL", slack.MsgOptionText("hello", false))
+			if err != nil {
+				t.Fatalf("got error %v, want the retry to succeed", err)
+			}
+			if ts == "" {
+				t.Error("got no timestamp")
+			}
+			calls := fake.received("chat.postMessage")
+			if len(calls) != 2 {
+				t.Fatalf("got %d posts, want 2", len(calls))
+			}
+			if first, retry := calls[0].values.Get("token"), calls[1].values.Get("token"); first != "xoxb-1" || retry != "xoxb-2" {
+				t.Errorf("posted with tokens %q then %q, want the retry to use the refreshed token", first, retry)
+			}
+			if len(tokens.reauths) > 0 {
+				t.Errorf("marked %v as needing reauth, want none", tokens.reauths)
+			}
+
+			// the refreshed client is kept for the next messages
+			if _, _, err := b.sendMessage(context.Background(), "test-request", "T0TEST", "C0TESTCHANNEL", slack.MsgOptionText("hello", false)); err != nil {
+				t.Fatal(err)
+			}
+			if calls := fake.received("chat.postMessage"); calls[2].values.Get("token") != "xoxb-2" || tokens.reads != 2 {
+				t.Errorf("posted with token %q after %d reads, want the refreshed client reused", calls[2].values.Get("token"), tokens.reads)
+			}
+		})
+	}
+}
+
+func TestSendMessageGivesUpOnRevokedToken(t *testing.T) {
+	tokens := &rotatingTokenStore{}
+	b, fake := newTestBot(t, &fakeNews{}, func(cfg *Config) { cfg.slackTokens = tokens })
+	fake.failNext("chat.postMessage", "token_revoked", "token_revoked")
+
+	_, _, err := b.sendMessage(context.Background(), "test-request", "T0TEST", "C0TESTCHANNEL", slack.MsgOptionText("hello", false))
+	if !isSlackError(err, "token_revoked") {
+		t.Fatalf("got error %v, want token_revoked", err)
+	}
+	if calls := fake.received("chat.postMessage"); len(calls) != 2 {
+		t.Errorf("got %d posts, want a single retry", len(calls))
+	}
+	if len(tokens.reauths) != 1 || tokens.reauths[0] != "T0TEST" {
+		t.Errorf("got reauths %v, want T0TEST marked", tokens.reauths)
+	}
+}
+
+func TestSendMessageDoesNotRetryOtherErrors(t *testing.T) {
+	tokens := &rotatingTokenStore{}
+	b, fake := newTestBot(t, &fakeNews{}, func(cfg *Config) { cfg.slackTokens = tokens })
+	fake.failNext("chat.postMessage", "channel_not_found")
+
+	if _, _, err := b.sendMessage(context.Background(), "test-request", "T0TEST", "C0TESTCHANNEL", slack.MsgOptionText("hello", false)); !isSlackError(err, "channel_not_found") {
+		t.Fatalf("got error %v, want channel_not_found", err)
+	}
+	if calls := fake.received("chat.postMessage"); len(calls) != 1 || tokens.reads != 1 {
+		t.Errorf("got %d posts and %d token reads, want no retry", len(calls), tokens.reads)
+	}
+}
+
+func TestFileTokenStoreRereadsTheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	store := fileTokenStore{path: path}
+	for _, token := range []string{"xoxb-old", "xoxb-new"} {
+		if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Token("T0TEST"); err != nil || got != token {
+			t.Errorf("got %q, %v, want %q", got, err, token)
+		}
+	}
+}
+
+func TestSendMessageStopsWaitingForASlot(t *testing.T) {
+	b, fake := newTestBot(t, &fakeNews{}, func(cfg *Config) { cfg.maxConcurrentPosts = 1 })
+	b.postSlots <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := b.sendMessage(ctx, "test-request", "T0TEST", "C0TESTCHANNEL", slack.MsgOptionText("hello", false)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	req := testCommandRequest(fake)
+	if err := b.postToResponseURL(ctx, req, slack.MsgOptionText("hello", false)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v posting to the response url, want context.Canceled", err)
+	}
+	if calls := len(fake.received("chat.postMessage")) + len(fake.received("response")); calls != 0 {
+		t.Errorf("got %d posts, want none while the slots are taken", calls)
+	}
+
+	// a free slot is taken even when the context is done
+	<-b.postSlots
+	if _, _, err := b.sendMessage(ctx, "test-request", "T0TEST", "C0TESTCHANNEL", slack.MsgOptionText("hello", false)); err != nil {
+		t.Fatalf("got error %v, want the message posted with a free slot", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+)
+
+// maxTopicLength is the maximum length of a channel topic accepted by slack
+const maxTopicLength = 250
+
+// topicHeadline builds the topic of a channel from a headline, truncated to fit the topic
+func topicHeadline(headline string) string {
+	return truncateText("📰 Trending now: "+headline, maxTopicLength)
+}
+
+// updateTopicHeadlines sets the topic of each subscribed channel to the top headline of its
+// section. Channels with several subscriptions show the headline of the first one.
+func (b *Bot) updateTopicHeadlines(ctx context.Context) {
+	if _, ok := b.maintenance.active(); ok {
+		b.logger.Info("skipping topic headlines during maintenance")
+		return
+	}
+
+	updated := map[string]bool{}
+	for _, sub := range b.subscriptions.List() {
+		if updated[sub.ChannelID] {
+			continue
+		}
+		updated[sub.ChannelID] = true
+
+		articles, err := b.newsSource.TopStories(ctx, sub.Section, 1)
+		b.metrics.recordRequest(sub.Section, err)
+		if err != nil {
+			b.logger.Error("error requesting top stories for topic", "channel_id", sub.ChannelID, "section", sub.Section, "error", err)
+			continue
+		}
+		if len(articles) == 0 {
+			continue
+		}
+		b.setTopicHeadline(ctx, sub.TeamID, sub.ChannelID, articles[0].Title)
+	}
+}
+
+// setTopicHeadline sets the topic of a channel to a headline. The bot needs the channels:manage
+// scope and to be a member of the channel, a missing permission is only logged.
+func (b *Bot) setTopicHeadline(ctx context.Context, teamID string, channelID string, headline string) {
+	client, err := b.slackClients.get(teamID)
+	if err != nil {
+		b.logger.Error("error setting topic", "channel_id", channelID, "error", err)
+		return
+	}
+
+	_, err = client.SetTopicOfConversationContext(ctx, channelID, topicHeadline(headline))
+	switch {
+	case isSlackError(err, "missing_scope", "not_in_channel", "restricted_action", "channel_not_found"):
+		b.logger.Warn("not allowed to set the topic, check the bot is a member and has the channels:manage scope", "channel_id", channelID, "error", err)
+	case err != nil:
+		b.logger.Error("error setting topic", "channel_id", channelID, "error", err)
+	}
+}
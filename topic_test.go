@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTopicHeadline(t *testing.T) {
+	prefix := "📰 Trending now: "
+	fits := strings.Repeat("a", maxTopicLength-utf8.RuneCountInString(prefix))
+	tests := []struct {
+		headline string
+		want     string
+	}{
+		{"Short headline", prefix + "Short headline"},
+		{fits, prefix + fits},
+		{fits + "b", prefix + fits[:len(fits)-1] + "…"},
+		{strings.Repeat("é", 300), prefix + strings.Repeat("é", maxTopicLength-utf8.RuneCountInString(prefix)-1) + "…"},
+	}
+	for _, tt := range tests {
+		got := topicHeadline(tt.headline)
+		if got != tt.want {
+			t.Errorf("topicHeadline(%d runes) = %q, want %q", utf8.RuneCountInString(tt.headline), got, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); n > maxTopicLength {
+			t.Errorf("got a topic of %d characters, want at most %d", n, maxTopicLength)
+		}
+	}
+}
+
+// topics returns the channels and topics of the conversations.setTopic calls, e.g. 'C0TEST: topic'
+func topics(fake *fakeSlack) []string {
+	var calls []string
+	for _, call := range fake.received("conversations.setTopic") {
+		calls = append(calls, call.values.Get("channel")+": "+call.values.Get("topic"))
+	}
+	return calls
+}
+
+func TestUpdateTopicHeadlines(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{
+		"world":   testArticles(3),
+		"science": {testArticle("Science story")},
+		"arts":    nil,
+	}}
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.subscriptions = []subscription{
+			{ChannelID: "C0TESTCHANNEL", Section: "science"},
+			{ChannelID: "C0TESTCHANNEL", Section: "world"},
+			{ChannelID: "C0OTHERCHANNEL", Section: "world"},
+			{ChannelID: "C0ARTSCHANNEL", Section: "arts"},
+		}
+	})
+	b.updateTopicHeadlines(context.Background())
+
+	want := []string{
+		"C0TESTCHANNEL: 📰 Trending now: Science story",
+		"C0OTHERCHANNEL: 📰 Trending now: Story 1",
+	}
+	if got := topics(fake); !reflect.DeepEqual(got, want) {
+		t.Errorf("got topics %v, want %v", got, want)
+	}
+	// a channel with several subscriptions only gets the stories of the first one
+	if requests := news.requested(); !reflect.DeepEqual(requests, []string{"top science", "top world", "top arts"}) {
+		t.Errorf("requested %v", requests)
+	}
+}
+
+func TestUpdateTopicHeadlinesErrors(t *testing.T) {
+	news := &fakeNews{stories: map[string][]Article{"world": testArticles(1)}}
+	b, fake := newTestBot(t, news, func(cfg *Config) {
+		cfg.subscriptions = []subscription{
+			{ChannelID: "C0TESTCHANNEL", Section: "world"},
+			{ChannelID: "C0OTHERCHANNEL", Section: "world"},
+		}
+	})
+	// a missing permission in a channel doesn't stop the other topics
+	fake.failNext("conversations.setTopic", "not_in_channel")
+	b.updateTopicHeadlines(context.Background())
+	if got := topics(fake); len(got) != 2 {
+		t.Errorf("got topic calls %v, want one for each channel", got)
+	}
+
+	b.maintenance.set(true, "")
+	b.updateTopicHeadlines(context.Background())
+	if got := topics(fake); len(got) != 2 {
+		t.Errorf("got topic calls %v during maintenance, want none", got[2:])
+	}
+}